@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+)
+
+// listTests 执行 list 子命令，打印每个测试用例集的步骤计划
+func listTests(args []string) int {
+	var opts commonOptions
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	opts.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	cfg, err := opts.loadConfig()
+	if err != nil {
+		log.Println(err)
+		return exitUsage
+	}
+	paths, err := opts.testCasePaths(cfg)
+	if err != nil {
+		log.Println(err)
+		return exitUsage
+	}
+
+	testCases, err := loadTestCases(paths, cfg)
+	if err != nil {
+		log.Println(err)
+		return exitFailed
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for i, set := range testCases {
+		fmt.Fprintf(w, "Test case set %d: %s\n", i, paths[i])
+		for _, tc := range set {
+			path := tc.Path
+			if path != "" && tc.Type != "sleep" {
+				path = filepath.Base(path)
+			}
+			expect := ""
+			if tc.Expect != nil && tc.Expect.Cause != nil {
+				expect = fmt.Sprintf("expect cause %d", *tc.Expect.Cause)
+			}
+			fmt.Fprintf(w, "  %d\t%s\t%s\t%s\t%s\n", tc.Step, tc.Type, tc.Action, path, expect)
+		}
+		fmt.Fprintln(w)
+	}
+	w.Flush()
+
+	return exitOK
+}
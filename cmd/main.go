@@ -1,67 +1,53 @@
 package main
 
 import (
+	"fmt"
 	"log"
-	"net"
 	"os"
-	"os/signal"
-	"syscall"
-	"upftester/internal/config"
-	"upftester/internal/handler"
-	"upftester/internal/network"
 )
 
-const TestCasePath = "../testcases/complete_test_case/complete_test_case.yaml"
+// 退出码
+const (
+	exitOK          = 0 // 全部测试通过
+	exitFailed      = 1 // 存在失败的测试用例集
+	exitUsage       = 2 // 参数或配置错误
+	exitTimeout     = 3 // 运行超时
+	exitInterrupted = 4 // 运行被 SIGINT/SIGTERM 中断
+)
 
-func main() {
-	log.SetFlags(log.LstdFlags | log.Lshortfile)
+const usage = `Usage: upf-tester <command> [flags]
 
-	var config config.Config
-	err := config.LoadConfig("../config/config.yaml")
-	if err != nil {
-		log.Fatal(err)
-		return
-	}
+Commands:
+  run       establish the association and run test case sets against the UPF
+  validate  load and check the config and step YAMLs without sending anything
+  list      print the step plan of each test case set
 
-	udpTransport, err := network.NewUDPTransport(config.Basic.LocalN4Ip, "8805", config.Resource.QueueSize)
-	if err != nil {
-		log.Fatal(err)
-		return
-	}
-	udpTransport.Start()
-	defer udpTransport.Stop()
-
-	handler.NewPFCPDispatcher(udpTransport).Start()
+Run 'upf-tester <command> -h' for the flags of a command.
+`
 
-	err = handler.SendPFCPAssociationRequest(config.Basic.LocalN4Ip, config.Basic.UpfN4Ip, udpTransport)
-	if err != nil {
-		log.Fatal(err)
-		return
-	}
+func main() {
+	log.SetFlags(log.LstdFlags | log.Lshortfile)
+	os.Exit(runCommand(os.Args[1:]))
+}
 
-	// Load test cases
-	if len(config.TestCases) > 0 {
-		for _, path := range config.TestCases {
-			log.Printf("Loading test case from: %s", path)
-			handler.LoadTestCases(path, &handler.GlobalTestCases)
-		}
-	} else {
-		log.Println("No test cases in config, loading default test case")
-		handler.LoadTestCases(TestCasePath, &handler.GlobalTestCases)
+func runCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return exitUsage
 	}
 
-	remoteAddr, err := net.ResolveUDPAddr("udp", config.Basic.UpfN4Ip+":8805")
-	if err != nil {
-		log.Fatal(err)
-		return
+	switch args[0] {
+	case "run":
+		return runTests(args[1:])
+	case "validate":
+		return validateTests(args[1:])
+	case "list":
+		return listTests(args[1:])
+	case "-h", "-help", "--help", "help":
+		fmt.Fprint(os.Stdout, usage)
+		return exitOK
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", args[0], usage)
+		return exitUsage
 	}
-	handler.RunTestCases(remoteAddr, udpTransport)
-
-	log.Println("Test cases completed")
-
-	stopChan := make(chan os.Signal, 1)
-	signal.Notify(stopChan, syscall.SIGINT, syscall.SIGTERM)
-	<-stopChan
-
-	log.Println("Received shutdown signal, exiting...")
 }
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"upftester/internal/mockupf"
+)
+
+func main() {
+	log.SetFlags(log.LstdFlags | log.Lshortfile)
+
+	n4Addr := flag.String("n4", "127.0.0.2:8805", "N4 listen address (IP:Port)")
+	n3Ip := flag.String("n3", "", "N3 interface IP, defaults to the N4 IP")
+	gtpuPort := flag.Int("gtpu-port", 2152, "GTP-U port")
+	n6Addr := flag.String("n6", "", "N6 tunnel listen address (IP:Port) for DN side IP-in-UDP traffic, disabled if empty")
+	n6Peer := flag.String("dn", "", "DN side N6 tunnel address (IP:Port), uplink packets are forwarded there instead of reflected")
+	nodeID := flag.String("node-id", "", "UPF Node ID, defaults to the N4 IP")
+	startSeid := flag.Uint64("start-seid", 1, "first UPF SEID to allocate")
+	startTeid := flag.Uint("start-teid", 1, "first local TEID to allocate")
+	startUeip := flag.String("start-ueip", "10.251.0.1", "first UE IP to allocate for PDRs with CHV4 set")
+	assocCause := flag.Uint("association-cause", 1, "cause for Association Setup Response")
+	estCause := flag.Uint("establishment-cause", 1, "cause for Session Establishment Response")
+	modCause := flag.Uint("modification-cause", 1, "cause for Session Modification Response")
+	delCause := flag.Uint("deletion-cause", 1, "cause for Session Deletion Response")
+	requireAssoc := flag.Bool("require-association", false, "reject sessions from peers without an association")
+	dropRequests := flag.Int("drop-requests", 0, "drop the first N PFCP requests to exercise retransmission")
+	duplicateResponses := flag.Bool("duplicate-responses", false, "send every PFCP response twice")
+	flag.Parse()
+
+	upf, err := mockupf.New(mockupf.Config{
+		N4Addr:    *n4Addr,
+		N3Ip:      *n3Ip,
+		GTPUPort:  *gtpuPort,
+		N6Addr:    *n6Addr,
+		N6Peer:    *n6Peer,
+		NodeID:    *nodeID,
+		StartSEID: *startSeid,
+		StartTEID: uint32(*startTeid),
+		StartUEIP: *startUeip,
+		Causes: mockupf.Causes{
+			AssociationSetup: uint8(*assocCause),
+			Establishment:    uint8(*estCause),
+			Modification:     uint8(*modCause),
+			Deletion:         uint8(*delCause),
+		},
+		RequireAssociation: *requireAssoc,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	upf.DropRequests(*dropRequests)
+	upf.SetDuplicateResponses(*duplicateResponses)
+	upf.Start()
+	defer upf.Stop()
+
+	stopChan := make(chan os.Signal, 1)
+	signal.Notify(stopChan, syscall.SIGINT, syscall.SIGTERM)
+	<-stopChan
+
+	log.Println("Received shutdown signal, exiting...")
+}
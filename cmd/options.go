@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"upftester/internal/config"
+	"upftester/internal/handler"
+)
+
+// stringList 可重复指定的字符串参数
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// commonOptions 各子命令共用的参数
+type commonOptions struct {
+	configPath string
+	testCases  stringList
+}
+
+func (o *commonOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.configPath, "config", "config/config.yaml", "path of the tester config file")
+	fs.Var(&o.testCases, "testcase", "test case set file, repeatable; defaults to testCases in the config")
+}
+
+// loadConfig 加载并校验配置文件
+func (o *commonOptions) loadConfig() (*config.Config, error) {
+	var cfg config.Config
+	if err := cfg.LoadConfig(o.configPath); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("validate config %s failed: %w", o.configPath, err)
+	}
+	return &cfg, nil
+}
+
+// testCasePaths 返回要执行的测试用例集，配置文件中的相对路径以配置文件所在目录为基准
+func (o *commonOptions) testCasePaths(cfg *config.Config) ([]string, error) {
+	if len(o.testCases) > 0 {
+		return o.testCases, nil
+	}
+
+	if len(cfg.TestCases) == 0 {
+		return nil, fmt.Errorf("no test case given, use -testcase or testCases in %s", o.configPath)
+	}
+
+	paths := make([]string, 0, len(cfg.TestCases))
+	for _, path := range cfg.TestCases {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(o.configPath), path)
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// loadTestCases 加载所有测试用例集，步骤文件中的模板引用 cfg
+func loadTestCases(paths []string, cfg *config.Config) ([][]handler.TestCase, error) {
+	testCases := make([][]handler.TestCase, 0, len(paths))
+	for _, path := range paths {
+		set, err := handler.LoadTestCases(path, cfg)
+		if err != nil {
+			return nil, err
+		}
+		testCases = append(testCases, set)
+	}
+	return testCases, nil
+}
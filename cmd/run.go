@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	"upftester/internal/handler"
+	"upftester/internal/report"
+)
+
+// runTests 执行 run 子命令
+func runTests(args []string) int {
+	var opts commonOptions
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	opts.register(fs)
+	reportPath := fs.String("report", "", "write the test report to this file")
+	reportFormat := fs.String("report-format", "json", "test report format: json or junit")
+	timeout := fs.Duration("timeout", 0, "abort the run after this duration, 0 means no limit")
+	associate := fs.Bool("associate", true, "set up the PFCP association before running the test cases, disable when the test cases contain association_setup steps")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if err := report.CheckFormat(*reportFormat); err != nil {
+		log.Println(err)
+		return exitUsage
+	}
+
+	cfg, err := opts.loadConfig()
+	if err != nil {
+		log.Println(err)
+		return exitUsage
+	}
+
+	paths, err := opts.testCasePaths(cfg)
+	if err != nil {
+		log.Println(err)
+		return exitUsage
+	}
+	for _, path := range paths {
+		log.Printf("Loading test case from: %s", path)
+	}
+	testCases, err := loadTestCases(paths, cfg)
+	if err != nil {
+		log.Println(err)
+		return exitUsage
+	}
+
+	tester, err := handler.NewTester(cfg)
+	if err != nil {
+		log.Println(err)
+		return exitUsage
+	}
+	defer tester.Close()
+
+	// Ctrl-C 或到达 -timeout 时取消正在执行的步骤，删除遗留的会话后输出已有的结果
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	if *associate {
+		startedAt := time.Now()
+		if err := tester.Associate(ctx); err != nil {
+			log.Println(err)
+			// 关联失败时不执行测试用例，报告中记录失败的关联步骤
+			if *reportPath != "" {
+				writeReport(*reportPath, *reportFormat, associationFailure(startedAt, err))
+			}
+			return canceledCode(ctx, *timeout, exitFailed)
+		}
+	}
+
+	result, err := tester.Run(ctx, testCases)
+	if err != nil {
+		log.Printf("Test run aborted: %v", err)
+	} else {
+		log.Println("Test cases completed")
+	}
+
+	reportFailed := false
+	if *reportPath != "" {
+		reportFailed = !writeReport(*reportPath, *reportFormat, result)
+	}
+
+	if failed := result.Failed(); failed > 0 {
+		log.Printf("%d of %d test case sets failed", failed, len(result.Sets))
+		return canceledCode(ctx, *timeout, exitFailed)
+	}
+	if td := result.Teardown; td != nil && !td.Clean() {
+		log.Printf("Teardown left %d sessions on UPF", td.Failed+td.Unresolved)
+		return canceledCode(ctx, *timeout, exitFailed)
+	}
+	if reportFailed {
+		return canceledCode(ctx, *timeout, exitFailed)
+	}
+	return canceledCode(ctx, *timeout, exitOK)
+}
+
+// associationFailure 返回记录关联建立失败的测试报告，包含一个失败的 association_setup 测试用例集
+func associationFailure(startedAt time.Time, err error) *report.Report {
+	result := report.New()
+	result.StartTime = startedAt
+	set := result.NewSet(0, "association_setup")
+	set.StartTime = startedAt
+	step := set.BeginStep(1, "association_setup", "send")
+	step.StartTime = startedAt
+	step.Finish(err)
+	set.Finish(err)
+	result.Finish()
+	return result
+}
+
+// writeReport 输出测试报告，返回是否成功
+func writeReport(path, format string, result *report.Report) bool {
+	if err := report.WriteFile(path, format, result); err != nil {
+		log.Printf("Write test report failed: %v", err)
+		return false
+	}
+	log.Printf("Test report written to %s", path)
+	return true
+}
+
+// canceledCode 运行被取消时返回超时或中断的退出码，否则返回 code
+func canceledCode(ctx context.Context, timeout time.Duration, code int) int {
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		log.Printf("Test run timeout after %s", timeout)
+		return exitTimeout
+	case ctx.Err() != nil:
+		log.Println("Test run interrupted")
+		return exitInterrupted
+	default:
+		return code
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"log"
+)
+
+// validateTests 执行 validate 子命令，只加载和校验配置与步骤 YAML，不发送任何消息
+func validateTests(args []string) int {
+	var opts commonOptions
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	opts.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	cfg, err := opts.loadConfig()
+	if err != nil {
+		log.Println(err)
+		return exitUsage
+	}
+	paths, err := opts.testCasePaths(cfg)
+	if err != nil {
+		log.Println(err)
+		return exitUsage
+	}
+
+	invalid := 0
+	for _, path := range paths {
+		if _, err := loadTestCases([]string{path}, cfg); err != nil {
+			log.Printf("INVALID %s: %v", path, err)
+			invalid++
+			continue
+		}
+		log.Printf("OK      %s", path)
+	}
+
+	if invalid > 0 {
+		return exitFailed
+	}
+	return exitOK
+}
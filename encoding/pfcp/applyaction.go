@@ -0,0 +1,118 @@
+package pfcp
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ApplyAction FAR 的 Apply Action 标志位 (3GPP TS 29.244 8.2.26)，
+// YAML 中可写为数值 (如 2)、标志名 (如 "BUFF|NOCP") 或标志名列表 (如 [BUFF, NOCP])
+type ApplyAction uint8
+
+const (
+	ApplyActionDROP ApplyAction = 0x01 // 丢弃
+	ApplyActionFORW ApplyAction = 0x02 // 转发
+	ApplyActionBUFF ApplyAction = 0x04 // 缓存
+	ApplyActionNOCP ApplyAction = 0x08 // 缓存首包时通知 CP
+	ApplyActionDUPL ApplyAction = 0x10 // 复制
+)
+
+var applyActionNames = []struct {
+	name string
+	flag ApplyAction
+}{
+	{"DROP", ApplyActionDROP},
+	{"FORW", ApplyActionFORW},
+	{"BUFF", ApplyActionBUFF},
+	{"NOCP", ApplyActionNOCP},
+	{"DUPL", ApplyActionDUPL},
+}
+
+// Has 判断是否设置了 flag
+func (a ApplyAction) Has(flag ApplyAction) bool {
+	return a&flag != 0
+}
+
+func (a ApplyAction) String() string {
+	var names []string
+	for _, n := range applyActionNames {
+		if a.Has(n.flag) {
+			names = append(names, n.name)
+		}
+	}
+	if len(names) == 0 {
+		return fmt.Sprintf("0x%02x", uint8(a))
+	}
+	return strings.Join(names, "|")
+}
+
+// ParseApplyAction 解析以 | 或 , 分隔的标志名，DROP/FORW/BUFF 只能设置一个，NOCP 需与 BUFF 一起设置
+func ParseApplyAction(s string) (ApplyAction, error) {
+	var action ApplyAction
+	for _, field := range strings.FieldsFunc(s, func(r rune) bool { return r == '|' || r == ',' }) {
+		name := strings.ToUpper(strings.TrimSpace(field))
+		found := false
+		for _, n := range applyActionNames {
+			if n.name == name {
+				action |= n.flag
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("unknown apply action %q", field)
+		}
+	}
+	if action == 0 {
+		return 0, fmt.Errorf("empty apply action")
+	}
+
+	exclusive := 0
+	for _, flag := range []ApplyAction{ApplyActionDROP, ApplyActionFORW, ApplyActionBUFF} {
+		if action.Has(flag) {
+			exclusive++
+		}
+	}
+	if exclusive > 1 {
+		return 0, fmt.Errorf("apply action %s: only one of DROP, FORW and BUFF may be set", action)
+	}
+	if action.Has(ApplyActionNOCP) && !action.Has(ApplyActionBUFF) {
+		return 0, fmt.Errorf("apply action %s: NOCP requires BUFF", action)
+	}
+	return action, nil
+}
+
+// UnmarshalYAML 数值按原样编码 (可用于构造非法组合)，标志名需满足组合规则
+func (a *ApplyAction) UnmarshalYAML(value *yaml.Node) error {
+	switch value.Kind {
+	case yaml.SequenceNode:
+		var names []string
+		if err := value.Decode(&names); err != nil {
+			return err
+		}
+		action, err := ParseApplyAction(strings.Join(names, "|"))
+		if err != nil {
+			return err
+		}
+		*a = action
+		return nil
+
+	case yaml.ScalarNode:
+		var raw uint8
+		if err := value.Decode(&raw); err == nil {
+			*a = ApplyAction(raw)
+			return nil
+		}
+		action, err := ParseApplyAction(value.Value)
+		if err != nil {
+			return err
+		}
+		*a = action
+		return nil
+
+	default:
+		return fmt.Errorf("invalid apply action at line %d", value.Line)
+	}
+}
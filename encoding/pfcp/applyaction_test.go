@@ -0,0 +1,37 @@
+package pfcp
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestApplyAction_UnmarshalYAML(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    ApplyAction
+		wantErr bool
+	}{
+		{"numeric", "applyAction: 2", ApplyActionFORW, false},
+		{"numeric invalid combination kept", "applyAction: 3", ApplyActionDROP | ApplyActionFORW, false},
+		{"names", `applyAction: "BUFF|NOCP"`, ApplyActionBUFF | ApplyActionNOCP, false},
+		{"list", "applyAction: [forw, dupl]", ApplyActionFORW | ApplyActionDUPL, false},
+		{"exclusive", `applyAction: "FORW|BUFF"`, 0, true},
+		{"nocp without buff", "applyAction: NOCP", 0, true},
+		{"unknown", "applyAction: SEND", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var far FAR
+			err := yaml.Unmarshal([]byte(tt.data), &far)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && far.ApplyAction != tt.want {
+				t.Errorf("apply action = %s, want %s", far.ApplyAction, tt.want)
+			}
+		})
+	}
+}
@@ -3,7 +3,6 @@ package pfcp
 import (
 	"log"
 	"os"
-	"upftester/internal/util"
 
 	"github.com/wmnsk/go-pfcp/message"
 	"gopkg.in/yaml.v3"
@@ -30,7 +29,7 @@ func (cfg *DeletionRequestConfig) Marshal(path string) (message.Message, error)
 
 	// Session Deletion Request 通常不包含任何 IE
 	// SEID 会在发送时由 handler 设置到 Header 中
-	return message.NewSessionDeletionRequest(0, 0, 0, util.GlobalSeqNumber.Inc(), 0), nil
+	return message.NewSessionDeletionRequest(0, 0, 0, 0, 0), nil
 }
 
 func (cfg *DeletionRequestConfig) Unmarshal() {
@@ -1,11 +1,10 @@
 package pfcp
 
 import (
+	"fmt"
 	"log"
 	"net"
 	"os"
-	"time"
-	"upftester/internal/util"
 
 	"github.com/wmnsk/go-pfcp/ie"
 	"github.com/wmnsk/go-pfcp/message"
@@ -19,22 +18,53 @@ type EstablishmentRequestConfig struct {
 	CreateFARs *[]FAR  `yaml:"createFars"`
 	CreateURRs *[]URR  `yaml:"createUrrs"`
 	CreateQERs *[]QER  `yaml:"createQers"`
+	CreateBAR  *BAR    `yaml:"createBar"`
 	PDNType    *uint8  `yaml:"pdnType"`
 	ApnDnn     string  `yaml:"apnDnn"`
 	UserID     *UserID `yaml:"userId"`
 }
 
+// Marshal 加载配置并编码，auto 字段编码为占位值，用于加载时校验
 func (cfg *EstablishmentRequestConfig) Marshal(path string) (message.Message, error) {
+	if err := cfg.Load(path); err != nil {
+		return nil, err
+	}
+	return cfg.Encode(nil)
+}
 
+// Load 从文件加载会话建立请求配置
+func (cfg *EstablishmentRequestConfig) Load(path string) error {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		log.Printf("Error reading file %s: %v", path, err)
-		return nil, err
+		return err
 	}
 
-	err = yaml.Unmarshal(data, cfg)
+	err = cfg.Parse(data)
 	if err != nil {
 		log.Printf("Error unmarshalling file %s: %v", path, err)
+		return err
+	}
+	return nil
+}
+
+// Parse 解析会话建立请求配置，用于已经渲染过模板的步骤文件
+func (cfg *EstablishmentRequestConfig) Parse(data []byte) error {
+	return yaml.Unmarshal(data, cfg)
+}
+
+// Encode 从会话的资源集合中分配配置为 auto 的 SEID、UE 地址和 TEID 并回写到配置，然后编码请求。
+// res 为 nil 时不分配，auto 字段编码为占位值。序列号在发送时设置
+func (cfg *EstablishmentRequestConfig) Encode(res Allocator) (message.Message, error) {
+	if cfg.FSEID != nil {
+		if err := resolveSEID(cfg.FSEID, res); err != nil {
+			return nil, err
+		}
+	}
+	if err := resolvePDRs(cfg.CreatePDRs, res); err != nil {
+		return nil, err
+	}
+	if err := resolveFARs(cfg.CreateFARs, res); err != nil {
 		return nil, err
 	}
 
@@ -45,160 +75,42 @@ func (cfg *EstablishmentRequestConfig) Marshal(path string) (message.Message, er
 	}
 
 	if cfg.FSEID != nil {
-		cfg.FSEID.SEID = util.GlobalSeid.Inc()
 		cfg.FSEID.Ipv4Address = cfg.NodeId.Ipv4
 		cfg.FSEID.Ipv6Address = cfg.NodeId.Ipv6
 		ies = append(ies, ie.NewFSEID(cfg.FSEID.SEID, net.ParseIP(cfg.FSEID.Ipv4Address), net.ParseIP(cfg.FSEID.Ipv6Address)))
 	}
 
 	if cfg.CreatePDRs != nil {
-
 		for _, pdr := range *cfg.CreatePDRs {
-
-			var pdiChildren []*ie.IE
-
-			if pdr.PDI.SourceInterface != nil {
-				pdiChildren = append(pdiChildren,
-					ie.NewSourceInterface(*pdr.PDI.SourceInterface))
-			}
-
-			if pdr.PDI.FTEID != nil {
-				pdiChildren = append(pdiChildren,
-					ie.NewFTEID(pdr.PDI.FTEID.Flag, 0, nil, nil, pdr.PDI.FTEID.ChooseId))
-			}
-
-			if pdr.PDI.UEAddress != nil {
-				pdiChildren = append(pdiChildren,
-					ie.NewUEIPAddress(pdr.PDI.UEAddress.Flag, pdr.PDI.UEAddress.Ipv4Address, pdr.PDI.UEAddress.Ipv6Address, 0, 0))
-			}
-
-			if pdr.PDI.SDFFilter != nil {
-				pdiChildren = append(pdiChildren,
-					ie.NewSDFFilter(*pdr.PDI.SDFFilter, "", "", "", 0))
-			}
-
-			if pdr.PDI.InterfaceType3gpp != nil {
-				pdiChildren = append(pdiChildren,
-					ie.NewTGPPInterfaceType(*pdr.PDI.InterfaceType3gpp))
-			}
-
-			pdi := ie.NewPDI(pdiChildren...)
-
-			var pdrChildren []*ie.IE
-
-			pdrChildren = append(pdrChildren, ie.NewPDRID(pdr.PdrId))
-
-			pdrChildren = append(pdrChildren, ie.NewPrecedence(pdr.Precedence))
-
-			pdrChildren = append(pdrChildren, pdi)
-
-			if pdr.OuterHeaderRemoval != nil {
-				pdrChildren = append(pdrChildren,
-					ie.NewOuterHeaderRemoval(pdr.OuterHeaderRemoval.Desc, pdr.OuterHeaderRemoval.Ext))
-			}
-
-			if pdr.FarId != nil {
-				pdrChildren = append(pdrChildren, ie.NewFARID(*pdr.FarId))
-			}
-
-			if pdr.UrrId != nil {
-				pdrChildren = append(pdrChildren, ie.NewURRID(*pdr.UrrId))
-			}
-
-			if pdr.QerIds != nil {
-				for _, qid := range *pdr.QerIds {
-					pdrChildren = append(pdrChildren, ie.NewQERID(qid))
-				}
-			}
-
-			ies = append(ies, ie.NewCreatePDR(pdrChildren...))
+			ies = append(ies, ie.NewCreatePDR(pdrChildren(pdr, false)...))
 		}
-
 	}
 
 	if cfg.CreateFARs != nil {
-		for _, far := range *cfg.CreateFARs {
-
-			farId := ie.NewFARID(far.FarId)
-
-			apply := ie.NewApplyAction(far.ApplyAction)
-
-			fp := ie.NewForwardingParameters(
-				ie.NewDestinationInterface(far.ForwardingParameters.DestinationInterface),
-				ie.NewTGPPInterfaceType(far.ForwardingParameters.InterfaceType3gpp),
-			)
-
-			if far.ForwardingParameters.OuterHeaderCreation != nil {
-
-				far.ForwardingParameters.OuterHeaderCreation.TEID = util.GlobalSeqNumber.Inc()
-
-				fp.Add(ie.NewOuterHeaderCreation(
-					far.ForwardingParameters.OuterHeaderCreation.OuterHeaderCreationDescription,
-					far.ForwardingParameters.OuterHeaderCreation.TEID,
-					far.ForwardingParameters.OuterHeaderCreation.IPv4Address,
-					far.ForwardingParameters.OuterHeaderCreation.IPv6Address,
-					far.ForwardingParameters.OuterHeaderCreation.PortNumber,
-					far.ForwardingParameters.OuterHeaderCreation.CTag,
-					far.ForwardingParameters.OuterHeaderCreation.STag,
-				))
-			}
-
-			ies = append(ies, ie.NewCreateFAR(farId, apply, fp))
+		for i := range *cfg.CreateFARs {
+			ies = append(ies, ie.NewCreateFAR(farChildren(&(*cfg.CreateFARs)[i], false)...))
 		}
 	}
 
 	if cfg.CreateURRs != nil {
 		for _, urr := range *cfg.CreateURRs {
-
-			var urrChildren []*ie.IE
-
-			urrChildren = append(urrChildren, ie.NewURRID(urr.UrrId))
-
-			if urr.MeasureMethod != nil {
-				urrChildren = append(urrChildren,
-					ie.NewMeasurementMethod(urr.MeasureMethod.Event, urr.MeasureMethod.Volum, urr.MeasureMethod.Duration))
-			}
-
-			if urr.ReportTriggers != nil {
-				urrChildren = append(urrChildren,
-					ie.NewReportingTriggers(urr.ReportTriggers.Octet1, urr.ReportTriggers.Octet2, urr.ReportTriggers.Octet3))
-			}
-
-			if urr.VolumeThreshold != nil {
-				urrChildren = append(urrChildren,
-					ie.NewVolumeThreshold(urr.VolumeThreshold.Flag, urr.VolumeThreshold.Tovol, urr.VolumeThreshold.Ulvol, urr.VolumeThreshold.Dlvol))
-			}
-
-			if urr.VolumeQuota != nil {
-				urrChildren = append(urrChildren,
-					ie.NewVolumeQuota(urr.VolumeQuota.Flag, urr.VolumeQuota.Tovol, urr.VolumeQuota.Ulvol, urr.VolumeThreshold.Dlvol))
-			}
-
-			if urr.TimeThreshold != nil {
-				urrChildren = append(urrChildren,
-					ie.NewTimeThreshold(time.Duration(urr.TimeThreshold.Duration)*time.Second))
-			}
-
-			if urr.TimeQuota != nil {
-				urrChildren = append(urrChildren,
-					ie.NewTimeQuota(time.Duration(urr.TimeQuota.Duration)*time.Second))
-			}
-
-			ies = append(ies,
-				ie.NewCreateURR(urrChildren...))
+			ies = append(ies, ie.NewCreateURR(urrChildren(urr)...))
 		}
 	}
 
 	if cfg.CreateQERs != nil {
 		for _, q := range *cfg.CreateQERs {
-			qerID := ie.NewQERID(*q.QerId)
-			gate := ie.NewGateStatus((*q.GateStatus).UL, (*q.GateStatus).DL)
-			mbr := ie.NewMBR(*q.MBR.UL, *q.MBR.DL)
-			createQER := ie.NewCreateQER(qerID, gate, mbr)
-			ies = append(ies, createQER)
+			if q.QerId == nil {
+				return nil, fmt.Errorf("create qer: qerId is required")
+			}
+			ies = append(ies, ie.NewCreateQER(qerChildren(q)...))
 		}
 	}
 
+	if cfg.CreateBAR != nil {
+		ies = append(ies, ie.NewCreateBAR(barChildren(*cfg.CreateBAR)...))
+	}
+
 	if cfg.PDNType != nil {
 		ies = append(ies, ie.NewPDNType(*cfg.PDNType))
 	}
@@ -211,7 +123,7 @@ func (cfg *EstablishmentRequestConfig) Marshal(path string) (message.Message, er
 		ies = append(ies, ie.NewUserID(cfg.UserID.Flag, cfg.UserID.IMSI, "", "", ""))
 	}
 
-	return message.NewSessionEstablishmentRequest(0, 0, 0, util.GlobalSeqNumber.Inc(), 0, ies...), nil
+	return message.NewSessionEstablishmentRequest(0, 0, 0, 0, 0, ies...), nil
 }
 
 func (cfg *EstablishmentRequestConfig) Unmarshal() {
@@ -1,9 +1,9 @@
 package pfcp
 
 import (
+	"fmt"
 	"log"
 	"os"
-	"upftester/internal/util"
 
 	"github.com/wmnsk/go-pfcp/ie"
 	"github.com/wmnsk/go-pfcp/message"
@@ -11,58 +11,178 @@ import (
 )
 
 type ModificationRequestConfig struct {
-	FarId         *uint32               `yaml:"farId"`
-	ApplyAction   *uint8                `yaml:"applyAction"`
-	UpdateFar     *ForwardingParameters `yaml:"forwardingParameters"`
-	PfcpSmReqFlag *uint8                `yaml:"pfcpSmReqFlag"`
+	// 单个 FAR 的更新，编码为一个 Update FAR (兼容旧的用例格式)
+	FarId       *uint32               `yaml:"farId"`
+	ApplyAction *ApplyAction          `yaml:"applyAction"`
+	UpdateFar   *ForwardingParameters `yaml:"forwardingParameters"`
+
+	CreatePDRs *[]PDR    `yaml:"createPdrs"`
+	UpdatePDRs *[]PDR    `yaml:"updatePdrs"`
+	RemovePDRs *[]uint16 `yaml:"removePdrs"`
+
+	CreateFARs *[]FAR    `yaml:"createFars"`
+	UpdateFARs *[]FAR    `yaml:"updateFars"`
+	RemoveFARs *[]uint32 `yaml:"removeFars"`
+
+	CreateURRs *[]URR    `yaml:"createUrrs"`
+	UpdateURRs *[]URR    `yaml:"updateUrrs"`
+	RemoveURRs *[]uint32 `yaml:"removeUrrs"`
+	QueryURRs  *[]uint32 `yaml:"queryUrrs"`
+
+	CreateQERs *[]QER    `yaml:"createQers"`
+	UpdateQERs *[]QER    `yaml:"updateQers"`
+	RemoveQERs *[]uint32 `yaml:"removeQers"`
+
+	CreateBAR *BAR   `yaml:"createBar"`
+	UpdateBAR *BAR   `yaml:"updateBar"`
+	RemoveBAR *uint8 `yaml:"removeBar"`
+
+	PfcpSmReqFlag *uint8 `yaml:"pfcpSmReqFlag"`
 }
 
+// Marshal 加载配置并编码，auto 字段编码为占位值，用于加载时校验
 func (cfg *ModificationRequestConfig) Marshal(path string) (message.Message, error) {
+	if err := cfg.Load(path); err != nil {
+		return nil, err
+	}
+	return cfg.Encode(nil)
+}
 
+// Load 从文件加载会话修改请求配置
+func (cfg *ModificationRequestConfig) Load(path string) error {
 	log.Printf("marshal session modification request config from path: %s", path)
 
 	data, err := os.ReadFile(path)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	err = yaml.Unmarshal(data, cfg)
-	if err != nil {
-		return nil, err
+	return cfg.Parse(data)
+}
+
+// Parse 解析会话修改请求配置，用于已经渲染过模板的步骤文件
+func (cfg *ModificationRequestConfig) Parse(data []byte) error {
+	return yaml.Unmarshal(data, cfg)
+}
+
+// Encode 从会话的资源集合中分配配置为 auto 的 UE 地址 (沿用会话已分配的地址) 和 TEID 并回写到配置，然后编码请求。
+// res 为 nil 时不分配，auto 字段编码为占位值。序列号在发送时设置
+func (cfg *ModificationRequestConfig) Encode(res Allocator) (message.Message, error) {
+	for _, pdrs := range []*[]PDR{cfg.CreatePDRs, cfg.UpdatePDRs} {
+		if err := resolvePDRs(pdrs, res); err != nil {
+			return nil, err
+		}
+	}
+	for _, fars := range []*[]FAR{cfg.CreateFARs, cfg.UpdateFARs} {
+		if err := resolveFARs(fars, res); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.UpdateFar != nil {
+		if err := resolveTEID(cfg.UpdateFar.OuterHeaderCreation, res); err != nil {
+			return nil, err
+		}
 	}
 
 	var ies []*ie.IE
 
-	if cfg.FarId != nil {
-		ies = append(ies, ie.NewFARID(*cfg.FarId))
+	// PDR
+	if cfg.CreatePDRs != nil {
+		for _, pdr := range *cfg.CreatePDRs {
+			ies = append(ies, ie.NewCreatePDR(pdrChildren(pdr, false)...))
+		}
 	}
-
-	if cfg.ApplyAction != nil {
-		ies = append(ies, ie.NewApplyAction(*cfg.ApplyAction))
+	if cfg.UpdatePDRs != nil {
+		for _, pdr := range *cfg.UpdatePDRs {
+			ies = append(ies, ie.NewUpdatePDR(pdrChildren(pdr, true)...))
+		}
+	}
+	if cfg.RemovePDRs != nil {
+		for _, id := range *cfg.RemovePDRs {
+			ies = append(ies, ie.NewRemovePDR(ie.NewPDRID(id)))
+		}
 	}
 
-	if cfg.UpdateFar != nil {
-		fp := ie.NewForwardingParameters(
-			ie.NewDestinationInterface(cfg.UpdateFar.DestinationInterface),
-			ie.NewTGPPInterfaceType(cfg.UpdateFar.InterfaceType3gpp),
-		)
-
-		if cfg.UpdateFar.OuterHeaderCreation != nil {
+	// FAR
+	if cfg.CreateFARs != nil {
+		for i := range *cfg.CreateFARs {
+			ies = append(ies, ie.NewCreateFAR(farChildren(&(*cfg.CreateFARs)[i], false)...))
+		}
+	}
+	if cfg.FarId != nil {
+		far := FAR{FarId: *cfg.FarId, ForwardingParameters: cfg.UpdateFar}
+		if cfg.ApplyAction != nil {
+			far.ApplyAction = *cfg.ApplyAction
+		}
+		ies = append(ies, ie.NewUpdateFAR(farChildren(&far, true)...))
+	} else if cfg.ApplyAction != nil || cfg.UpdateFar != nil {
+		return nil, fmt.Errorf("update far: farId is required")
+	}
+	if cfg.UpdateFARs != nil {
+		for i := range *cfg.UpdateFARs {
+			ies = append(ies, ie.NewUpdateFAR(farChildren(&(*cfg.UpdateFARs)[i], true)...))
+		}
+	}
+	if cfg.RemoveFARs != nil {
+		for _, id := range *cfg.RemoveFARs {
+			ies = append(ies, ie.NewRemoveFAR(ie.NewFARID(id)))
+		}
+	}
 
-			cfg.UpdateFar.OuterHeaderCreation.TEID = util.GlobalSeqNumber.Inc()
+	// URR
+	if cfg.CreateURRs != nil {
+		for _, urr := range *cfg.CreateURRs {
+			ies = append(ies, ie.NewCreateURR(urrChildren(urr)...))
+		}
+	}
+	if cfg.UpdateURRs != nil {
+		for _, urr := range *cfg.UpdateURRs {
+			ies = append(ies, ie.NewUpdateURR(urrChildren(urr)...))
+		}
+	}
+	if cfg.RemoveURRs != nil {
+		for _, id := range *cfg.RemoveURRs {
+			ies = append(ies, ie.NewRemoveURR(ie.NewURRID(id)))
+		}
+	}
+	if cfg.QueryURRs != nil {
+		for _, id := range *cfg.QueryURRs {
+			ies = append(ies, ie.NewQueryURR(ie.NewURRID(id)))
+		}
+	}
 
-			fp.Add(ie.NewOuterHeaderCreation(
-				cfg.UpdateFar.OuterHeaderCreation.OuterHeaderCreationDescription,
-				cfg.UpdateFar.OuterHeaderCreation.TEID,
-				cfg.UpdateFar.OuterHeaderCreation.IPv4Address,
-				cfg.UpdateFar.OuterHeaderCreation.IPv6Address,
-				cfg.UpdateFar.OuterHeaderCreation.PortNumber,
-				cfg.UpdateFar.OuterHeaderCreation.CTag,
-				cfg.UpdateFar.OuterHeaderCreation.STag,
-			))
+	// QER
+	if cfg.CreateQERs != nil {
+		for _, q := range *cfg.CreateQERs {
+			if q.QerId == nil {
+				return nil, fmt.Errorf("create qer: qerId is required")
+			}
+			ies = append(ies, ie.NewCreateQER(qerChildren(q)...))
 		}
+	}
+	if cfg.UpdateQERs != nil {
+		for _, q := range *cfg.UpdateQERs {
+			if q.QerId == nil {
+				return nil, fmt.Errorf("update qer: qerId is required")
+			}
+			ies = append(ies, ie.NewUpdateQER(qerChildren(q)...))
+		}
+	}
+	if cfg.RemoveQERs != nil {
+		for _, id := range *cfg.RemoveQERs {
+			ies = append(ies, ie.NewRemoveQER(ie.NewQERID(id)))
+		}
+	}
 
-		ies = append(ies, fp)
+	// BAR
+	if cfg.CreateBAR != nil {
+		ies = append(ies, ie.NewCreateBAR(barChildren(*cfg.CreateBAR)...))
+	}
+	if cfg.UpdateBAR != nil {
+		ies = append(ies, ie.NewUpdateBARWithinSessionModificationRequest(barChildren(*cfg.UpdateBAR)...))
+	}
+	if cfg.RemoveBAR != nil {
+		ies = append(ies, ie.NewRemoveBAR(ie.NewBARID(*cfg.RemoveBAR)))
 	}
 
 	if cfg.PfcpSmReqFlag != nil {
@@ -70,7 +190,31 @@ func (cfg *ModificationRequestConfig) Marshal(path string) (message.Message, err
 		ies = append(ies, ie.NewPFCPSMReqFlags(*cfg.PfcpSmReqFlag))
 	}
 
-	return message.NewSessionModificationRequest(0, 0, 0, util.GlobalSeqNumber.Inc(), 0, ies...), nil
+	return message.NewSessionModificationRequest(0, 0, 0, 0, 0, ies...), nil
+}
+
+// DownlinkFARs 返回本次修改中新建或更新的、目的接口为 Access 且带 Outer Header Creation 的 FAR
+func (cfg *ModificationRequestConfig) DownlinkFARs() []*FAR {
+	var candidates []*FAR
+	if cfg.FarId != nil && cfg.UpdateFar != nil {
+		candidates = append(candidates, &FAR{FarId: *cfg.FarId, ForwardingParameters: cfg.UpdateFar})
+	}
+	for _, list := range []*[]FAR{cfg.CreateFARs, cfg.UpdateFARs} {
+		if list != nil {
+			for i := range *list {
+				candidates = append(candidates, &(*list)[i])
+			}
+		}
+	}
+
+	var fars []*FAR
+	for _, far := range candidates {
+		fp := far.ForwardingParameters
+		if fp != nil && fp.DestinationInterface == 0 && fp.OuterHeaderCreation != nil {
+			fars = append(fars, far)
+		}
+	}
+	return fars
 }
 
 func (cfg *ModificationRequestConfig) Unmarshal() {
@@ -0,0 +1,126 @@
+package pfcp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+func TestModificationRequestConfig_Marshal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "modification.yaml")
+	data := `
+farId: 1
+applyAction: 2
+forwardingParameters:
+  destinationInterface: 1
+createPdrs:
+  - pdrId: 3
+    precedence: 20
+    pdi:
+      sourceInterface: 1
+    farId: 3
+updatePdrs:
+  - pdrId: 2
+    farId: 3
+removePdrs: [4]
+createFars:
+  - farId: 3
+    applyAction: 2
+    forwardingParameters:
+      destinationInterface: 0
+      outerHeaderCreation:
+        outerHeaderCreationDescription: 0x0100
+        ipv4Address: "127.0.0.1"
+updateFars:
+  - farId: 2
+    duplicatingParameters:
+      destinationInterface: 4
+removeFars: [2]
+createUrrs:
+  - urrId: 2
+updateUrrs:
+  - urrId: 1
+    volumeQuota:
+      flag: 2
+      ulvol: 1000
+removeUrrs: [3]
+queryUrrs: [1, 2]
+createQers:
+  - qerId: 2
+    gateStatus: {ul: 0, dl: 0}
+updateQers:
+  - qerId: 1
+    gateStatus: {ul: 1, dl: 1}
+removeQers: [3]
+createBar:
+  barId: 1
+  suggestedBufferingPacketsCount: 10
+`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := new(ModificationRequestConfig)
+	if err := cfg.Load(path); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	msg, err := cfg.Encode(&testAllocator{nextTEID: 100})
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	b := make([]byte, msg.MarshalLen())
+	if err := msg.MarshalTo(b); err != nil {
+		t.Fatalf("MarshalTo() error = %v", err)
+	}
+	req, err := message.ParseSessionModificationRequest(b)
+	if err != nil {
+		t.Fatalf("ParseSessionModificationRequest() error = %v", err)
+	}
+
+	counts := []struct {
+		name string
+		got  int
+		want int
+	}{
+		{"CreatePDR", len(req.CreatePDR), 1},
+		{"UpdatePDR", len(req.UpdatePDR), 1},
+		{"RemovePDR", len(req.RemovePDR), 1},
+		{"CreateFAR", len(req.CreateFAR), 1},
+		{"UpdateFAR", len(req.UpdateFAR), 2},
+		{"RemoveFAR", len(req.RemoveFAR), 1},
+		{"CreateURR", len(req.CreateURR), 1},
+		{"UpdateURR", len(req.UpdateURR), 1},
+		{"RemoveURR", len(req.RemoveURR), 1},
+		{"QueryURR", len(req.QueryURR), 2},
+		{"CreateQER", len(req.CreateQER), 1},
+		{"UpdateQER", len(req.UpdateQER), 1},
+		{"RemoveQER", len(req.RemoveQER), 1},
+	}
+	for _, c := range counts {
+		if c.got != c.want {
+			t.Errorf("%s count = %d, want %d", c.name, c.got, c.want)
+		}
+	}
+	if req.CreateBAR == nil {
+		t.Error("expect Create BAR")
+	}
+
+	// 旧格式的 farId/applyAction/forwardingParameters 编码为 Update FAR
+	if id, err := req.UpdateFAR[0].FARID(); err != nil || id != 1 {
+		t.Errorf("expect first Update FAR for FAR 1, got %d (%v)", id, err)
+	}
+	if _, err := req.UpdateFAR[0].FindByType(ie.UpdateForwardingParameters); err != nil {
+		t.Errorf("expect Update Forwarding Parameters in Update FAR: %v", err)
+	}
+	if _, err := req.UpdateFAR[1].FindByType(ie.UpdateDuplicatingParameters); err != nil {
+		t.Errorf("expect Update Duplicating Parameters in Update FAR: %v", err)
+	}
+
+	if fars := cfg.DownlinkFARs(); len(fars) != 1 || fars[0].FarId != 3 || fars[0].ForwardingParameters.OuterHeaderCreation.TEID != 100 {
+		t.Errorf("expect FAR 3 as the only downlink FAR with assigned TEID, got %+v", fars)
+	}
+}
@@ -0,0 +1,147 @@
+package pfcp
+
+import (
+	"net"
+
+	"gopkg.in/yaml.v3"
+)
+
+// auto 步骤 YAML 中表示由资源池分配的取值
+const auto = "auto"
+
+// Allocator 为步骤 YAML 中 auto 的字段分配会话资源，同一会话内多次分配的 SEID 和 UE 地址相同
+type Allocator interface {
+	SEID() (uint64, error)
+	UEIPv4() (net.IP, error)
+	UEIPv6() (net.IP, error)
+	AllocateTEID() (uint32, error)
+}
+
+// markAuto 检查映射节点中 key 的取值，为 auto 或未配置时返回 true，并将 auto 替换为 0 以便解码。
+// 显式配置的数值 (包括 0) 原样发送
+func markAuto(node *yaml.Node, key string) bool {
+	if node.Kind != yaml.MappingNode {
+		return false
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		k, v := node.Content[i], node.Content[i+1]
+		if k.Value != key {
+			continue
+		}
+		if v.Kind == yaml.ScalarNode && v.Value == auto {
+			v.Value, v.Tag = "0", "!!int"
+			return true
+		}
+		return false
+	}
+	return true
+}
+
+// UnmarshalYAML 支持 seid: auto
+func (f *FSEID) UnmarshalYAML(value *yaml.Node) error {
+	isAuto := markAuto(value, "seid")
+	type plain FSEID
+	if err := value.Decode((*plain)(f)); err != nil {
+		return err
+	}
+	f.autoSEID = isAuto
+	return nil
+}
+
+// UnmarshalYAML 支持 teid: auto
+func (o *OuterHeaderCreation) UnmarshalYAML(value *yaml.Node) error {
+	isAuto := markAuto(value, "teid")
+	type plain OuterHeaderCreation
+	if err := value.Decode((*plain)(o)); err != nil {
+		return err
+	}
+	o.autoTEID = isAuto
+	return nil
+}
+
+// resolveSEID 为 auto 的 SEID 分配会话的 SEID，res 为 nil 时保持占位值 0
+func resolveSEID(f *FSEID, res Allocator) error {
+	if !f.autoSEID || res == nil {
+		return nil
+	}
+	seid, err := res.SEID()
+	if err != nil {
+		return err
+	}
+	f.SEID, f.autoSEID = seid, false
+	return nil
+}
+
+// resolveUEAddress 将 auto 的 UE 地址替换为会话的 UE 地址，res 为 nil 时替换为全零占位地址
+func resolveUEAddress(ue *UEAddress, res Allocator) error {
+	if ue.Ipv4Address == auto {
+		ue.Ipv4Address = "0.0.0.0"
+		if res != nil {
+			ip, err := res.UEIPv4()
+			if err != nil {
+				return err
+			}
+			ue.Ipv4Address = ip.String()
+		}
+	}
+	if ue.Ipv6Address == auto {
+		ue.Ipv6Address = "::"
+		if res != nil {
+			ip, err := res.UEIPv6()
+			if err != nil {
+				return err
+			}
+			ue.Ipv6Address = ip.String()
+		}
+	}
+	return nil
+}
+
+// resolveTEID 为 auto 的 Outer Header Creation TEID 分配新的本端 TEID，res 为 nil 时保持占位值 0
+func resolveTEID(ohc *OuterHeaderCreation, res Allocator) error {
+	if ohc == nil || !ohc.autoTEID || res == nil {
+		return nil
+	}
+	teid, err := res.AllocateTEID()
+	if err != nil {
+		return err
+	}
+	ohc.TEID, ohc.autoTEID = teid, false
+	return nil
+}
+
+// resolvePDRs 填写 PDR 中 auto 的 UE 地址
+func resolvePDRs(pdrs *[]PDR, res Allocator) error {
+	if pdrs == nil {
+		return nil
+	}
+	for i := range *pdrs {
+		if ue := (*pdrs)[i].PDI.UEAddress; ue != nil {
+			if err := resolveUEAddress(ue, res); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// resolveFARs 填写 FAR 转发参数和复制参数中 auto 的 TEID
+func resolveFARs(fars *[]FAR, res Allocator) error {
+	if fars == nil {
+		return nil
+	}
+	for i := range *fars {
+		far := &(*fars)[i]
+		if fp := far.ForwardingParameters; fp != nil {
+			if err := resolveTEID(fp.OuterHeaderCreation, res); err != nil {
+				return err
+			}
+		}
+		if dp := far.DuplicatingParameters; dp != nil {
+			if err := resolveTEID(dp.OuterHeaderCreation, res); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
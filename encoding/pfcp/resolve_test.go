@@ -0,0 +1,172 @@
+package pfcp
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+// testAllocator 按会话返回固定的 SEID 和 UE 地址，TEID 从 nextTEID 递增分配
+type testAllocator struct {
+	seid     uint64
+	ueIPv4   net.IP
+	ueIPv6   net.IP
+	nextTEID uint32
+	seids    int
+	teids    int
+}
+
+func (a *testAllocator) SEID() (uint64, error) {
+	a.seids++
+	return a.seid, nil
+}
+
+func (a *testAllocator) UEIPv4() (net.IP, error) { return a.ueIPv4, nil }
+
+func (a *testAllocator) UEIPv6() (net.IP, error) { return a.ueIPv6, nil }
+
+func (a *testAllocator) AllocateTEID() (uint32, error) {
+	a.teids++
+	a.nextTEID++
+	return a.nextTEID - 1, nil
+}
+
+func TestEstablishmentRequestConfig_EncodeAuto(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "establishment.yaml")
+	data := `
+nodeId:
+  ipv4: "127.0.0.1"
+fseid:
+  seid: auto
+createPdrs:
+  - pdrId: 1
+    pdi:
+      sourceInterface: 0
+      ueAddress:
+        flag: 2
+        ipv4Address: auto
+  - pdrId: 2
+    pdi:
+      sourceInterface: 1
+      ueAddress:
+        flag: 7
+        ipv4Address: auto
+        ipv6Address: auto
+createFars:
+  - farId: 2
+    applyAction: FORW
+    forwardingParameters:
+      destinationInterface: 0
+      outerHeaderCreation:
+        outerHeaderCreationDescription: 0x0100
+        teid: auto
+        ipv4Address: "127.0.0.1"
+  - farId: 3
+    applyAction: FORW
+    forwardingParameters:
+      destinationInterface: 0
+      outerHeaderCreation:
+        outerHeaderCreationDescription: 0x0100
+        teid: 7
+        ipv4Address: "127.0.0.1"
+`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// 加载时校验不分配资源
+	if _, err := new(EstablishmentRequestConfig).Marshal(path); err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	res := &testAllocator{seid: 10, ueIPv4: net.ParseIP("10.250.0.1"), ueIPv6: net.ParseIP("2001:db8::1"), nextTEID: 20}
+	cfg := new(EstablishmentRequestConfig)
+	if err := cfg.Load(path); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	msg, err := cfg.Encode(res)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	if cfg.FSEID.SEID != 10 {
+		t.Errorf("SEID = %d, want 10", cfg.FSEID.SEID)
+	}
+	pdrs := *cfg.CreatePDRs
+	if pdrs[0].PDI.UEAddress.Ipv4Address != "10.250.0.1" || pdrs[1].PDI.UEAddress.Ipv4Address != "10.250.0.1" {
+		t.Errorf("expect both PDRs to share UE IPv4 10.250.0.1, got %s and %s",
+			pdrs[0].PDI.UEAddress.Ipv4Address, pdrs[1].PDI.UEAddress.Ipv4Address)
+	}
+	if got := pdrs[1].PDI.UEAddress.Ipv6Address; got != "2001:db8::1" {
+		t.Errorf("UE IPv6 = %s, want 2001:db8::1", got)
+	}
+	fars := *cfg.CreateFARs
+	if fars[0].ForwardingParameters.OuterHeaderCreation.TEID != 20 || fars[1].ForwardingParameters.OuterHeaderCreation.TEID != 7 {
+		t.Errorf("expect auto TEID 20 and explicit TEID 7, got %d and %d",
+			fars[0].ForwardingParameters.OuterHeaderCreation.TEID, fars[1].ForwardingParameters.OuterHeaderCreation.TEID)
+	}
+
+	b := make([]byte, msg.MarshalLen())
+	if err := msg.MarshalTo(b); err != nil {
+		t.Fatalf("MarshalTo() error = %v", err)
+	}
+	req, err := message.ParseSessionEstablishmentRequest(b)
+	if err != nil {
+		t.Fatalf("ParseSessionEstablishmentRequest() error = %v", err)
+	}
+	if fseid, err := req.CPFSEID.FSEID(); err != nil || fseid.SEID != 10 {
+		t.Errorf("encoded F-SEID = %+v (%v)", fseid, err)
+	}
+
+	if res.seids != 1 || res.teids != 1 {
+		t.Errorf("expect 1 SEID and 1 TEID allocated, got %d and %d", res.seids, res.teids)
+	}
+}
+
+func TestEstablishmentRequestConfig_EncodeExplicitZero(t *testing.T) {
+	cfg := new(EstablishmentRequestConfig)
+	data := `
+nodeId:
+  ipv4: "127.0.0.1"
+fseid:
+  seid: 0
+createFars:
+  - farId: 1
+    applyAction: FORW
+    forwardingParameters:
+      destinationInterface: 0
+      outerHeaderCreation:
+        outerHeaderCreationDescription: 0x0100
+        teid: 0
+        ipv4Address: "127.0.0.1"
+  - farId: 2
+    applyAction: FORW
+    forwardingParameters:
+      destinationInterface: 0
+      outerHeaderCreation:
+        outerHeaderCreationDescription: 0x0100
+        ipv4Address: "127.0.0.1"
+`
+	if err := cfg.Parse([]byte(data)); err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	res := &testAllocator{seid: 10, nextTEID: 20}
+	if _, err := cfg.Encode(res); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	if cfg.FSEID.SEID != 0 || res.seids != 0 {
+		t.Errorf("expect explicit seid 0 sent unchanged, got %d with %d allocations", cfg.FSEID.SEID, res.seids)
+	}
+	fars := *cfg.CreateFARs
+	if got := fars[0].ForwardingParameters.OuterHeaderCreation.TEID; got != 0 {
+		t.Errorf("expect explicit teid 0 sent unchanged, got %d", got)
+	}
+	if got := fars[1].ForwardingParameters.OuterHeaderCreation.TEID; got != 20 || res.teids != 1 {
+		t.Errorf("expect omitted teid allocated as 20, got %d with %d allocations", got, res.teids)
+	}
+}
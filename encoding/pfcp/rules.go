@@ -0,0 +1,202 @@
+package pfcp
+
+import (
+	"time"
+
+	"github.com/wmnsk/go-pfcp/ie"
+)
+
+// 会话建立和会话修改共用的 PDR/FAR/URR/QER/BAR 编码。
+// update 为 true 时构造 Update 类 IE 的子 IE，未配置的可选字段不携带。
+
+func newPDI(pdi PDI) *ie.IE {
+	var children []*ie.IE
+
+	if pdi.SourceInterface != nil {
+		children = append(children, ie.NewSourceInterface(*pdi.SourceInterface))
+	}
+
+	if pdi.FTEID != nil {
+		children = append(children, ie.NewFTEID(pdi.FTEID.Flag, 0, nil, nil, pdi.FTEID.ChooseId))
+	}
+
+	if pdi.UEAddress != nil {
+		children = append(children,
+			ie.NewUEIPAddress(pdi.UEAddress.Flag, pdi.UEAddress.Ipv4Address, pdi.UEAddress.Ipv6Address, 0, 0))
+	}
+
+	if pdi.SDFFilter != nil {
+		children = append(children, ie.NewSDFFilter(*pdi.SDFFilter, "", "", "", 0))
+	}
+
+	if pdi.InterfaceType3gpp != nil {
+		children = append(children, ie.NewTGPPInterfaceType(*pdi.InterfaceType3gpp))
+	}
+
+	if len(children) == 0 {
+		return nil
+	}
+	return ie.NewPDI(children...)
+}
+
+func pdrChildren(pdr PDR, update bool) []*ie.IE {
+	children := []*ie.IE{ie.NewPDRID(pdr.PdrId)}
+
+	if !update || pdr.Precedence != 0 {
+		children = append(children, ie.NewPrecedence(pdr.Precedence))
+	}
+
+	if pdi := newPDI(pdr.PDI); pdi != nil {
+		children = append(children, pdi)
+	} else if !update {
+		children = append(children, ie.NewPDI())
+	}
+
+	if pdr.OuterHeaderRemoval != nil {
+		children = append(children,
+			ie.NewOuterHeaderRemoval(pdr.OuterHeaderRemoval.Desc, pdr.OuterHeaderRemoval.Ext))
+	}
+
+	if pdr.FarId != nil {
+		children = append(children, ie.NewFARID(*pdr.FarId))
+	}
+
+	if pdr.UrrId != nil {
+		children = append(children, ie.NewURRID(*pdr.UrrId))
+	}
+
+	if pdr.QerIds != nil {
+		for _, qid := range *pdr.QerIds {
+			children = append(children, ie.NewQERID(qid))
+		}
+	}
+
+	return children
+}
+
+// newOuterHeaderCreation 构造 Outer Header Creation，auto 的 TEID 已在编码前由资源池分配
+func newOuterHeaderCreation(ohc *OuterHeaderCreation) *ie.IE {
+	return ie.NewOuterHeaderCreation(
+		ohc.OuterHeaderCreationDescription,
+		ohc.TEID,
+		ohc.IPv4Address,
+		ohc.IPv6Address,
+		ohc.PortNumber,
+		ohc.CTag,
+		ohc.STag,
+	)
+}
+
+func newForwardingParameters(fp *ForwardingParameters, update bool) *ie.IE {
+	children := []*ie.IE{
+		ie.NewDestinationInterface(fp.DestinationInterface),
+		ie.NewTGPPInterfaceType(fp.InterfaceType3gpp),
+	}
+
+	if fp.OuterHeaderCreation != nil {
+		children = append(children, newOuterHeaderCreation(fp.OuterHeaderCreation))
+	}
+
+	if update {
+		return ie.NewUpdateForwardingParameters(children...)
+	}
+	return ie.NewForwardingParameters(children...)
+}
+
+func newDuplicatingParameters(dp *DuplicatingParameters, update bool) *ie.IE {
+	children := []*ie.IE{ie.NewDestinationInterface(dp.DestinationInterface)}
+
+	if dp.OuterHeaderCreation != nil {
+		children = append(children, newOuterHeaderCreation(dp.OuterHeaderCreation))
+	}
+
+	if update {
+		return ie.NewUpdateDuplicatingParameters(children...)
+	}
+	return ie.NewDuplicatingParameters(children...)
+}
+
+func farChildren(far *FAR, update bool) []*ie.IE {
+	children := []*ie.IE{ie.NewFARID(far.FarId)}
+
+	if !update || far.ApplyAction != 0 {
+		children = append(children, ie.NewApplyAction(uint8(far.ApplyAction)))
+	}
+
+	if far.ForwardingParameters != nil {
+		children = append(children, newForwardingParameters(far.ForwardingParameters, update))
+	}
+
+	if far.DuplicatingParameters != nil {
+		children = append(children, newDuplicatingParameters(far.DuplicatingParameters, update))
+	}
+
+	if far.BarId != nil {
+		children = append(children, ie.NewBARID(*far.BarId))
+	}
+
+	return children
+}
+
+func urrChildren(urr URR) []*ie.IE {
+	children := []*ie.IE{ie.NewURRID(urr.UrrId)}
+
+	if urr.MeasureMethod != nil {
+		children = append(children,
+			ie.NewMeasurementMethod(urr.MeasureMethod.Event, urr.MeasureMethod.Volum, urr.MeasureMethod.Duration))
+	}
+
+	if urr.ReportTriggers != nil {
+		children = append(children,
+			ie.NewReportingTriggers(urr.ReportTriggers.Octet1, urr.ReportTriggers.Octet2, urr.ReportTriggers.Octet3))
+	}
+
+	if urr.VolumeThreshold != nil {
+		children = append(children,
+			ie.NewVolumeThreshold(urr.VolumeThreshold.Flag, urr.VolumeThreshold.Tovol, urr.VolumeThreshold.Ulvol, urr.VolumeThreshold.Dlvol))
+	}
+
+	if urr.VolumeQuota != nil {
+		children = append(children,
+			ie.NewVolumeQuota(urr.VolumeQuota.Flag, urr.VolumeQuota.Tovol, urr.VolumeQuota.Ulvol, urr.VolumeQuota.Dlvol))
+	}
+
+	if urr.TimeThreshold != nil {
+		children = append(children,
+			ie.NewTimeThreshold(time.Duration(urr.TimeThreshold.Duration)*time.Second))
+	}
+
+	if urr.TimeQuota != nil {
+		children = append(children,
+			ie.NewTimeQuota(time.Duration(urr.TimeQuota.Duration)*time.Second))
+	}
+
+	return children
+}
+
+// qerChildren 构造 QER 的子 IE，未配置的 Gate Status 和 MBR 不携带
+func qerChildren(q QER) []*ie.IE {
+	children := []*ie.IE{ie.NewQERID(*q.QerId)}
+	if q.GateStatus != nil {
+		children = append(children, ie.NewGateStatus(q.GateStatus.UL, q.GateStatus.DL))
+	}
+	if q.MBR != nil && q.MBR.UL != nil && q.MBR.DL != nil {
+		children = append(children, ie.NewMBR(*q.MBR.UL, *q.MBR.DL))
+	}
+	return children
+}
+
+func barChildren(bar BAR) []*ie.IE {
+	children := []*ie.IE{ie.NewBARID(bar.BarId)}
+
+	if bar.DownlinkDataNotificationDelay != nil {
+		children = append(children,
+			ie.NewDownlinkDataNotificationDelay(time.Duration(*bar.DownlinkDataNotificationDelay)*time.Millisecond))
+	}
+
+	if bar.SuggestedBufferingPacketsCount != nil {
+		children = append(children, ie.NewSuggestedBufferingPacketsCount(*bar.SuggestedBufferingPacketsCount))
+	}
+
+	return children
+}
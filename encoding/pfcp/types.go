@@ -9,6 +9,8 @@ type FSEID struct {
 	SEID        uint64 `yaml:"seid"`
 	Ipv4Address string `yaml:"ipv4Address"`
 	Ipv6Address string `yaml:"ipv6Address"`
+
+	autoSEID bool // seid 为 auto 或未配置，由资源池分配
 }
 
 type FTEID struct {
@@ -53,6 +55,8 @@ type OuterHeaderCreation struct {
 	PortNumber                     uint16 `yaml:"portNumber"`
 	CTag                           uint32 `yaml:"cTag"`
 	STag                           uint32 `yaml:"sTag"`
+
+	autoTEID bool // teid 为 auto 或未配置，由资源池分配
 }
 
 type ForwardingParameters struct {
@@ -61,10 +65,17 @@ type ForwardingParameters struct {
 	InterfaceType3gpp    uint8                `yaml:"interfaceType3gpp"`
 }
 
+type DuplicatingParameters struct {
+	DestinationInterface uint8                `yaml:"destinationInterface"`
+	OuterHeaderCreation  *OuterHeaderCreation `yaml:"outerHeaderCreation"`
+}
+
 type FAR struct {
-	FarId                uint32                `yaml:"farId"`
-	ApplyAction          uint8                 `yaml:"applyAction"`
-	ForwardingParameters *ForwardingParameters `yaml:"forwardingParameters"`
+	FarId                 uint32                 `yaml:"farId"`
+	ApplyAction           ApplyAction            `yaml:"applyAction"`
+	ForwardingParameters  *ForwardingParameters  `yaml:"forwardingParameters"`
+	DuplicatingParameters *DuplicatingParameters `yaml:"duplicatingParameters"`
+	BarId                 *uint8                 `yaml:"barId"`
 }
 
 type URR struct {
@@ -124,6 +135,12 @@ type QER struct {
 	MBR        *MBR        `yaml:"mbr"`
 }
 
+type BAR struct {
+	BarId                          uint8  `yaml:"barId"`
+	DownlinkDataNotificationDelay  *int   `yaml:"downlinkDataNotificationDelay"` // 毫秒
+	SuggestedBufferingPacketsCount *uint8 `yaml:"suggestedBufferingPacketsCount"`
+}
+
 type UserID struct {
 	Flag   uint8  `yaml:"flag"`
 	IMSI   string `yaml:"imsi"`
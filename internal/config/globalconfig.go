@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 	"gopkg.in/yaml.v3"
@@ -19,12 +20,17 @@ type Config struct {
 	Basic     BasicConfig     `yaml:"basic"`
 	DataPlane DataPlaneConfig `yaml:"dataPlane"`
 	Resource  ResourceConfig  `yaml:"resources"`
+	Pfcp      PfcpConfig      `yaml:"pfcp"`
+	Teardown  TeardownConfig  `yaml:"teardown"`
 	TestCases []string        `yaml:"testCases"`
 }
 
 type BasicConfig struct {
 	LocalN4Ip string `yaml:"localN4Ip" validate:"required,ip"`
 	UpfN4Ip   string `yaml:"upfN4Ip" validate:"required,ip"`
+	// N4 端口，0 表示 PFCP 默认端口 8805
+	LocalN4Port int `yaml:"localN4Port" validate:"min=0,max=65535"`
+	UpfN4Port   int `yaml:"upfN4Port" validate:"min=0,max=65535"`
 }
 
 type DataPlaneConfig struct {
@@ -32,13 +38,62 @@ type DataPlaneConfig struct {
 	N3Ip  string `yaml:"n3Ip" validate:"required,ip"`
 	N6Ip  string `yaml:"n6Ip" validate:"required,ip"`
 	DnIp  string `yaml:"dnIp" validate:"required,ip"`
+
+	// N6 隧道端口，非 0 时 DN 侧报文以 IP-in-UDP 形式发往 n6Ip:n6TunnelPort，
+	// 用于模拟 UPF 等 DN 与 UPF 之间没有路由的环境；为 0 时经内核路由收发
+	N6TunnelPort int `yaml:"n6TunnelPort" validate:"omitempty,min=1,max=65535"`
+
+	// 切换目标 gNB，用于 handover 步骤
+	TargetGnbIp   string `yaml:"targetGnbIp" validate:"omitempty,ip"`
+	TargetGnbTeid uint32 `yaml:"targetGnbTeid"` // 目标 gNB 的下行 TEID，0 表示自动分配
 }
 
+// ResourceConfig 本端资源池，步骤 YAML 中配置为 auto 的 SEID、UE 地址和 TEID 从这里分配，会话删除后归还
 type ResourceConfig struct {
-	QueueSize uint16 `yaml:"queueSize" validate:"required,min=10,max=65535"`
-	StartUeIp string `yaml:"startUeIp" validate:"required,ip"`
-	StartSeId uint64 `yaml:"startSeId" validate:"required,min=1"`
-	StartTeId uint32 `yaml:"startTeId" validate:"required,min=1"`
+	QueueSize   uint16 `yaml:"queueSize" validate:"required,min=10,max=65535"`
+	StartUeIp   string `yaml:"startUeIp" validate:"required,ipv4"`
+	StartUeIpv6 string `yaml:"startUeIpv6" validate:"omitempty,ipv6"` // 起始 UE IPv6 地址，按低 64 位递增
+	StartSeId   uint64 `yaml:"startSeId" validate:"required,min=1"`
+	StartTeId   uint32 `yaml:"startTeId" validate:"required,min=1"`
+	PoolSize    uint32 `yaml:"poolSize"` // 每个资源池的容量，0 表示直到取值空间用尽
+}
+
+// PFCP 请求重传默认值 (3GPP TS 29.244 T1/N1)
+const (
+	DefaultT1 = 3 * time.Second
+	DefaultN1 = 3
+)
+
+// PfcpConfig PFCP 请求可靠传输参数 (3GPP TS 29.244 T1/N1)
+type PfcpConfig struct {
+	T1 int  `yaml:"t1" validate:"omitempty,min=1"`        // 重传定时器（毫秒），默认 3000
+	N1 *int `yaml:"n1" validate:"omitempty,min=0,max=10"` // 最大重传次数，默认 3，配置为 0 时不重传
+
+	ReportResponseCause uint8 `yaml:"reportResponseCause"` // Session Report Response 的 Cause，默认 Request Accepted
+}
+
+// TeardownConfig 清理遗留会话的最后手段，逐个发送 Session Deletion Request 后仍有会话无法删除时使用
+type TeardownConfig struct {
+	// 发送以本端 Node ID 标识的 Session Set Deletion Request，删除本端在 UPF 上的全部会话
+	SessionSetDeletion bool `yaml:"sessionSetDeletion"`
+	// 运行结束时发送 Association Release Request，UPF 释放关联时一并删除其会话
+	AssociationRelease bool `yaml:"associationRelease"`
+}
+
+// T1Duration 返回重传定时器时长
+func (c *PfcpConfig) T1Duration() time.Duration {
+	if c.T1 == 0 {
+		return DefaultT1
+	}
+	return time.Duration(c.T1) * time.Millisecond
+}
+
+// N1Count 返回最大重传次数，未配置时为 DefaultN1
+func (c *PfcpConfig) N1Count() int {
+	if c.N1 == nil {
+		return DefaultN1
+	}
+	return *c.N1
 }
 
 func (c *Config) LoadConfig(path string) error {
@@ -61,3 +61,25 @@ func TestConfig_LoadConfig_Validate(t *testing.T) {
 		})
 	}
 }
+
+func TestPfcpConfig_N1Count(t *testing.T) {
+	zero, five := 0, 5
+	tests := []struct {
+		name string
+		n1   *int
+		want int
+	}{
+		{name: "unset", n1: nil, want: DefaultN1},
+		{name: "no retransmission", n1: &zero, want: 0},
+		{name: "configured", n1: &five, want: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := PfcpConfig{N1: tt.n1}
+			if got := cfg.N1Count(); got != tt.want {
+				t.Errorf("N1Count() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package dataplane
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// BufferingTest 下行缓存测试：会话空闲 (下行 FAR 为 BUFF) 时从 DN 侧向 UE 发送带序列号的下行 UDP 报文，
+// 并在 gNB 地址持续接收，直到会话重新激活后 UPF 将缓存的报文送达，核对报文是否完整且按序
+type BufferingTest struct {
+	config *DataPlaneTestConfig
+	gnbIP  string
+	ueIP   string
+	dnIP   string
+	dn     *DNEmulator
+	result *DataPlaneTestResult
+
+	receiver *Receiver
+	stopOnce sync.Once
+
+	mu         sync.Mutex
+	seen       map[uint32]bool
+	maxSeq     uint32
+	received   int
+	bytesRecv  int
+	reordered  int
+	duplicates int
+	latencySum time.Duration
+	minLatency time.Duration
+	maxLatency time.Duration
+}
+
+// NewBufferingTest 创建下行缓存测试，dn 为已启动的 DN 仿真器，测试结束时关闭
+func NewBufferingTest(config *DataPlaneTestConfig, gnbIP, ueIP, dnIP string, dn *DNEmulator) *BufferingTest {
+	return &BufferingTest{
+		config: config,
+		gnbIP:  gnbIP,
+		ueIP:   ueIP,
+		dnIP:   dnIP,
+		dn:     dn,
+		seen:   make(map[uint32]bool),
+		result: &DataPlaneTestResult{
+			TestType:  "BUFFERING",
+			StartTime: time.Now(),
+		},
+	}
+}
+
+// Start 启动 gNB 侧接收并从 DN 侧发送 packetCount 个下行报文，发送完成后返回
+func (t *BufferingTest) Start() error {
+	count := t.config.PacketCount
+	if count <= 0 {
+		return fmt.Errorf("buffering test requires packetCount")
+	}
+
+	log.Printf("Starting buffering test: UE IP=%s, DN IP=%s, Packets=%d", t.ueIP, t.dnIP, count)
+
+	// 缓存的报文送达时会话的下行 TEID 可能已变化，不按 TEID 过滤
+	t.receiver = NewReceiver(t.gnbIP, 2152, 0, t.ueIP)
+	t.receiver.SetHandler(t.handlePacket)
+	if err := t.receiver.Start(); err != nil {
+		return fmt.Errorf("start downlink receiver failed: %w", err)
+	}
+
+	interval := time.Duration(t.config.Interval) * time.Millisecond
+	srcPort := uint16(t.config.DstPort)
+	dstPort := uint16(t.config.SrcPort)
+	for seq := uint32(1); seq <= uint32(count); seq++ {
+		payload := make([]byte, t.config.PayloadSize)
+		copy(payload[0:4], udpProbeMagic)
+		binary.BigEndian.PutUint32(payload[6:10], seq)
+		binary.BigEndian.PutUint64(payload[10:18], uint64(time.Now().UnixNano()))
+
+		if err := t.dn.SendUDP(t.ueIP, srcPort, dstPort, payload); err != nil {
+			log.Printf("Send downlink packet failed: %v", err)
+			continue
+		}
+		t.result.PacketsSent++
+		t.result.BytesSent += 20 + 8 + len(payload)
+
+		if seq < uint32(count) {
+			time.Sleep(interval)
+		}
+	}
+
+	return nil
+}
+
+// handlePacket 统计送达 gNB 的下行报文
+func (t *BufferingTest) handlePacket(inner []byte, receivedAt time.Time) {
+	payload, ok := parseUDPPayload(inner, t.dnIP, t.ueIP)
+	if !ok || len(payload) < udpProbeHeaderLen || !bytes.Equal(payload[0:4], udpProbeMagic) {
+		return
+	}
+	seq := binary.BigEndian.Uint32(payload[6:10])
+	sentAt := time.Unix(0, int64(binary.BigEndian.Uint64(payload[10:18])))
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.seen[seq] {
+		t.duplicates++
+		return
+	}
+	t.seen[seq] = true
+	if seq < t.maxSeq {
+		t.reordered++
+	} else {
+		t.maxSeq = seq
+	}
+
+	// 时延包含在 UPF 中缓存的时间
+	latency := receivedAt.Sub(sentAt)
+	t.latencySum += latency
+	if t.received == 0 || latency < t.minLatency {
+		t.minLatency = latency
+	}
+	if latency > t.maxLatency {
+		t.maxLatency = latency
+	}
+
+	t.received++
+	t.bytesRecv += len(inner)
+}
+
+// Delivered 返回目前送达 gNB 的报文数
+func (t *BufferingTest) Delivered() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.received
+}
+
+// Wait 等待所有报文送达，超时或 ctx 取消时返回 false
+func (t *BufferingTest) Wait(ctx context.Context, timeout time.Duration) bool {
+	deadline := time.After(timeout)
+	check := time.NewTicker(10 * time.Millisecond)
+	defer check.Stop()
+
+	for t.Delivered() < t.result.PacketsSent {
+		select {
+		case <-deadline:
+			return false
+		case <-ctx.Done():
+			return false
+		case <-check.C:
+		}
+	}
+	return true
+}
+
+// Stop 停止接收并计算结果，可重复调用
+func (t *BufferingTest) Stop() error {
+	t.stopOnce.Do(func() {
+		if t.receiver != nil {
+			t.receiver.Stop()
+		}
+		t.dn.Close()
+		t.result.EndTime = time.Now()
+		t.result.Duration = t.result.EndTime.Sub(t.result.StartTime)
+		t.calculateResult()
+	})
+	return nil
+}
+
+// GetResult 获取测试结果
+func (t *BufferingTest) GetResult() *DataPlaneTestResult {
+	return t.result
+}
+
+func (t *BufferingTest) calculateResult() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.result.PacketsReceived = t.received
+	t.result.BytesReceived = t.bytesRecv
+	t.result.Reordered = t.reordered
+	t.result.Duplicates = t.duplicates
+	t.result.PacketsLost = t.result.PacketsSent - t.received
+	if t.result.PacketsLost < 0 {
+		t.result.PacketsLost = 0
+	}
+	if t.result.PacketsSent > 0 {
+		t.result.PacketLossRate = float64(t.result.PacketsLost) / float64(t.result.PacketsSent) * 100
+	}
+	if t.received > 0 {
+		t.result.AvgLatency = t.latencySum / time.Duration(t.received)
+		t.result.MinLatency = t.minLatency
+		t.result.MaxLatency = t.maxLatency
+	}
+
+	t.result.Success = t.result.PacketsSent > 0 && t.result.PacketsLost == 0 && t.reordered == 0 && t.duplicates == 0
+
+	log.Printf("Buffering Test Result: Sent=%d, Received=%d, Lost=%d, Reordered=%d, Duplicates=%d, Latency min/avg/max=%v/%v/%v",
+		t.result.PacketsSent, t.result.PacketsReceived, t.result.PacketsLost, t.result.Reordered, t.result.Duplicates,
+		t.result.MinLatency, t.result.AvgLatency, t.result.MaxLatency)
+}
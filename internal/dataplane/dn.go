@@ -0,0 +1,318 @@
+package dataplane
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+// DNStats DN 仿真器统计，字节数按内层 IP 包计算
+type DNStats struct {
+	UplinkPackets   int // 收到的上行报文
+	UplinkBytes     int
+	EchoedPackets   int // 回送的 Echo Reply / UDP 应答
+	DownlinkPackets int // 主动发起的下行报文
+	DownlinkBytes   int
+}
+
+// DNEmulator DN 仿真器，在 N6 侧绑定 dnIp，接收 UPF 解封装后的上行报文，
+// 自行应答 ICMP Echo 和 UDP 并可主动向 UE 发起下行流量，使上下行可以分别统计。
+//
+// 配置了 N6 隧道时，上下行报文均为 IP-in-UDP，在 dnIp:n6TunnelPort 与 n6Ip:n6TunnelPort 之间收发；
+// 否则经内核路由：UDP 使用绑定 dnIp:port 的 socket，ICMP 使用原始套接字
+// (需要 CAP_NET_RAW，且应关闭内核应答 net.ipv4.icmp_echo_ignore_all=1，否则 UE 会收到重复的应答)
+type DNEmulator struct {
+	dnIP   string
+	port   int
+	tunnel *net.UDPAddr // nil 表示经内核路由收发
+	ueIP   net.IP       // 只处理该 UE 的上行报文，nil 表示不过滤
+
+	conn *net.UDPConn
+	icmp *icmp.PacketConn // 路由模式的 ICMP 原始套接字，无权限时为 nil
+
+	mu       sync.Mutex
+	echo     bool
+	stats    DNStats
+	onUplink func(size int, receivedAt time.Time) // 收到上行报文时回调，用于按时间统计上行实际速率
+
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// NewDNEmulator 创建 DN 仿真器，port 为 DN 侧 UDP 端口，tunnelPort 为 0 时不使用 N6 隧道，
+// ueIP 为空时处理所有 UE 的上行报文
+func NewDNEmulator(dnIP string, port int, n6IP string, tunnelPort int, ueIP string) *DNEmulator {
+	e := &DNEmulator{
+		dnIP: dnIP,
+		port: port,
+		ueIP: net.ParseIP(ueIP),
+		echo: true,
+	}
+	if tunnelPort != 0 {
+		e.tunnel = &net.UDPAddr{IP: net.ParseIP(n6IP), Port: tunnelPort}
+	}
+	return e
+}
+
+// SetEcho 设置是否应答上行报文，默认应答
+func (e *DNEmulator) SetEcho(echo bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.echo = echo
+}
+
+// SetUplinkHandler 设置收到上行报文时的回调，size 为内层 IP 包长度
+func (e *DNEmulator) SetUplinkHandler(handler func(size int, receivedAt time.Time)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.onUplink = handler
+}
+
+// Start 绑定 DN 侧地址并开始接收上行报文
+func (e *DNEmulator) Start() error {
+	local := &net.UDPAddr{IP: net.ParseIP(e.dnIP), Port: e.port}
+	if e.tunnel != nil {
+		local.Port = e.tunnel.Port
+	}
+
+	conn, err := net.ListenUDP("udp", local)
+	if err != nil {
+		return fmt.Errorf("listen DN side socket failed: %w", err)
+	}
+	e.conn = conn
+
+	if e.tunnel != nil {
+		e.wg.Add(1)
+		go e.receiveTunnel()
+		log.Printf("DN emulator started on %s, N6 tunnel peer %s", conn.LocalAddr(), e.tunnel)
+		return nil
+	}
+
+	e.wg.Add(1)
+	go e.receiveUDP()
+
+	if pc, err := icmp.ListenPacket("ip4:icmp", e.dnIP); err != nil {
+		log.Printf("DN emulator ICMP disabled, open raw socket failed: %v", err)
+	} else {
+		e.icmp = pc
+		e.wg.Add(1)
+		go e.receiveICMP()
+	}
+	log.Printf("DN emulator started on %s", conn.LocalAddr())
+	return nil
+}
+
+// SendUDP 从 DN 侧向 UE 发起下行 UDP 报文，srcPort 仅在 N6 隧道模式下生效 (路由模式使用 socket 端口)
+func (e *DNEmulator) SendUDP(ueIP string, srcPort, dstPort uint16, payload []byte) error {
+	if e.tunnel == nil {
+		dst := &net.UDPAddr{IP: net.ParseIP(ueIP), Port: int(dstPort)}
+		if _, err := e.conn.WriteToUDP(payload, dst); err != nil {
+			return fmt.Errorf("send downlink UDP packet failed: %w", err)
+		}
+		e.countDownlink(20 + 8 + len(payload))
+		return nil
+	}
+
+	packet, err := BuildIPUDPPacket(e.dnIP, ueIP, srcPort, dstPort, payload)
+	if err != nil {
+		return err
+	}
+	if _, err := e.conn.WriteToUDP(packet, e.tunnel); err != nil {
+		return fmt.Errorf("send N6 tunnel packet failed: %w", err)
+	}
+	e.countDownlink(len(packet))
+	return nil
+}
+
+// Stats 返回当前统计
+func (e *DNEmulator) Stats() DNStats {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.stats
+}
+
+// Close 停止接收并释放端口，可重复调用
+func (e *DNEmulator) Close() error {
+	e.stopOnce.Do(func() {
+		if e.conn != nil {
+			e.conn.Close()
+		}
+		if e.icmp != nil {
+			e.icmp.Close()
+		}
+		e.wg.Wait()
+	})
+	return nil
+}
+
+// receiveTunnel 接收 N6 隧道中的上行 IP 包，应答经隧道发回 UPF
+func (e *DNEmulator) receiveTunnel() {
+	defer e.wg.Done()
+
+	buffer := make([]byte, 65535)
+	for {
+		n, _, err := e.conn.ReadFromUDP(buffer)
+		if err != nil {
+			return
+		}
+
+		reply, ok := e.handleTunnelPacket(buffer[:n])
+		if !ok || reply == nil {
+			continue
+		}
+		if _, err := e.conn.WriteToUDP(reply, e.tunnel); err != nil {
+			log.Printf("DN emulator send reply failed: %v", err)
+			continue
+		}
+		e.countEchoed()
+	}
+}
+
+// handleTunnelPacket 统计发往 dnIp 的上行 IP 包，需要应答时返回应答包
+func (e *DNEmulator) handleTunnelPacket(packet []byte) ([]byte, bool) {
+	header, err := ipv4.ParseHeader(packet)
+	if err != nil || len(packet) < header.Len || !header.Dst.Equal(net.ParseIP(e.dnIP)) {
+		return nil, false
+	}
+	if !e.accept(header.Src) {
+		return nil, false
+	}
+	echo := e.countUplink(len(packet))
+	if !echo {
+		return nil, true
+	}
+
+	body := packet[header.Len:]
+	switch header.Protocol {
+	case protocolICMP:
+		msg, err := icmp.ParseMessage(1, body)
+		if err != nil || msg.Type != ipv4.ICMPTypeEcho {
+			return nil, true
+		}
+		req, ok := msg.Body.(*icmp.Echo)
+		if !ok {
+			return nil, true
+		}
+		reply, err := buildEchoReplyMessage(req.ID, req.Seq, req.Data)
+		if err != nil {
+			return nil, true
+		}
+		ipHeader, err := buildIPv4Header(header.Dst, header.Src, protocolICMP, len(reply))
+		if err != nil {
+			return nil, true
+		}
+		return append(ipHeader, reply...), true
+
+	case protocolUDP:
+		if len(body) < 8 {
+			return nil, true
+		}
+		srcPort := uint16(body[0])<<8 | uint16(body[1])
+		dstPort := uint16(body[2])<<8 | uint16(body[3])
+		reply, err := BuildIPUDPPacket(e.dnIP, header.Src.String(), dstPort, srcPort, body[8:])
+		if err != nil {
+			return nil, true
+		}
+		return reply, true
+	}
+	return nil, true
+}
+
+// receiveUDP 路由模式下接收上行 UDP 报文并回送负载
+func (e *DNEmulator) receiveUDP() {
+	defer e.wg.Done()
+
+	buffer := make([]byte, 65535)
+	for {
+		n, addr, err := e.conn.ReadFromUDP(buffer)
+		if err != nil {
+			return
+		}
+		if !e.accept(addr.IP) {
+			continue
+		}
+		if !e.countUplink(20 + 8 + n) {
+			continue
+		}
+		if _, err := e.conn.WriteToUDP(buffer[:n], addr); err != nil {
+			log.Printf("DN emulator send reply failed: %v", err)
+			continue
+		}
+		e.countEchoed()
+	}
+}
+
+// receiveICMP 路由模式下经原始套接字接收上行 Echo Request 并应答
+func (e *DNEmulator) receiveICMP() {
+	defer e.wg.Done()
+
+	buffer := make([]byte, 65535)
+	for {
+		n, peer, err := e.icmp.ReadFrom(buffer)
+		if err != nil {
+			return
+		}
+		addr, ok := peer.(*net.IPAddr)
+		if !ok || !e.accept(addr.IP) {
+			continue
+		}
+
+		msg, err := icmp.ParseMessage(1, buffer[:n])
+		if err != nil || msg.Type != ipv4.ICMPTypeEcho {
+			continue
+		}
+		req, ok := msg.Body.(*icmp.Echo)
+		if !ok || !e.countUplink(20+n) {
+			continue
+		}
+
+		reply, err := buildEchoReplyMessage(req.ID, req.Seq, req.Data)
+		if err != nil {
+			continue
+		}
+		if _, err := e.icmp.WriteTo(reply, addr); err != nil {
+			log.Printf("DN emulator send echo reply failed: %v", err)
+			continue
+		}
+		e.countEchoed()
+	}
+}
+
+// accept 判断是否处理来自 src 的上行报文
+func (e *DNEmulator) accept(src net.IP) bool {
+	return e.ueIP == nil || e.ueIP.Equal(src)
+}
+
+// countUplink 统计上行报文，返回是否需要应答
+func (e *DNEmulator) countUplink(size int) bool {
+	receivedAt := time.Now()
+
+	e.mu.Lock()
+	e.stats.UplinkPackets++
+	e.stats.UplinkBytes += size
+	echo, onUplink := e.echo, e.onUplink
+	e.mu.Unlock()
+
+	if onUplink != nil {
+		onUplink(size, receivedAt)
+	}
+	return echo
+}
+
+func (e *DNEmulator) countEchoed() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.stats.EchoedPackets++
+}
+
+func (e *DNEmulator) countDownlink(size int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.stats.DownlinkPackets++
+	e.stats.DownlinkBytes += size
+}
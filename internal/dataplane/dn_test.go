@@ -0,0 +1,52 @@
+package dataplane
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDNEmulatorHandleTunnelPacket(t *testing.T) {
+	e := NewDNEmulator("10.60.0.1", 5001, "10.60.0.254", 2153, "10.45.0.2")
+
+	udp, err := BuildIPUDPPacket("10.45.0.2", "10.60.0.1", 10000, 5001, []byte("probe"))
+	if err != nil {
+		t.Fatalf("BuildIPUDPPacket() error = %v", err)
+	}
+	reply, ok := e.handleTunnelPacket(udp)
+	if !ok || reply == nil {
+		t.Fatalf("expect UDP reply, got ok=%v", ok)
+	}
+	payload, ok := parseUDPPayload(reply, "10.60.0.1", "10.45.0.2")
+	if !ok || !bytes.Equal(payload, []byte("probe")) {
+		t.Errorf("UDP reply payload = %q, ok=%v", payload, ok)
+	}
+	if reply[20] != 0x13 || reply[21] != 0x89 || reply[22] != 0x27 || reply[23] != 0x10 {
+		t.Errorf("expect swapped ports 5001 -> 10000, got % x", reply[20:24])
+	}
+
+	icmp, err := BuildIPICMPPacket("10.45.0.2", "10.60.0.1", 7, []byte("ping"))
+	if err != nil {
+		t.Fatalf("BuildIPICMPPacket() error = %v", err)
+	}
+	reply, ok = e.handleTunnelPacket(icmp)
+	if !ok || reply == nil {
+		t.Fatalf("expect echo reply, got ok=%v", ok)
+	}
+	if id, seq, ok := parseEchoReply(reply, "10.60.0.1", "10.45.0.2"); !ok || id != icmpEchoID() || seq != 7 {
+		t.Errorf("parseEchoReply() = %d, %d, %v", id, seq, ok)
+	}
+
+	other, _ := BuildIPUDPPacket("10.45.0.3", "10.60.0.1", 10000, 5001, []byte("probe"))
+	if _, ok := e.handleTunnelPacket(other); ok {
+		t.Error("expect packets from other UEs to be ignored")
+	}
+
+	e.SetEcho(false)
+	if reply, ok := e.handleTunnelPacket(udp); !ok || reply != nil {
+		t.Errorf("expect no reply with echo disabled, got ok=%v reply=%v", ok, reply)
+	}
+
+	if stats := e.Stats(); stats.UplinkPackets != 3 || stats.UplinkBytes != 2*len(udp)+len(icmp) {
+		t.Errorf("Stats() = %+v", stats)
+	}
+}
@@ -6,6 +6,12 @@ import (
 	"fmt"
 )
 
+// gtpHeaderLen 不带可选字段的 GTP-U 头部长度
+const gtpHeaderLen = 8
+
+// gtpMsgTypeEndMarker GTP-U End Marker 消息类型 (3GPP TS 29.281)
+const gtpMsgTypeEndMarker = 0xFE
+
 func buildGTPHeader(tunnelID uint32, payloadLen int) ([]byte, error) {
 	var gtpBuf bytes.Buffer
 
@@ -30,3 +36,45 @@ func buildGTPHeader(tunnelID uint32, payloadLen int) ([]byte, error) {
 
 	return gtpBuf.Bytes(), nil
 }
+
+// parseGPDU 解析 GTP-U G-PDU，返回 TEID 和内层 IP 包，支持可选字段和扩展头
+func parseGPDU(b []byte) (uint32, []byte, error) {
+	if len(b) < gtpHeaderLen {
+		return 0, nil, fmt.Errorf("gtp-u packet too short: %d", len(b))
+	}
+	flags := b[0]
+	if b[1] != 0xFF {
+		return 0, nil, fmt.Errorf("not a g-pdu: %d", b[1])
+	}
+	teid := binary.BigEndian.Uint32(b[4:8])
+
+	offset := gtpHeaderLen
+	if flags&0x07 != 0 {
+		if len(b) < 12 {
+			return 0, nil, fmt.Errorf("gtp-u optional header truncated")
+		}
+		offset = 12
+		next := b[11]
+		for flags&0x04 != 0 && next != 0 {
+			if len(b) < offset+1 {
+				return 0, nil, fmt.Errorf("gtp-u extension header truncated")
+			}
+			extLen := int(b[offset]) * 4
+			if extLen == 0 || len(b) < offset+extLen {
+				return 0, nil, fmt.Errorf("gtp-u extension header truncated")
+			}
+			next = b[offset+extLen-1]
+			offset += extLen
+		}
+	}
+
+	return teid, b[offset:], nil
+}
+
+// parseEndMarker 解析 GTP-U End Marker，返回 TEID
+func parseEndMarker(b []byte) (uint32, bool) {
+	if len(b) < gtpHeaderLen || b[1] != gtpMsgTypeEndMarker {
+		return 0, false
+	}
+	return binary.BigEndian.Uint32(b[4:8]), true
+}
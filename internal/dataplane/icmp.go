@@ -1,6 +1,7 @@
 package dataplane
 
 import (
+	"net"
 	"os"
 
 	"golang.org/x/net/icmp"
@@ -9,13 +10,55 @@ import (
 
 func buildICMPMessage(seq int, data []byte) ([]byte, error) {
 
-	pid := os.Getpid() & 0xffff
-
 	msg := icmp.Message{
 		Type: ipv4.ICMPTypeEcho,
 		Code: 0,
 		Body: &icmp.Echo{
-			ID:   pid,
+			ID:   icmpEchoID(),
+			Seq:  seq,
+			Data: data,
+		},
+	}
+
+	return msg.Marshal(nil)
+}
+
+// icmpEchoID 本进程发送的 Echo Request 的 ID
+func icmpEchoID() int {
+	return os.Getpid() & 0xffff
+}
+
+// parseEchoReply 解析内层 IPv4 ICMP Echo Reply，校验源 (DN) 和目的 (UE) 地址，返回 ID 和序列号
+func parseEchoReply(packet []byte, srcIP, dstIP string) (int, int, bool) {
+	if len(packet) < 20 || packet[0]>>4 != 4 {
+		return 0, 0, false
+	}
+	ihl := int(packet[0]&0x0f) * 4
+	if ihl < 20 || len(packet) < ihl || packet[9] != 1 {
+		return 0, 0, false
+	}
+	if !net.IP(packet[12:16]).Equal(net.ParseIP(srcIP)) || !net.IP(packet[16:20]).Equal(net.ParseIP(dstIP)) {
+		return 0, 0, false
+	}
+
+	msg, err := icmp.ParseMessage(1, packet[ihl:])
+	if err != nil || msg.Type != ipv4.ICMPTypeEchoReply {
+		return 0, 0, false
+	}
+	echo, ok := msg.Body.(*icmp.Echo)
+	if !ok {
+		return 0, 0, false
+	}
+	return echo.ID, echo.Seq, true
+}
+
+// buildEchoReplyMessage 按 Echo Request 的 ID、序列号和数据构造 Echo Reply
+func buildEchoReplyMessage(id, seq int, data []byte) ([]byte, error) {
+	msg := icmp.Message{
+		Type: ipv4.ICMPTypeEchoReply,
+		Code: 0,
+		Body: &icmp.Echo{
+			ID:   id,
 			Seq:  seq,
 			Data: data,
 		},
@@ -7,9 +7,15 @@ import (
 	"os"
 )
 
+// IPv4 协议号
+const (
+	protocolICMP = 1
+	protocolUDP  = 17
+)
+
 func checksum(data []byte) uint16 {
 	var sum uint32
-	for i := 0; i < len(data); i += 2 {
+	for i := 0; i+1 < len(data); i += 2 {
 		sum += uint32(binary.BigEndian.Uint16(data[i:]))
 	}
 
@@ -22,7 +28,7 @@ func checksum(data []byte) uint16 {
 	return ^uint16(sum)
 }
 
-func buildIPv4Header(srcIP, dstIP net.IP, payloadLen int) ([]byte, error) {
+func buildIPv4Header(srcIP, dstIP net.IP, protocol uint8, payloadLen int) ([]byte, error) {
 	if srcIP.To4() == nil || dstIP.To4() == nil {
 		return nil, fmt.Errorf("仅支持IPv4地址")
 	}
@@ -44,7 +50,7 @@ func buildIPv4Header(srcIP, dstIP net.IP, payloadLen int) ([]byte, error) {
 
 	ipHeader[8] = 64
 
-	ipHeader[9] = 1
+	ipHeader[9] = protocol
 
 	ipHeader[10] = 0
 	ipHeader[11] = 0
@@ -58,3 +64,26 @@ func buildIPv4Header(srcIP, dstIP net.IP, payloadLen int) ([]byte, error) {
 
 	return ipHeader, nil
 }
+
+// buildUDPDatagram 构造 UDP 报文，校验和包含 IPv4 伪首部
+func buildUDPDatagram(srcIP, dstIP net.IP, srcPort, dstPort uint16, payload []byte) []byte {
+	length := 8 + len(payload)
+	datagram := make([]byte, length)
+	binary.BigEndian.PutUint16(datagram[0:2], srcPort)
+	binary.BigEndian.PutUint16(datagram[2:4], dstPort)
+	binary.BigEndian.PutUint16(datagram[4:6], uint16(length))
+	copy(datagram[8:], payload)
+
+	pseudo := make([]byte, 12, 12+length)
+	copy(pseudo[0:4], srcIP.To4())
+	copy(pseudo[4:8], dstIP.To4())
+	pseudo[9] = protocolUDP
+	binary.BigEndian.PutUint16(pseudo[10:12], uint16(length))
+	cksum := checksum(append(pseudo, datagram...))
+	if cksum == 0 {
+		cksum = 0xffff
+	}
+	binary.BigEndian.PutUint16(datagram[6:8], cksum)
+
+	return datagram
+}
@@ -8,17 +8,27 @@ import (
 	"time"
 )
 
-// Receiver 数据平面接收器
+// PacketHandler 下行内层 IP 包处理函数，inner 仅在调用期间有效
+type PacketHandler func(inner []byte, receivedAt time.Time)
+
+// EndMarkerHandler GTP-U End Marker 处理函数
+type EndMarkerHandler func(teid uint32, receivedAt time.Time)
+
+// Receiver 数据平面接收器，监听 gNB GTP-U 地址接收下行数据，
+// 同一个 socket 也用于发送上行数据，保证源端口为 2152
 type Receiver struct {
 	listenIP   string
 	listenPort int
-	teid       uint32
+	teid       uint32 // 下行 TEID，0 表示不过滤
 	ueIP       string
-	
+	handler    PacketHandler
+	endMarker  EndMarkerHandler
+
 	conn     *net.UDPConn
 	stopChan chan struct{}
+	stopOnce sync.Once
 	wg       sync.WaitGroup
-	
+
 	// 统计信息
 	packetsReceived int
 	bytesReceived   int64
@@ -36,6 +46,16 @@ func NewReceiver(listenIP string, listenPort int, teid uint32, ueIP string) *Rec
 	}
 }
 
+// SetHandler 设置下行数据包处理函数，需在 Start 之前调用
+func (r *Receiver) SetHandler(handler PacketHandler) {
+	r.handler = handler
+}
+
+// SetEndMarkerHandler 设置 End Marker 处理函数，需在 Start 之前调用
+func (r *Receiver) SetEndMarkerHandler(handler EndMarkerHandler) {
+	r.endMarker = handler
+}
+
 // Start 启动接收器
 func (r *Receiver) Start() error {
 	addr, err := net.ResolveUDPAddr("udp", fmt.Sprintf("%s:%d", r.listenIP, r.listenPort))
@@ -57,6 +77,37 @@ func (r *Receiver) Start() error {
 	return nil
 }
 
+// Started 接收器是否已绑定 socket
+func (r *Receiver) Started() bool {
+	return r.conn != nil
+}
+
+// startReceiver 启动测试的下行接收器。UDS 模式下上行不经过接收器的 socket，
+// 绑定失败不终止测试，只在 result 中记录收不到下行报文
+func startReceiver(r *Receiver, udsSocketPath string, result *DataPlaneTestResult) error {
+	err := r.Start()
+	if err == nil {
+		return nil
+	}
+	if udsSocketPath == "" {
+		return fmt.Errorf("start downlink receiver failed: %w", err)
+	}
+	log.Printf("Start downlink receiver failed, downlink packets cannot be received in UDS mode: %v", err)
+	result.ErrorMessage = fmt.Sprintf("downlink receiver not started, replies cannot be received: %v", err)
+	return nil
+}
+
+// Send 从接收器的 socket 发送数据
+func (r *Receiver) Send(data []byte, dst *net.UDPAddr) error {
+	if r.conn == nil {
+		return fmt.Errorf("receiver not started")
+	}
+	if _, err := r.conn.WriteToUDP(data, dst); err != nil {
+		return fmt.Errorf("send UDP packet failed: %w", err)
+	}
+	return nil
+}
+
 // receive 接收数据包
 func (r *Receiver) receive() {
 	defer r.wg.Done()
@@ -70,49 +121,40 @@ func (r *Receiver) receive() {
 		default:
 			// 设置读取超时，避免阻塞
 			r.conn.SetReadDeadline(time.Now().Add(1 * time.Second))
-			
+
 			n, remoteAddr, err := r.conn.ReadFromUDP(buffer)
 			if err != nil {
 				if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
 					continue
 				}
+				select {
+				case <-r.stopChan:
+					return
+				default:
+				}
 				log.Printf("Read from UDP failed: %v", err)
 				continue
 			}
+			receivedAt := time.Now()
 
-			// 解析 GTP 头部
-			if n < 8 {
-				log.Printf("Packet too short: %d bytes", n)
+			if teid, ok := parseEndMarker(buffer[:n]); ok {
+				if r.endMarker != nil && (r.teid == 0 || teid == r.teid) {
+					log.Printf("Received end marker from %s, TEID=%d", remoteAddr, teid)
+					r.endMarker(teid, receivedAt)
+				}
 				continue
 			}
 
-			// GTP-U header: flags(1) + msgType(1) + length(2) + TEID(4)
-			flags := buffer[0]
-			msgType := buffer[1]
-			teid := uint32(buffer[4])<<24 | uint32(buffer[5])<<16 | uint32(buffer[6])<<8 | uint32(buffer[7])
-
-			// 检查是否是 GTP-U 数据包 (msgType = 0xFF)
-			if msgType != 0xFF {
+			teid, innerPacket, err := parseGPDU(buffer[:n])
+			if err != nil {
 				continue
 			}
 
 			// 检查 TEID 是否匹配
-			if teid != r.teid {
-				continue
-			}
-
-			// 提取内部 IP 包
-			gtpHeaderLen := 8
-			if flags&0x07 != 0 { // 有扩展头部
-				gtpHeaderLen = 12
-			}
-
-			if n <= gtpHeaderLen {
+			if r.teid != 0 && teid != r.teid {
 				continue
 			}
 
-			innerPacket := buffer[gtpHeaderLen:n]
-
 			// 简单验证是否是 IP 包
 			if len(innerPacket) < 20 {
 				continue
@@ -121,29 +163,37 @@ func (r *Receiver) receive() {
 			// 更新统计
 			r.mu.Lock()
 			r.packetsReceived++
-			r.bytesReceived += int64(n)
+			r.bytesReceived += int64(len(innerPacket))
+			received := r.packetsReceived
 			r.mu.Unlock()
 
-			if r.packetsReceived%10 == 0 {
-				log.Printf("Received %d packets from %s, TEID=%d", r.packetsReceived, remoteAddr, teid)
+			if r.handler != nil {
+				r.handler(innerPacket, receivedAt)
+			}
+
+			if received%10 == 0 {
+				log.Printf("Received %d packets from %s, TEID=%d", received, remoteAddr, teid)
 			}
 		}
 	}
 }
 
-// Stop 停止接收器
+// Stop 停止接收器，可重复调用
 func (r *Receiver) Stop() error {
-	close(r.stopChan)
-	if r.conn != nil {
-		r.conn.Close()
-	}
-	r.wg.Wait()
-	
-	log.Printf("Receiver stopped. Total received: %d packets, %d bytes", r.packetsReceived, r.bytesReceived)
+	r.stopOnce.Do(func() {
+		close(r.stopChan)
+		if r.conn != nil {
+			r.conn.Close()
+		}
+		r.wg.Wait()
+
+		packets, bytes := r.GetStats()
+		log.Printf("Receiver stopped. Total received: %d packets, %d bytes", packets, bytes)
+	})
 	return nil
 }
 
-// GetStats 获取统计信息
+// GetStats 获取统计信息，字节数为内层 IP 包长度
 func (r *Receiver) GetStats() (packets int, bytes int64) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -0,0 +1,48 @@
+package dataplane
+
+import (
+	"net"
+	"testing"
+)
+
+func TestStartReceiver_BindFailure(t *testing.T) {
+	busy, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		t.Fatalf("ListenUDP() error = %v", err)
+	}
+	defer busy.Close()
+	port := busy.LocalAddr().(*net.UDPAddr).Port
+
+	t.Run("udp mode", func(t *testing.T) {
+		result := &DataPlaneTestResult{}
+		r := NewReceiver("127.0.0.1", port, 0, "10.45.0.2")
+		if err := startReceiver(r, "", result); err == nil {
+			t.Fatal("startReceiver() error = nil, want bind failure")
+		}
+		r.Stop()
+	})
+
+	t.Run("uds mode", func(t *testing.T) {
+		result := &DataPlaneTestResult{}
+		r := NewReceiver("127.0.0.1", port, 0, "10.45.0.2")
+		if err := startReceiver(r, "/tmp/upf.sock", result); err != nil {
+			t.Fatalf("startReceiver() error = %v, want nil in UDS mode", err)
+		}
+		if r.Started() {
+			t.Error("Started() = true, want false")
+		}
+		if result.ErrorMessage == "" {
+			t.Error("ErrorMessage is empty, want note that replies cannot be received")
+		}
+		r.Stop()
+	})
+}
+
+func TestReceiver_StopTwice(t *testing.T) {
+	r := NewReceiver("127.0.0.1", 0, 0, "10.45.0.2")
+	if err := r.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	r.Stop()
+	r.Stop()
+}
@@ -71,7 +71,7 @@ func BuildIPICMPPacket(srcIP, dstIP string, seq int, icmpData []byte) ([]byte, e
 		return nil, fmt.Errorf("构造ICMP消息失败: %v", err)
 	}
 
-	ipHeader, err := buildIPv4Header(src, dst, len(icmpPacket))
+	ipHeader, err := buildIPv4Header(src, dst, protocolICMP, len(icmpPacket))
 	if err != nil {
 		return nil, fmt.Errorf("构造IP头部失败: %v", err)
 	}
@@ -112,3 +112,36 @@ func SendUDS(socketPath string, data []byte) error {
 
 	return nil
 }
+
+func BuildIPUDPPacket(srcIP, dstIP string, srcPort, dstPort uint16, payload []byte) ([]byte, error) {
+
+	src := net.ParseIP(srcIP)
+	dst := net.ParseIP(dstIP)
+	if src == nil || dst == nil {
+		return nil, fmt.Errorf("无效的IP地址")
+	}
+
+	udpPacket := buildUDPDatagram(src, dst, srcPort, dstPort, payload)
+
+	ipHeader, err := buildIPv4Header(src, dst, protocolUDP, len(udpPacket))
+	if err != nil {
+		return nil, fmt.Errorf("构造IP头部失败: %v", err)
+	}
+
+	return append(ipHeader, udpPacket...), nil
+}
+
+func BuildGTPIPUDPPacket(srcIP, dstIP string, srcPort, dstPort uint16, tunnelID uint32, payload []byte) ([]byte, error) {
+
+	ipUdpPacket, err := BuildIPUDPPacket(srcIP, dstIP, srcPort, dstPort, payload)
+	if err != nil {
+		return nil, fmt.Errorf("构造IP+UDP数据包失败: %v", err)
+	}
+
+	gtpHeader, err := buildGTPHeader(tunnelID, len(ipUdpPacket))
+	if err != nil {
+		return nil, fmt.Errorf("构造GTP头部失败: %v", err)
+	}
+
+	return append(gtpHeader, ipUdpPacket...), nil
+}
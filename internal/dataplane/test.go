@@ -1,9 +1,12 @@
 package dataplane
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"net"
 	"os"
+	"sync"
 	"time"
 
 	"gopkg.in/yaml.v3"
@@ -11,7 +14,7 @@ import (
 
 // DataPlaneTestConfig 数据平面测试配置
 type DataPlaneTestConfig struct {
-	TestType      string `yaml:"testType"`      // icmp, udp, tcp, throughput
+	TestType      string `yaml:"testType"`      // icmp, udp, tcp, throughput, buffering, downlink
 	Duration      int    `yaml:"duration"`      // 测试时长（秒）
 	PacketCount   int    `yaml:"packetCount"`   // 发送包数量，0 表示持续发送
 	Interval      int    `yaml:"interval"`      // 发送间隔（毫秒）
@@ -19,6 +22,21 @@ type DataPlaneTestConfig struct {
 	Bidirectional bool   `yaml:"bidirectional"` // 是否双向测试
 	DstIp         string `yaml:"dstIp"`         // 目标 IP 地址 (可选，默认使用 globalConfig.DnIp)
 	UDSSocketPath string `yaml:"udsSocketPath"` // Unix Domain Socket 路径 (可选，用于替代 UDP发送)
+	DnEmulator    bool   `yaml:"dnEmulator"`    // 在 dstIp 上启动 DN 仿真器应答上行，分别统计上下行 (downlink 测试总是启动)
+
+	// UDP 测试参数
+	SrcPort   int     `yaml:"srcPort"`   // UE 侧源端口，多条流依次递增，默认 10000
+	DstPort   int     `yaml:"dstPort"`   // DN 侧目的端口，默认 5001
+	FlowCount int     `yaml:"flowCount"` // 流数量，默认 1
+	RatePps   int     `yaml:"ratePps"`   // 发送速率 (包/秒)，未配置时按 interval 发送
+	RateMbps  float64 `yaml:"rateMbps"`  // 发送速率 (Mbps，按内层 IP 包计算)，与 ratePps 二选一
+
+	// 吞吐量测试参数，rateMbps 为目标速率
+	Ramp         *RampConfig `yaml:"ramp"`         // 阶梯升速 (可选)
+	WindowMs     int         `yaml:"windowMs"`     // 滑动窗口长度（毫秒），默认 1000
+	WindowStepMs int         `yaml:"windowStepMs"` // 滑动窗口步长（毫秒），默认 100
+	VerifyMbr    bool        `yaml:"verifyMbr"`    // 是否按会话 QER 的上行/下行 MBR 分别核对两个方向的速率，需要启用 dnEmulator
+	MbrTolerance float64     `yaml:"mbrTolerance"` // MBR 核对的允许偏差 (百分比)，默认 10
 }
 
 // LoadDataPlaneTestConfig 从文件加载数据平面测试配置
@@ -27,12 +45,25 @@ func LoadDataPlaneTestConfig(path string) (*DataPlaneTestConfig, error) {
 	if err != nil {
 		return nil, fmt.Errorf("read config file failed: %w", err)
 	}
+	return ParseDataPlaneTestConfig(data)
+}
+
+// ParseDataPlaneTestConfig 解析数据平面测试配置并填写默认值，按配置中的 testType 校验
+func ParseDataPlaneTestConfig(data []byte) (*DataPlaneTestConfig, error) {
+	return ParseDataPlaneTestConfigAs(data, "")
+}
 
+// ParseDataPlaneTestConfigAs 解析数据平面测试配置并填写默认值，testType 不为空时覆盖配置中的 testType，
+// 按实际运行的测试类型校验 (如探测报文的最小负载长度)
+func ParseDataPlaneTestConfigAs(data []byte, testType string) (*DataPlaneTestConfig, error) {
 	var config DataPlaneTestConfig
-	err = yaml.Unmarshal(data, &config)
+	err := yaml.Unmarshal(data, &config)
 	if err != nil {
 		return nil, fmt.Errorf("unmarshal config failed: %w", err)
 	}
+	if testType != "" {
+		config.TestType = testType
+	}
 
 	// 设置默认值
 	if config.Duration == 0 {
@@ -44,10 +75,73 @@ func LoadDataPlaneTestConfig(path string) (*DataPlaneTestConfig, error) {
 	if config.PayloadSize == 0 {
 		config.PayloadSize = 64
 	}
+	if config.SrcPort == 0 {
+		config.SrcPort = 10000
+	}
+	if config.DstPort == 0 {
+		config.DstPort = 5001
+	}
+	if config.FlowCount == 0 {
+		config.FlowCount = 1
+	}
+
+	if config.WindowMs == 0 {
+		config.WindowMs = 1000
+	}
+	if config.WindowStepMs == 0 {
+		config.WindowStepMs = 100
+	}
+	if config.MbrTolerance == 0 {
+		config.MbrTolerance = 10
+	}
+
+	if config.RatePps > 0 && config.RateMbps > 0 {
+		return nil, fmt.Errorf("ratePps and rateMbps are mutually exclusive")
+	}
+	if config.RatePps < 0 || config.RateMbps < 0 {
+		return nil, fmt.Errorf("rate must not be negative")
+	}
+	if config.SrcPort+config.FlowCount-1 > 65535 || config.DstPort > 65535 {
+		return nil, fmt.Errorf("invalid port range")
+	}
+	if (config.TestType == "udp" || config.TestType == "throughput" || config.TestType == "buffering" ||
+		config.TestType == "downlink") &&
+		config.PayloadSize < udpProbeHeaderLen {
+		return nil, fmt.Errorf("%s payloadSize must be at least %d bytes", config.TestType, udpProbeHeaderLen)
+	}
+	if config.TestType == "throughput" {
+		if config.RateMbps <= 0 {
+			return nil, fmt.Errorf("throughput test requires rateMbps")
+		}
+		if ramp := config.Ramp; ramp != nil && (ramp.StartMbps <= 0 || ramp.StepMbps <= 0 || ramp.StepDuration <= 0) {
+			return nil, fmt.Errorf("ramp startMbps, stepMbps and stepDuration must be positive")
+		}
+		if config.WindowMs < 10 || config.WindowStepMs < 10 {
+			return nil, fmt.Errorf("windowMs and windowStepMs must be at least 10")
+		}
+		if config.MbrTolerance < 0 {
+			return nil, fmt.Errorf("mbrTolerance must not be negative")
+		}
+		if config.VerifyMbr && !config.DnEmulator {
+			return nil, fmt.Errorf("verifyMbr requires dnEmulator to measure the delivered uplink rate")
+		}
+	}
 
 	return &config, nil
 }
 
+// packetRate 返回发送速率 (包/秒)，packetSize 为内层 IP 包长度
+func (c *DataPlaneTestConfig) packetRate(packetSize int) float64 {
+	switch {
+	case c.RatePps > 0:
+		return float64(c.RatePps)
+	case c.RateMbps > 0:
+		return c.RateMbps * 1e6 / 8 / float64(packetSize)
+	default:
+		return 1000 / float64(c.Interval)
+	}
+}
+
 // DataPlaneTestResult 数据平面测试结果
 type DataPlaneTestResult struct {
 	TestType        string
@@ -56,58 +150,150 @@ type DataPlaneTestResult struct {
 	Duration        time.Duration
 	PacketsSent     int
 	PacketsReceived int
+	BytesSent       int // 发送的内层 IP 包字节数，与 URR 流量统计口径一致
+	BytesReceived   int // 接收的内层 IP 包字节数
 	PacketsLost     int
 	PacketLossRate  float64
 	AvgLatency      time.Duration
 	MinLatency      time.Duration
 	MaxLatency      time.Duration
-	Throughput      float64 // Mbps
+	RoundTrip       bool               // 时延为往返时延 (RTT)，否则为单向时延
+	Throughput      float64            // Mbps
+	Reordered       int                // 乱序到达的包数
+	Duplicates      int                // 重复到达的包数
+	Jitter          time.Duration      // RFC 3550 到达间隔抖动
+	Windows         []ThroughputWindow // 吞吐量测试的滑动窗口速率
+	Directions      *DirectionStats    // 启用 DN 仿真器时分别统计的上下行结果
 	Success         bool
 	ErrorMessage    string
 }
 
+// DirectionStats 按方向统计的收发结果，上行在 DN 仿真器处接收，下行在 gNB 处接收
+type DirectionStats struct {
+	UplinkSent       int
+	UplinkReceived   int
+	UplinkLost       int
+	DownlinkSent     int // DN 仿真器应答和主动发起的报文
+	DownlinkReceived int
+	DownlinkLost     int
+}
+
+// SetDNStats 按 DN 仿真器的统计填写分方向结果，downlink 测试没有上行流量
+func (r *DataPlaneTestResult) SetDNStats(stats DNStats) {
+	d := &DirectionStats{
+		UplinkReceived:   stats.UplinkPackets,
+		DownlinkSent:     stats.EchoedPackets + stats.DownlinkPackets,
+		DownlinkReceived: r.PacketsReceived,
+	}
+	if r.TestType != "DOWNLINK" {
+		d.UplinkSent = r.PacketsSent
+	}
+	d.UplinkLost = max(d.UplinkSent-d.UplinkReceived, 0)
+	d.DownlinkLost = max(d.DownlinkSent-d.DownlinkReceived, 0)
+	r.Directions = d
+
+	log.Printf("%s Test Directions: Uplink Sent=%d, Received=%d, Lost=%d; Downlink Sent=%d, Received=%d, Lost=%d",
+		r.TestType, d.UplinkSent, d.UplinkReceived, d.UplinkLost, d.DownlinkSent, d.DownlinkReceived, d.DownlinkLost)
+}
+
 // DataPlaneTest 数据平面测试接口
 type DataPlaneTest interface {
 	Start() error
 	Stop() error
+	Done() <-chan struct{} // 测试自行结束 (时长或包数到达且应答收齐) 时关闭
 	GetResult() *DataPlaneTestResult
 }
 
-// ICMPTest ICMP 测试
+// Wait 等待测试自行结束，超过 limit 或 ctx 取消时停止测试；ctx 取消时返回 ctx.Err()，结果为停止前的统计
+func Wait(ctx context.Context, test DataPlaneTest, limit time.Duration) error {
+	timer := time.NewTimer(limit)
+	defer timer.Stop()
+
+	select {
+	case <-test.Done():
+		return nil
+	case <-timer.C:
+		test.Stop()
+		return nil
+	case <-ctx.Done():
+		test.Stop()
+		return ctx.Err()
+	}
+}
+
+// replyGrace 发送结束后等待剩余应答的时间
+const replyGrace = time.Second
+
+// waitReplies 发送结束后等待应答收齐，超过 replyGrace 或被停止时返回
+func waitReplies(stopChan <-chan struct{}, allReplied func() bool) {
+	grace := time.After(replyGrace)
+	check := time.NewTicker(10 * time.Millisecond)
+	defer check.Stop()
+
+	for !allReplied() {
+		select {
+		case <-stopChan:
+			return
+		case <-grace:
+			return
+		case <-check.C:
+		}
+	}
+}
+
+// ICMPTest ICMP 测试，经 N3 发送上行 Echo Request，并在 gNB 地址接收下行 Echo Reply
 type ICMPTest struct {
-	config   *DataPlaneTestConfig
-	srcIP    string
-	dstIP    string
-	gnbIP    string
-	upfN3IP  string
-	teid     uint32
-	ueIP     string
-	result   *DataPlaneTestResult
-	stopChan chan struct{}
-	doneChan chan struct{}
+	config       *DataPlaneTestConfig
+	srcIP        string
+	dstIP        string
+	gnbIP        string
+	upfN3IP      string
+	teid         uint32 // 上行 TEID
+	downlinkTEID uint32 // 下行 TEID，0 表示不过滤
+	ueIP         string
+	receiver     *Receiver
+	result       *DataPlaneTestResult
+	stopChan     chan struct{}
+	stopOnce     sync.Once
+	doneChan     chan struct{}
+
+	mu            sync.Mutex
+	sentAt        map[int]time.Time     // 序列号 -> 发送时间
+	rtts          map[int]time.Duration // 序列号 -> RTT，重复的应答只统计一次
+	bytesReceived int
 }
 
 // NewICMPTest 创建 ICMP 测试
-func NewICMPTest(config *DataPlaneTestConfig, gnbIP, upfN3IP string, teid uint32, ueIP, dstIP string) *ICMPTest {
+func NewICMPTest(config *DataPlaneTestConfig, gnbIP, upfN3IP string, teid, downlinkTEID uint32, ueIP, dstIP string) *ICMPTest {
 	return &ICMPTest{
-		config:   config,
-		gnbIP:    gnbIP,
-		upfN3IP:  upfN3IP,
-		teid:     teid,
-		ueIP:     ueIP,
-		dstIP:    dstIP,
-		stopChan: make(chan struct{}),
-		doneChan: make(chan struct{}),
+		config:       config,
+		gnbIP:        gnbIP,
+		upfN3IP:      upfN3IP,
+		teid:         teid,
+		downlinkTEID: downlinkTEID,
+		ueIP:         ueIP,
+		dstIP:        dstIP,
+		stopChan:     make(chan struct{}),
+		doneChan:     make(chan struct{}),
+		sentAt:       make(map[int]time.Time),
+		rtts:         make(map[int]time.Duration),
 		result: &DataPlaneTestResult{
 			TestType:  "ICMP",
 			StartTime: time.Now(),
+			RoundTrip: true,
 		},
 	}
 }
 
 // Start 启动 ICMP 测试
 func (t *ICMPTest) Start() error {
-	log.Printf("Starting ICMP test: UE IP=%s, TEID=%d, Duration=%ds", t.ueIP, t.teid, t.config.Duration)
+	log.Printf("Starting ICMP test: UE IP=%s, TEID=%d, Downlink TEID=%d, Duration=%ds", t.ueIP, t.teid, t.downlinkTEID, t.config.Duration)
+
+	t.receiver = NewReceiver(t.gnbIP, 2152, t.downlinkTEID, t.ueIP)
+	t.receiver.SetHandler(t.handleReply)
+	if err := startReceiver(t.receiver, t.config.UDSSocketPath, t.result); err != nil {
+		return err
+	}
 
 	go t.run()
 
@@ -117,9 +303,9 @@ func (t *ICMPTest) Start() error {
 // run 运行 ICMP 测试
 func (t *ICMPTest) run() {
 	defer close(t.doneChan)
+	defer t.receiver.Stop()
 
-	srcAddr := t.gnbIP + ":2152"
-	dstAddr := t.upfN3IP + ":2152"
+	dstAddr := &net.UDPAddr{IP: net.ParseIP(t.upfN3IP), Port: 2152}
 
 	seq := 1
 	icmpData := []byte("upf-tester-icmp-payload")
@@ -129,29 +315,22 @@ func (t *ICMPTest) run() {
 
 	timeout := time.After(time.Duration(t.config.Duration) * time.Second)
 
+send:
 	for {
 		select {
 		case <-t.stopChan:
 			log.Println("ICMP test stopped by user")
-			t.result.EndTime = time.Now()
-			t.result.Duration = t.result.EndTime.Sub(t.result.StartTime)
-			t.calculateResult()
+			t.finish()
 			return
 
 		case <-timeout:
 			log.Println("ICMP test completed (timeout)")
-			t.result.EndTime = time.Now()
-			t.result.Duration = t.result.EndTime.Sub(t.result.StartTime)
-			t.calculateResult()
-			return
+			break send
 
 		case <-ticker.C:
 			if t.config.PacketCount > 0 && t.result.PacketsSent >= t.config.PacketCount {
 				log.Println("ICMP test completed (packet count reached)")
-				t.result.EndTime = time.Now()
-				t.result.Duration = t.result.EndTime.Sub(t.result.StartTime)
-				t.calculateResult()
-				return
+				break send
 			}
 
 			// 构造 GTP+IP+ICMP 数据包
@@ -161,24 +340,25 @@ func (t *ICMPTest) run() {
 				continue
 			}
 
-			log.Printf("entry")
 			// 发送数据包
+			t.mu.Lock()
+			t.sentAt[seq] = time.Now()
+			t.mu.Unlock()
 			if t.config.UDSSocketPath != "" {
-				log.Printf("Sending UDS packet: %v", packet)
 				err = SendUDS(t.config.UDSSocketPath, packet)
-				if err != nil {
-					log.Printf("Send UDS packet failed: %v", err)
-					continue
-				}
 			} else {
-				err = SendUDPWithSrc(srcAddr, dstAddr, packet)
-				if err != nil {
-					log.Printf("Send UDP packet failed: %v", err)
-					continue
-				}
+				err = t.receiver.Send(packet, dstAddr)
+			}
+			if err != nil {
+				log.Printf("Send packet failed: %v", err)
+				t.mu.Lock()
+				delete(t.sentAt, seq)
+				t.mu.Unlock()
+				continue
 			}
 
 			t.result.PacketsSent++
+			t.result.BytesSent += len(packet) - gtpHeaderLen
 			seq++
 
 			if t.result.PacketsSent%10 == 0 {
@@ -186,15 +366,59 @@ func (t *ICMPTest) run() {
 			}
 		}
 	}
+
+	// 等待剩余的 Echo Reply
+	if t.receiver.Started() {
+		waitReplies(t.stopChan, t.allReplied)
+	}
+	t.finish()
 }
 
-// Stop 停止 ICMP 测试
+// handleReply 匹配下行 Echo Reply，按 ICMP ID 和序列号计算 RTT
+func (t *ICMPTest) handleReply(inner []byte, receivedAt time.Time) {
+	id, seq, ok := parseEchoReply(inner, t.dstIP, t.ueIP)
+	if !ok || id != icmpEchoID() {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	sentAt, ok := t.sentAt[seq]
+	if !ok {
+		return
+	}
+	if _, dup := t.rtts[seq]; dup {
+		return
+	}
+	t.rtts[seq] = receivedAt.Sub(sentAt)
+	t.bytesReceived += len(inner)
+}
+
+func (t *ICMPTest) allReplied() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.rtts) >= len(t.sentAt)
+}
+
+func (t *ICMPTest) finish() {
+	t.result.EndTime = time.Now()
+	t.result.Duration = t.result.EndTime.Sub(t.result.StartTime)
+	t.calculateResult()
+}
+
+// Stop 停止 ICMP 测试，可重复调用
 func (t *ICMPTest) Stop() error {
-	close(t.stopChan)
+	t.stopOnce.Do(func() { close(t.stopChan) })
 	<-t.doneChan
 	return nil
 }
 
+// Done 测试结束时关闭
+func (t *ICMPTest) Done() <-chan struct{} {
+	return t.doneChan
+}
+
 // GetResult 获取测试结果
 func (t *ICMPTest) GetResult() *DataPlaneTestResult {
 	return t.result
@@ -202,16 +426,35 @@ func (t *ICMPTest) GetResult() *DataPlaneTestResult {
 
 // calculateResult 计算测试结果
 func (t *ICMPTest) calculateResult() {
-	// TODO: 实现接收端统计，目前只统计发送
-	t.result.PacketsReceived = 0 // 需要接收端实现
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.result.PacketsReceived = len(t.rtts)
+	t.result.BytesReceived = t.bytesReceived
 	t.result.PacketsLost = t.result.PacketsSent - t.result.PacketsReceived
 
 	if t.result.PacketsSent > 0 {
 		t.result.PacketLossRate = float64(t.result.PacketsLost) / float64(t.result.PacketsSent) * 100
 	}
 
-	t.result.Success = t.result.PacketsSent > 0
+	var total time.Duration
+	for _, rtt := range t.rtts {
+		total += rtt
+		if t.result.MinLatency == 0 || rtt < t.result.MinLatency {
+			t.result.MinLatency = rtt
+		}
+		if rtt > t.result.MaxLatency {
+			t.result.MaxLatency = rtt
+		}
+	}
+	if len(t.rtts) > 0 {
+		t.result.AvgLatency = total / time.Duration(len(t.rtts))
+	}
+
+	// 收到应答即认为连通；UDS 模式下未绑定接收器时无法收到应答，只要求发送成功
+	t.result.Success = t.result.PacketsReceived > 0 || (!t.receiver.Started() && t.result.PacketsSent > 0)
 
-	log.Printf("ICMP Test Result: Sent=%d, Received=%d, Lost=%d, Loss Rate=%.2f%%",
-		t.result.PacketsSent, t.result.PacketsReceived, t.result.PacketsLost, t.result.PacketLossRate)
+	log.Printf("ICMP Test Result: Sent=%d, Received=%d, Lost=%d, Loss Rate=%.2f%%, RTT min/avg/max=%v/%v/%v",
+		t.result.PacketsSent, t.result.PacketsReceived, t.result.PacketsLost, t.result.PacketLossRate,
+		t.result.MinLatency, t.result.AvgLatency, t.result.MaxLatency)
 }
@@ -0,0 +1,13 @@
+package dataplane
+
+import "testing"
+
+func TestICMPTest_StopTwice(t *testing.T) {
+	config := &DataPlaneTestConfig{TestType: "icmp", Duration: 5, Interval: 10}
+	test := NewICMPTest(config, "127.0.2.1", "127.0.2.2", 1, 0, "10.45.0.2", "10.60.0.1")
+	if err := test.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	test.Stop()
+	test.Stop()
+}
@@ -0,0 +1,342 @@
+package dataplane
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// throughputBin 吞吐量统计的时间粒度
+const throughputBin = 10 * time.Millisecond
+
+// maxBurstPerTick 每个发送周期最多补发的包数，避免调度停顿后瞬间突发
+const maxBurstPerTick = 1000
+
+// RampConfig 阶梯升速配置，从 startMbps 起每 stepDuration 秒增加 stepMbps，直到目标速率
+type RampConfig struct {
+	StartMbps    float64 `yaml:"startMbps"`
+	StepMbps     float64 `yaml:"stepMbps"`
+	StepDuration int     `yaml:"stepDuration"` // 每级持续时间（秒）
+}
+
+// ThroughputWindow 一个滑动窗口内的速率，速率按内层 IP 包计算
+type ThroughputWindow struct {
+	Offset       time.Duration // 窗口起点相对测试开始的偏移
+	OfferedMbps  float64       // 测试端发出的上行速率
+	UplinkMbps   float64       // DN 仿真器收到的上行速率，未启用 DN 仿真器时为 0
+	DownlinkMbps float64       // 测试端收到的下行速率
+}
+
+// ThroughputTest 吞吐量测试，按目标速率 (或阶梯升速) 发送上行 UDP 流量，
+// 在 gNB 地址接收 DN 侧回送的下行流量，按滑动窗口统计发送速率和两个方向的实际速率。
+// 上行实际速率在 DN 仿真器处统计，未启用 DN 仿真器时无法测量
+type ThroughputTest struct {
+	config       *DataPlaneTestConfig
+	gnbIP        string
+	upfN3IP      string
+	teid         uint32 // 上行 TEID
+	downlinkTEID uint32 // 下行 TEID，0 表示不过滤
+	ueIP         string
+	dstIP        string
+	dn           *DNEmulator // 非 nil 时在 DN 侧统计上行实际速率
+	receiver     *Receiver
+	result       *DataPlaneTestResult
+	stopChan     chan struct{}
+	stopOnce     sync.Once
+	doneChan     chan struct{}
+
+	mu            sync.Mutex
+	startedAt     time.Time
+	sentBins      []int // 每个统计粒度内发出的字节数
+	uplinkBins    []int // 每个统计粒度内 DN 仿真器收到的上行字节数
+	receivedBins  []int // 每个统计粒度内收到的字节数
+	received      int
+	bytesReceived int
+}
+
+// NewThroughputTest 创建吞吐量测试，dn 为 nil 时不统计上行实际速率
+func NewThroughputTest(config *DataPlaneTestConfig, gnbIP, upfN3IP string, teid, downlinkTEID uint32, ueIP, dstIP string, dn *DNEmulator) *ThroughputTest {
+	return &ThroughputTest{
+		config:       config,
+		gnbIP:        gnbIP,
+		upfN3IP:      upfN3IP,
+		teid:         teid,
+		downlinkTEID: downlinkTEID,
+		ueIP:         ueIP,
+		dstIP:        dstIP,
+		dn:           dn,
+		stopChan:     make(chan struct{}),
+		doneChan:     make(chan struct{}),
+		result: &DataPlaneTestResult{
+			TestType:  "THROUGHPUT",
+			StartTime: time.Now(),
+		},
+	}
+}
+
+// Start 启动吞吐量测试
+func (t *ThroughputTest) Start() error {
+	log.Printf("Starting throughput test: UE IP=%s, TEID=%d, Downlink TEID=%d, Rate=%.2fMbps, Duration=%ds",
+		t.ueIP, t.teid, t.downlinkTEID, t.config.RateMbps, t.config.Duration)
+
+	t.receiver = NewReceiver(t.gnbIP, 2152, t.downlinkTEID, t.ueIP)
+	t.receiver.SetHandler(t.handlePacket)
+	if err := startReceiver(t.receiver, t.config.UDSSocketPath, t.result); err != nil {
+		return err
+	}
+
+	t.startedAt = time.Now()
+	if t.dn != nil {
+		t.dn.SetUplinkHandler(func(size int, receivedAt time.Time) {
+			t.record(&t.uplinkBins, receivedAt, size)
+		})
+	}
+	go t.run()
+
+	return nil
+}
+
+// rateAt 返回测试开始 elapsed 之后的目标速率 (Mbps)
+func (t *ThroughputTest) rateAt(elapsed time.Duration) float64 {
+	target := t.config.RateMbps
+	ramp := t.config.Ramp
+	if ramp == nil || ramp.StepDuration <= 0 {
+		return target
+	}
+
+	stage := int(elapsed / (time.Duration(ramp.StepDuration) * time.Second))
+	rate := ramp.StartMbps + float64(stage)*ramp.StepMbps
+	if rate > target {
+		return target
+	}
+	return rate
+}
+
+// run 按目标速率发送，每个周期按当前速率累计应发送的字节数
+func (t *ThroughputTest) run() {
+	defer close(t.doneChan)
+	defer t.receiver.Stop()
+
+	dstAddr := &net.UDPAddr{IP: net.ParseIP(t.upfN3IP), Port: 2152}
+	packetSize := 20 + 8 + t.config.PayloadSize
+	srcPort := uint16(t.config.SrcPort)
+	dstPort := uint16(t.config.DstPort)
+	var seq uint32
+	var credit float64 // 可发送的包数
+
+	ticker := time.NewTicker(time.Millisecond)
+	defer ticker.Stop()
+
+	timeout := time.After(time.Duration(t.config.Duration) * time.Second)
+	last := t.startedAt
+
+send:
+	for {
+		select {
+		case <-t.stopChan:
+			log.Println("Throughput test stopped by user")
+			t.finish()
+			return
+
+		case <-timeout:
+			log.Println("Throughput test completed (timeout)")
+			break send
+
+		case now := <-ticker.C:
+			rate := t.rateAt(now.Sub(t.startedAt))
+			credit += rate * 1e6 / 8 / float64(packetSize) * now.Sub(last).Seconds()
+			last = now
+			if credit > maxBurstPerTick {
+				credit = maxBurstPerTick
+			}
+
+			for ; credit >= 1; credit-- {
+				seq++
+				payload := make([]byte, t.config.PayloadSize)
+				copy(payload[0:4], udpProbeMagic)
+				binary.BigEndian.PutUint32(payload[6:10], seq)
+				binary.BigEndian.PutUint64(payload[10:18], uint64(time.Now().UnixNano()))
+
+				packet, err := BuildGTPIPUDPPacket(t.ueIP, t.dstIP, srcPort, dstPort, t.teid, payload)
+				if err != nil {
+					log.Printf("Build GTP+IP+UDP packet failed: %v", err)
+					t.finish()
+					return
+				}
+
+				if t.config.UDSSocketPath != "" {
+					err = SendUDS(t.config.UDSSocketPath, packet)
+				} else {
+					err = t.receiver.Send(packet, dstAddr)
+				}
+				if err != nil {
+					log.Printf("Send packet failed: %v", err)
+					continue
+				}
+
+				t.result.PacketsSent++
+				t.result.BytesSent += len(packet) - gtpHeaderLen
+				t.record(&t.sentBins, time.Now(), len(packet)-gtpHeaderLen)
+			}
+		}
+	}
+
+	if t.receiver.Started() {
+		waitReplies(t.stopChan, t.allReceived)
+	}
+	t.finish()
+}
+
+// record 将字节数累计到对应的统计粒度
+func (t *ThroughputTest) record(bins *[]int, at time.Time, size int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	index := int(at.Sub(t.startedAt) / throughputBin)
+	if index < 0 {
+		index = 0
+	}
+	for len(*bins) <= index {
+		*bins = append(*bins, 0)
+	}
+	(*bins)[index] += size
+}
+
+// handlePacket 统计下行回送的测试报文
+func (t *ThroughputTest) handlePacket(inner []byte, receivedAt time.Time) {
+	payload, ok := parseUDPPayload(inner, t.dstIP, t.ueIP)
+	if !ok || len(payload) < udpProbeHeaderLen || !bytes.Equal(payload[0:4], udpProbeMagic) {
+		return
+	}
+
+	t.record(&t.receivedBins, receivedAt, len(inner))
+
+	t.mu.Lock()
+	t.received++
+	t.bytesReceived += len(inner)
+	t.mu.Unlock()
+}
+
+func (t *ThroughputTest) allReceived() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.received >= t.result.PacketsSent
+}
+
+func (t *ThroughputTest) finish() {
+	t.result.EndTime = time.Now()
+	t.result.Duration = t.result.EndTime.Sub(t.result.StartTime)
+	t.calculateResult()
+}
+
+// Stop 停止吞吐量测试，可重复调用
+func (t *ThroughputTest) Stop() error {
+	t.stopOnce.Do(func() { close(t.stopChan) })
+	<-t.doneChan
+	return nil
+}
+
+// Done 测试结束时关闭
+func (t *ThroughputTest) Done() <-chan struct{} {
+	return t.doneChan
+}
+
+// GetResult 获取测试结果
+func (t *ThroughputTest) GetResult() *DataPlaneTestResult {
+	return t.result
+}
+
+// calculateResult 计算测试结果和滑动窗口速率
+func (t *ThroughputTest) calculateResult() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.result.PacketsReceived = t.received
+	t.result.BytesReceived = t.bytesReceived
+	t.result.PacketsLost = t.result.PacketsSent - t.received
+	if t.result.PacketsLost < 0 {
+		t.result.PacketsLost = 0
+	}
+	if t.result.PacketsSent > 0 {
+		t.result.PacketLossRate = float64(t.result.PacketsLost) / float64(t.result.PacketsSent) * 100
+	}
+
+	// 只统计发送期间的完整窗口
+	window := time.Duration(t.config.WindowMs) * time.Millisecond
+	step := time.Duration(t.config.WindowStepMs) * time.Millisecond
+	sending := time.Duration(len(t.sentBins)) * throughputBin
+	t.result.Windows = nil
+	for offset := time.Duration(0); offset+window <= sending; offset += step {
+		t.result.Windows = append(t.result.Windows, ThroughputWindow{
+			Offset:       offset,
+			OfferedMbps:  windowMbps(t.sentBins, offset, window),
+			UplinkMbps:   windowMbps(t.uplinkBins, offset, window),
+			DownlinkMbps: windowMbps(t.receivedBins, offset, window),
+		})
+	}
+
+	if sending > 0 {
+		t.result.Throughput = windowMbps(t.receivedBins, 0, sending)
+	}
+
+	t.result.Success = t.received > 0 || (!t.receiver.Started() && t.result.PacketsSent > 0)
+
+	log.Printf("Throughput Test Result: Sent=%d, Received=%d, Lost=%d, Loss Rate=%.2f%%, Throughput=%.2fMbps, Windows=%d",
+		t.result.PacketsSent, t.result.PacketsReceived, t.result.PacketsLost, t.result.PacketLossRate,
+		t.result.Throughput, len(t.result.Windows))
+}
+
+// windowMbps 计算 [offset, offset+window) 内的速率
+func windowMbps(bins []int, offset, window time.Duration) float64 {
+	first := int(offset / throughputBin)
+	last := int((offset + window) / throughputBin)
+	var total int
+	for i := first; i < last && i < len(bins); i++ {
+		total += bins[i]
+	}
+	return float64(total) * 8 / window.Seconds() / 1e6
+}
+
+// VerifyMBR 按滑动窗口分别核对上行和下行实际速率是否受限于各自的 MBR (Mbps，0 表示不核对该方向)：
+// 任一窗口的实际速率都不应超过 MBR 的容差上限；进入 UPF 的速率超过该上限的窗口，实际速率也不应低于容差下限。
+// 上行进入 UPF 的是测试端发送的流量，下行进入 UPF 的是 DN 仿真器回送的流量，即上行实际速率
+func (r *DataPlaneTestResult) VerifyMBR(ulMbps, dlMbps, tolerance float64) []string {
+	if len(r.Windows) == 0 {
+		return []string{"mbr: no complete throughput window"}
+	}
+
+	var mismatches []string
+	for _, w := range r.Windows {
+		if ulMbps > 0 {
+			if mismatch := verifyWindowMBR("uplink", w.Offset, w.OfferedMbps, w.UplinkMbps, ulMbps, tolerance); mismatch != "" {
+				mismatches = append(mismatches, mismatch)
+			}
+		}
+		if dlMbps > 0 {
+			if mismatch := verifyWindowMBR("downlink", w.Offset, w.UplinkMbps, w.DownlinkMbps, dlMbps, tolerance); mismatch != "" {
+				mismatches = append(mismatches, mismatch)
+			}
+		}
+	}
+	return mismatches
+}
+
+// verifyWindowMBR 核对一个窗口内单个方向的实际速率，offered 为进入 UPF 的速率，符合时返回空字符串
+func verifyWindowMBR(direction string, offset time.Duration, offered, delivered, mbrMbps, tolerance float64) string {
+	upper := mbrMbps * (1 + tolerance/100)
+	lower := mbrMbps * (1 - tolerance/100)
+
+	switch {
+	case delivered > upper:
+		return fmt.Sprintf("mbr: %s window at %v delivered %.3fMbps, expect at most %.3fMbps",
+			direction, offset, delivered, upper)
+	case offered > upper && delivered < lower:
+		return fmt.Sprintf("mbr: %s window at %v delivered %.3fMbps with %.3fMbps offered, expect at least %.3fMbps",
+			direction, offset, delivered, offered, lower)
+	}
+	return ""
+}
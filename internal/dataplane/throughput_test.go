@@ -0,0 +1,105 @@
+package dataplane
+
+import (
+	"testing"
+	"time"
+)
+
+func TestThroughputTest_RateAt(t *testing.T) {
+	test := &ThroughputTest{config: &DataPlaneTestConfig{
+		RateMbps: 10,
+		Ramp:     &RampConfig{StartMbps: 2, StepMbps: 3, StepDuration: 1},
+	}}
+
+	tests := []struct {
+		elapsed time.Duration
+		want    float64
+	}{
+		{elapsed: 0, want: 2},
+		{elapsed: 999 * time.Millisecond, want: 2},
+		{elapsed: time.Second, want: 5},
+		{elapsed: 2500 * time.Millisecond, want: 8},
+		{elapsed: 3 * time.Second, want: 10},
+		{elapsed: time.Minute, want: 10},
+	}
+
+	for _, tt := range tests {
+		if got := test.rateAt(tt.elapsed); got != tt.want {
+			t.Errorf("rateAt(%v) = %v, want %v", tt.elapsed, got, tt.want)
+		}
+	}
+}
+
+func TestDataPlaneTestResult_VerifyMBR(t *testing.T) {
+	// MBR-UL 10Mbps，MBR-DL 5Mbps，容差 10%
+	tests := []struct {
+		name    string
+		windows []ThroughputWindow
+		wantErr bool
+	}{
+		{
+			name:    "each direction policed at its mbr",
+			windows: []ThroughputWindow{{OfferedMbps: 20, UplinkMbps: 10.5, DownlinkMbps: 5.2}, {OfferedMbps: 20, UplinkMbps: 9.4, DownlinkMbps: 4.6}},
+		},
+		{
+			name:    "offered below both mbrs",
+			windows: []ThroughputWindow{{OfferedMbps: 4, UplinkMbps: 4, DownlinkMbps: 4}},
+		},
+		{
+			name:    "uplink exceeds mbr-ul",
+			windows: []ThroughputWindow{{OfferedMbps: 20, UplinkMbps: 12, DownlinkMbps: 5}},
+			wantErr: true,
+		},
+		{
+			name:    "uplink over-policed",
+			windows: []ThroughputWindow{{OfferedMbps: 20, UplinkMbps: 6, DownlinkMbps: 5}},
+			wantErr: true,
+		},
+		{
+			name:    "downlink exceeds mbr-dl",
+			windows: []ThroughputWindow{{OfferedMbps: 20, UplinkMbps: 10, DownlinkMbps: 10}},
+			wantErr: true,
+		},
+		{
+			name:    "downlink over-policed",
+			windows: []ThroughputWindow{{OfferedMbps: 20, UplinkMbps: 10, DownlinkMbps: 3}},
+			wantErr: true,
+		},
+		{
+			name:    "no windows",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := &DataPlaneTestResult{Windows: tt.windows}
+			mismatches := result.VerifyMBR(10, 5, 10)
+			if (len(mismatches) > 0) != tt.wantErr {
+				t.Errorf("VerifyMBR() = %v, wantErr %v", mismatches, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseDataPlaneTestConfigAs_VerifyMbr(t *testing.T) {
+	if _, err := ParseDataPlaneTestConfigAs([]byte("rateMbps: 8\nverifyMbr: true\n"), "throughput"); err == nil {
+		t.Error("expect verifyMbr without dnEmulator rejected")
+	}
+	if _, err := ParseDataPlaneTestConfigAs([]byte("rateMbps: 8\nverifyMbr: true\ndnEmulator: true\n"), "throughput"); err != nil {
+		t.Errorf("ParseDataPlaneTestConfigAs() error = %v", err)
+	}
+}
+
+func TestThroughputTest_StopTwice(t *testing.T) {
+	config, err := ParseDataPlaneTestConfigAs([]byte("duration: 5\nrateMbps: 1\n"), "throughput")
+	if err != nil {
+		t.Fatalf("ParseDataPlaneTestConfigAs() error = %v", err)
+	}
+	test := NewThroughputTest(config, "127.0.2.1", "127.0.2.2", 1, 0, "10.45.0.2", "10.60.0.1", nil)
+	if err := test.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	test.Stop()
+	test.Stop()
+}
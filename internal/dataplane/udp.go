@@ -0,0 +1,353 @@
+package dataplane
+
+import (
+	"bytes"
+	"encoding/binary"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// udpProbeMagic UDP 测试负载标识
+var udpProbeMagic = []byte("UPFT")
+
+// udpProbeHeaderLen 负载头部长度: magic(4) + 流编号(2) + 序列号(4) + 发送时间戳(8)
+const udpProbeHeaderLen = 18
+
+// udpFlowStats 单条流的接收统计
+type udpFlowStats struct {
+	seen        map[uint32]bool
+	maxSeq      uint32
+	hasSeq      bool
+	lastTransit time.Duration
+	hasTransit  bool
+	jitter      float64 // 纳秒
+}
+
+// UDPTest UDP 测试，经 N3 发送带序列号和时间戳的上行 UDP 报文，
+// 在 gNB 地址接收 DN 侧回送的下行报文并统计丢包、乱序、重复、往返时延和抖动。
+// 下行测试由 DN 侧发起，时延为单向时延
+type UDPTest struct {
+	config       *DataPlaneTestConfig
+	gnbIP        string
+	upfN3IP      string
+	teid         uint32 // 上行 TEID
+	downlinkTEID uint32 // 下行 TEID，0 表示不过滤
+	ueIP         string
+	dstIP        string
+	dn           *DNEmulator // 非 nil 时为下行测试，由 DN 仿真器发起流量
+	receiver     *Receiver
+	result       *DataPlaneTestResult
+	stopChan     chan struct{}
+	stopOnce     sync.Once
+	doneChan     chan struct{}
+
+	mu            sync.Mutex
+	flows         []*udpFlowStats
+	received      int
+	bytesReceived int
+	duplicates    int
+	reordered     int
+	latencySum    time.Duration
+	minLatency    time.Duration
+	maxLatency    time.Duration
+}
+
+// NewUDPTest 创建 UDP 测试
+func NewUDPTest(config *DataPlaneTestConfig, gnbIP, upfN3IP string, teid, downlinkTEID uint32, ueIP, dstIP string) *UDPTest {
+	flows := make([]*udpFlowStats, config.FlowCount)
+	for i := range flows {
+		flows[i] = &udpFlowStats{seen: make(map[uint32]bool)}
+	}
+
+	return &UDPTest{
+		config:       config,
+		gnbIP:        gnbIP,
+		upfN3IP:      upfN3IP,
+		teid:         teid,
+		downlinkTEID: downlinkTEID,
+		ueIP:         ueIP,
+		dstIP:        dstIP,
+		flows:        flows,
+		stopChan:     make(chan struct{}),
+		doneChan:     make(chan struct{}),
+		result: &DataPlaneTestResult{
+			TestType:  "UDP",
+			StartTime: time.Now(),
+			RoundTrip: true,
+		},
+	}
+}
+
+// NewDownlinkTest 创建下行测试，由 DN 仿真器 dn 从 dnIP 向 UE 发送带序列号和时间戳的 UDP 报文，
+// 在 gNB 地址接收并统计丢包、乱序、重复、时延和抖动，不经过上行
+func NewDownlinkTest(config *DataPlaneTestConfig, gnbIP string, downlinkTEID uint32, ueIP, dnIP string, dn *DNEmulator) *UDPTest {
+	t := NewUDPTest(config, gnbIP, "", 0, downlinkTEID, ueIP, dnIP)
+	t.dn = dn
+	t.result.TestType = "DOWNLINK"
+	t.result.RoundTrip = false
+	return t
+}
+
+// Start 启动 UDP 测试
+func (t *UDPTest) Start() error {
+	log.Printf("Starting UDP test: UE IP=%s, TEID=%d, Downlink TEID=%d, Flows=%d, Duration=%ds",
+		t.ueIP, t.teid, t.downlinkTEID, t.config.FlowCount, t.config.Duration)
+
+	t.receiver = NewReceiver(t.gnbIP, 2152, t.downlinkTEID, t.ueIP)
+	// 下行测试只能经接收器收包，绑定失败总是致命的
+	udsSocketPath := t.config.UDSSocketPath
+	if t.dn != nil {
+		udsSocketPath = ""
+	}
+	t.receiver.SetHandler(t.handlePacket)
+	if err := startReceiver(t.receiver, udsSocketPath, t.result); err != nil {
+		return err
+	}
+
+	go t.run()
+
+	return nil
+}
+
+// run 按配置速率发送，各条流轮流发送
+func (t *UDPTest) run() {
+	defer close(t.doneChan)
+	defer t.receiver.Stop()
+
+	dstAddr := &net.UDPAddr{IP: net.ParseIP(t.upfN3IP), Port: 2152}
+	packetSize := 20 + 8 + t.config.PayloadSize
+	pps := t.config.packetRate(packetSize)
+	seqs := make([]uint32, t.config.FlowCount)
+
+	ticker := time.NewTicker(time.Millisecond)
+	defer ticker.Stop()
+
+	timeout := time.After(time.Duration(t.config.Duration) * time.Second)
+	start := time.Now()
+
+send:
+	for {
+		select {
+		case <-t.stopChan:
+			log.Println("UDP test stopped by user")
+			t.finish()
+			return
+
+		case <-timeout:
+			log.Println("UDP test completed (timeout)")
+			break send
+
+		case <-ticker.C:
+			due := int(time.Since(start).Seconds()*pps) + 1
+			if t.config.PacketCount > 0 && due > t.config.PacketCount {
+				due = t.config.PacketCount
+			}
+
+			for t.result.PacketsSent < due {
+				flow := t.result.PacketsSent % t.config.FlowCount
+				seqs[flow]++
+
+				payload := t.buildPayload(flow, seqs[flow])
+				if t.dn != nil {
+					if err := t.dn.SendUDP(t.ueIP, uint16(t.config.DstPort), uint16(t.config.SrcPort+flow), payload); err != nil {
+						log.Printf("Send downlink packet failed: %v", err)
+					}
+					t.result.PacketsSent++
+					t.result.BytesSent += 20 + 8 + len(payload)
+					continue
+				}
+
+				packet, err := BuildGTPIPUDPPacket(t.ueIP, t.dstIP,
+					uint16(t.config.SrcPort+flow), uint16(t.config.DstPort), t.teid, payload)
+				if err != nil {
+					log.Printf("Build GTP+IP+UDP packet failed: %v", err)
+					t.finish()
+					return
+				}
+
+				if t.config.UDSSocketPath != "" {
+					err = SendUDS(t.config.UDSSocketPath, packet)
+				} else {
+					err = t.receiver.Send(packet, dstAddr)
+				}
+				if err != nil {
+					log.Printf("Send packet failed: %v", err)
+				}
+
+				t.result.PacketsSent++
+				t.result.BytesSent += len(packet) - gtpHeaderLen
+			}
+
+			if t.config.PacketCount > 0 && t.result.PacketsSent >= t.config.PacketCount {
+				log.Println("UDP test completed (packet count reached)")
+				break send
+			}
+		}
+	}
+
+	if t.receiver.Started() {
+		waitReplies(t.stopChan, t.allReceived)
+	}
+	t.finish()
+}
+
+// buildPayload 构造带流编号、序列号和发送时间戳的负载
+func (t *UDPTest) buildPayload(flow int, seq uint32) []byte {
+	payload := make([]byte, t.config.PayloadSize)
+	copy(payload[0:4], udpProbeMagic)
+	binary.BigEndian.PutUint16(payload[4:6], uint16(flow))
+	binary.BigEndian.PutUint32(payload[6:10], seq)
+	binary.BigEndian.PutUint64(payload[10:18], uint64(time.Now().UnixNano()))
+	return payload
+}
+
+// handlePacket 解析下行 UDP 报文并更新统计
+func (t *UDPTest) handlePacket(inner []byte, receivedAt time.Time) {
+	payload, ok := parseUDPPayload(inner, t.dstIP, t.ueIP)
+	if !ok || len(payload) < udpProbeHeaderLen || !bytes.Equal(payload[0:4], udpProbeMagic) {
+		return
+	}
+
+	flow := int(binary.BigEndian.Uint16(payload[4:6]))
+	seq := binary.BigEndian.Uint32(payload[6:10])
+	sentAt := time.Unix(0, int64(binary.BigEndian.Uint64(payload[10:18])))
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if flow >= len(t.flows) {
+		return
+	}
+	stats := t.flows[flow]
+
+	if stats.seen[seq] {
+		t.duplicates++
+		return
+	}
+	stats.seen[seq] = true
+
+	if stats.hasSeq && seq < stats.maxSeq {
+		t.reordered++
+	} else {
+		stats.maxSeq = seq
+		stats.hasSeq = true
+	}
+
+	latency := receivedAt.Sub(sentAt)
+	t.latencySum += latency
+	if t.received == 0 || latency < t.minLatency {
+		t.minLatency = latency
+	}
+	if latency > t.maxLatency {
+		t.maxLatency = latency
+	}
+
+	// RFC 3550 6.4.1: J += (|D(i-1,i)| - J) / 16
+	if stats.hasTransit {
+		d := latency - stats.lastTransit
+		if d < 0 {
+			d = -d
+		}
+		stats.jitter += (float64(d) - stats.jitter) / 16
+	}
+	stats.lastTransit = latency
+	stats.hasTransit = true
+
+	t.received++
+	t.bytesReceived += len(inner)
+}
+
+func (t *UDPTest) allReceived() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.received >= t.result.PacketsSent
+}
+
+func (t *UDPTest) finish() {
+	t.result.EndTime = time.Now()
+	t.result.Duration = t.result.EndTime.Sub(t.result.StartTime)
+	t.calculateResult()
+}
+
+// Stop 停止 UDP 测试，可重复调用
+func (t *UDPTest) Stop() error {
+	t.stopOnce.Do(func() { close(t.stopChan) })
+	<-t.doneChan
+	return nil
+}
+
+// Done 测试结束时关闭
+func (t *UDPTest) Done() <-chan struct{} {
+	return t.doneChan
+}
+
+// GetResult 获取测试结果
+func (t *UDPTest) GetResult() *DataPlaneTestResult {
+	return t.result
+}
+
+// calculateResult 计算测试结果
+func (t *UDPTest) calculateResult() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.result.PacketsReceived = t.received
+	t.result.BytesReceived = t.bytesReceived
+	t.result.Duplicates = t.duplicates
+	t.result.Reordered = t.reordered
+	t.result.PacketsLost = t.result.PacketsSent - t.received
+	if t.result.PacketsLost < 0 {
+		t.result.PacketsLost = 0
+	}
+	if t.result.PacketsSent > 0 {
+		t.result.PacketLossRate = float64(t.result.PacketsLost) / float64(t.result.PacketsSent) * 100
+	}
+
+	if t.received > 0 {
+		t.result.AvgLatency = t.latencySum / time.Duration(t.received)
+		t.result.MinLatency = t.minLatency
+		t.result.MaxLatency = t.maxLatency
+	}
+
+	var jitter float64
+	for _, stats := range t.flows {
+		jitter += stats.jitter
+	}
+	t.result.Jitter = time.Duration(jitter / float64(len(t.flows)))
+
+	if seconds := t.result.Duration.Seconds(); seconds > 0 {
+		t.result.Throughput = float64(t.bytesReceived) * 8 / seconds / 1e6
+	}
+
+	t.result.Success = t.received > 0 || (!t.receiver.Started() && t.result.PacketsSent > 0)
+
+	latencyLabel := "Latency"
+	if t.result.RoundTrip {
+		latencyLabel = "RTT"
+	}
+	log.Printf("UDP Test Result: Sent=%d, Received=%d, Lost=%d, Loss Rate=%.2f%%, Reordered=%d, Duplicates=%d, %s min/avg/max=%v/%v/%v, Jitter=%v",
+		t.result.PacketsSent, t.result.PacketsReceived, t.result.PacketsLost, t.result.PacketLossRate,
+		t.result.Reordered, t.result.Duplicates, latencyLabel, t.result.MinLatency, t.result.AvgLatency, t.result.MaxLatency, t.result.Jitter)
+}
+
+// parseUDPPayload 解析内层 IPv4 UDP 报文，校验源 (DN) 和目的 (UE) 地址，返回 UDP 负载
+func parseUDPPayload(packet []byte, srcIP, dstIP string) ([]byte, bool) {
+	if len(packet) < 20 || packet[0]>>4 != 4 {
+		return nil, false
+	}
+	ihl := int(packet[0]&0x0f) * 4
+	if ihl < 20 || len(packet) < ihl+8 || packet[9] != protocolUDP {
+		return nil, false
+	}
+	if !net.IP(packet[12:16]).Equal(net.ParseIP(srcIP)) || !net.IP(packet[16:20]).Equal(net.ParseIP(dstIP)) {
+		return nil, false
+	}
+
+	length := int(binary.BigEndian.Uint16(packet[ihl+4 : ihl+6]))
+	if length < 8 || len(packet) < ihl+length {
+		return nil, false
+	}
+	return packet[ihl+8 : ihl+length], true
+}
@@ -0,0 +1,68 @@
+package dataplane
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseUDPPayload(t *testing.T) {
+	payload := []byte("upf-tester-udp-payload")
+	packet, err := BuildIPUDPPacket("10.60.0.1", "10.45.0.2", 5001, 10000, payload)
+	if err != nil {
+		t.Fatalf("BuildIPUDPPacket() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		srcIP  string
+		dstIP  string
+		packet []byte
+		wantOK bool
+	}{
+		{name: "matching addresses", srcIP: "10.60.0.1", dstIP: "10.45.0.2", packet: packet, wantOK: true},
+		{name: "unexpected source", srcIP: "10.60.0.2", dstIP: "10.45.0.2", packet: packet, wantOK: false},
+		{name: "truncated", srcIP: "10.60.0.1", dstIP: "10.45.0.2", packet: packet[:24], wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseUDPPayload(tt.packet, tt.srcIP, tt.dstIP)
+			if ok != tt.wantOK {
+				t.Fatalf("parseUDPPayload() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && !bytes.Equal(got, payload) {
+				t.Errorf("parseUDPPayload() = %q, want %q", got, payload)
+			}
+		})
+	}
+}
+
+func TestParseDataPlaneTestConfigAs_PayloadSize(t *testing.T) {
+	data := []byte("payloadSize: 10\nrateMbps: 1\n")
+
+	if _, err := ParseDataPlaneTestConfig(data); err != nil {
+		t.Fatalf("ParseDataPlaneTestConfig() error = %v, want nil without testType", err)
+	}
+	for _, testType := range []string{"udp", "throughput", "downlink", "buffering"} {
+		if _, err := ParseDataPlaneTestConfigAs(data, testType); err == nil {
+			t.Errorf("ParseDataPlaneTestConfigAs(%s) error = nil, want payloadSize rejected", testType)
+		}
+	}
+	// testType 与步骤动作不一致时按动作校验
+	if _, err := ParseDataPlaneTestConfigAs([]byte("testType: icmp\npayloadSize: 10\n"), "udp"); err == nil {
+		t.Error("expect step action to override testType")
+	}
+	if _, err := ParseDataPlaneTestConfigAs(data, "icmp"); err != nil {
+		t.Errorf("ParseDataPlaneTestConfigAs(icmp) error = %v", err)
+	}
+}
+
+func TestUDPTest_StopTwice(t *testing.T) {
+	config := &DataPlaneTestConfig{TestType: "udp", Duration: 5, Interval: 10, PayloadSize: 64}
+	test := NewUDPTest(config, "127.0.2.1", "127.0.2.2", 1, 0, "10.45.0.2", "10.60.0.1")
+	if err := test.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	test.Stop()
+	test.Stop()
+}
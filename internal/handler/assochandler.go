@@ -1,80 +1,89 @@
 package handler
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"net"
-	"os"
-	"time"
-	"upftester/internal/network"
-	"upftester/internal/util"
 
 	"github.com/wmnsk/go-pfcp/ie"
 	"github.com/wmnsk/go-pfcp/message"
 )
 
-var StartTime time.Time
+// Associate 以本端 N4 地址为 Node ID 向 UPF 发起 PFCP Association Setup，UPF 拒绝或未响应时返回错误。
+// 关联建立后 UPF 的 Heartbeat Request 由分发器应答
+func (t *Tester) Associate(ctx context.Context) error {
+	req := t.associationRequest("association_setup", &AssociationConfig{})
 
-func SendPFCPAssociationRequest(localN4Ip, upfN4Ip string, udpTransport *network.UDPTransport) error {
-
-	addr, err := net.ResolveUDPAddr("udp", net.JoinHostPort(upfN4Ip, "8805"))
+	log.Printf("Sending association setup request to %s", t.remoteAddr)
+	txn, err := t.request(req)
 	if err != nil {
-		log.Println("解析 UDP 地址失敗:", err)
 		return err
 	}
+	reply, err := txn.WaitContext(ctx)
+	if err != nil {
+		return fmt.Errorf("wait association setup response failed: %w", err)
+	}
+	if reply.MessageType != message.MsgTypeAssociationSetupResponse {
+		return fmt.Errorf("expect association setup response, got message type %d", reply.MessageType)
+	}
+
+	resp, err := message.ParseAssociationSetupResponse(reply.Payload)
+	if err != nil {
+		return fmt.Errorf("association setup response parse failed: %w", err)
+	}
+	if err := acceptedCause(resp.Cause); err != nil {
+		return fmt.Errorf("association setup rejected, %w", err)
+	}
 
-	StartTime = time.Now()
+	log.Printf("Association setup with %s accepted", t.remoteAddr)
+	return nil
+}
 
-	msg := message.NewAssociationSetupRequest(util.GlobalSeqNumber.Inc(),
-		ie.NewNodeID(localN4Ip, "", ""),
-		ie.NewRecoveryTimeStamp(StartTime),
-	)
+// releaseAssociation 向 UPF 发送 Association Release Request，UPF 释放关联时删除本端的全部会话
+func (t *Tester) releaseAssociation() error {
+	req := t.associationRequest("association_release", &AssociationConfig{})
 
-	data, err := msg.Marshal()
+	log.Printf("Sending association release request to %s", t.remoteAddr)
+	txn, err := t.request(req)
 	if err != nil {
-		log.Println("消息編碼失敗:", err)
 		return err
 	}
+	reply, err := txn.Wait()
+	if err != nil {
+		return fmt.Errorf("wait association release response failed: %w", err)
+	}
+	if reply.MessageType != message.MsgTypeAssociationReleaseResponse {
+		return fmt.Errorf("expect association release response, got message type %d", reply.MessageType)
+	}
 
-	ch := make(chan *PFCPMessage)
-	GetPFCPDispatcher().Register(0, ch)
-	go func() {
-		for {
-			select {
-			case msg := <-ch:
-				if msg.MessageType == message.MsgTypeAssociationSetupResponse {
-					resp, err := message.ParseAssociationSetupResponse(msg.Payload)
-					if err != nil {
-						log.Println("消息解析失敗:", err)
-						os.Exit(1)
-					}
-
-					value, err := resp.Cause.ValueAsUint8()
-					if err != nil {
-						log.Println("消息解析失敗:", err)
-						os.Exit(1)
-					}
+	resp, err := message.ParseAssociationReleaseResponse(reply.Payload)
+	if err != nil {
+		return fmt.Errorf("association release response parse failed: %w", err)
+	}
+	if err := acceptedCause(resp.Cause); err != nil {
+		return fmt.Errorf("association release rejected, %w", err)
+	}
 
-					if value != ie.CauseRequestAccepted {
-						log.Println("association create success")
-						os.Exit(1)
-					}
-				}
+	log.Printf("Association with %s released", t.remoteAddr)
+	return nil
+}
 
-				if msg.MessageType == message.MsgTypeHeartbeatRequest {
-					msg := message.NewHeartbeatResponse(msg.Sequence,
-						ie.NewRecoveryTimeStamp(StartTime),
-					)
-					data, err := msg.Marshal()
-					if err != nil {
-						log.Println("消息編碼失敗:", err)
-						return
-					}
-					udpTransport.Send(data, addr)
-				}
-			}
-		}
-	}()
+// localNodeID 返回本端 Node ID，nodeID 为空时使用本端 N4 地址
+func (t *Tester) localNodeID(nodeID string) *ie.IE {
+	if nodeID == "" {
+		nodeID = t.cfg.Basic.LocalN4Ip
+	}
+	return ie.NewNodeIDHeuristic(nodeID)
+}
 
-	udpTransport.Send(data, addr)
-	return nil
+// handleHeartbeat 应答 UPF 发起的 Heartbeat Request
+func (d *PFCPDispatcher) handleHeartbeat(msg *PFCPMessage, addr *net.UDPAddr) {
+	resp := message.NewHeartbeatResponse(msg.Sequence, ie.NewRecoveryTimeStamp(d.recoveryTime))
+	data := make([]byte, resp.MarshalLen())
+	if err := resp.MarshalTo(data); err != nil {
+		log.Printf("marshal heartbeat response failed: %v", err)
+		return
+	}
+	d.transport.Send(data, addr)
 }
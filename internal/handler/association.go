@@ -0,0 +1,170 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"upftester/internal/report"
+
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+	"gopkg.in/yaml.v3"
+)
+
+// upFunctionFeatures UP Function Features 标志位，按 [字节, 掩码] 排列 (3GPP TS 29.244 8.2.25)
+var upFunctionFeatures = map[string][2]uint8{
+	"BUCP":  {0, 0x01},
+	"DDND":  {0, 0x02},
+	"DLBD":  {0, 0x04},
+	"TRST":  {0, 0x08},
+	"FTUP":  {0, 0x10},
+	"PFDM":  {0, 0x20},
+	"HEEU":  {0, 0x40},
+	"TREU":  {0, 0x80},
+	"EMPU":  {1, 0x01},
+	"PDIU":  {1, 0x02},
+	"UDBC":  {1, 0x04},
+	"QUOAC": {1, 0x08},
+	"TRACE": {1, 0x10},
+	"FRRT":  {1, 0x20},
+	"PFDE":  {1, 0x40},
+	"EPFAR": {1, 0x80},
+	"DPDRA": {2, 0x01},
+	"ADPDP": {2, 0x02},
+	"UEIP":  {2, 0x04},
+	"SSET":  {2, 0x08},
+	"MNOP":  {2, 0x10},
+	"MTE":   {2, 0x20},
+	"BUNDL": {2, 0x40},
+	"GCOM":  {2, 0x80},
+	"MPAS":  {3, 0x01},
+	"RTTL":  {3, 0x02},
+	"VTIME": {3, 0x04},
+	"NORP":  {3, 0x08},
+	"IPTV":  {3, 0x10},
+	"IP6PL": {3, 0x20},
+	"TSCU":  {3, 0x40},
+	"MPTCP": {3, 0x80},
+}
+
+// associationResponseTypes 各 association 步骤期望的响应类型
+var associationResponseTypes = map[string]uint8{
+	"association_setup":   message.MsgTypeAssociationSetupResponse,
+	"association_update":  message.MsgTypeAssociationUpdateResponse,
+	"association_release": message.MsgTypeAssociationReleaseResponse,
+}
+
+// AssociationConfig association_setup、association_update 和 association_release 步骤的请求参数，
+// 由步骤的 path 指定，未配置 path 时全部使用默认值
+type AssociationConfig struct {
+	NodeID             string `yaml:"nodeId"`             // 本端 Node ID (IP 地址或 FQDN)，默认 localN4Ip
+	CPFunctionFeatures *uint8 `yaml:"cpFunctionFeatures"` // CP Function Features，如 0x01 (LOAD)，未配置时不携带；association_release 忽略
+}
+
+// loadAssociationConfig 渲染并解析 association 步骤文件，path 为空时返回默认配置
+func loadAssociationConfig(path string, td *TemplateData) (*AssociationConfig, error) {
+	cfg := &AssociationConfig{}
+	if path == "" {
+		return cfg, nil
+	}
+	data, err := renderFile(path, td)
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("unmarshal association config %s failed: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// loadAssociationStep 校验响应断言并加载请求参数
+func loadAssociationStep(step TestStep, td *TemplateData) (interface{}, error) {
+	if err := step.Expect.validate(); err != nil {
+		return nil, fmt.Errorf("invalid expect: %w", err)
+	}
+	return loadAssociationConfig(step.Path, td)
+}
+
+// associationRequest 按步骤类型生成 Association Setup、Update 或 Release Request
+func (t *Tester) associationRequest(stepType string, cfg *AssociationConfig) message.Message {
+	nodeID := t.localNodeID(cfg.NodeID)
+	var features []*ie.IE
+	if cfg.CPFunctionFeatures != nil {
+		features = append(features, ie.NewCPFunctionFeatures(*cfg.CPFunctionFeatures))
+	}
+
+	switch stepType {
+	case "association_setup":
+		return message.NewAssociationSetupRequest(0, append([]*ie.IE{nodeID, ie.NewRecoveryTimeStamp(t.recoveryTime)}, features...)...)
+	case "association_update":
+		return message.NewAssociationUpdateRequest(0, append([]*ie.IE{nodeID}, features...)...)
+	default:
+		return message.NewAssociationReleaseRequest(0, nodeID)
+	}
+}
+
+// runAssociation 执行 association_setup、association_update 或 association_release 步骤：发送请求并等待响应，
+// 断言响应并捕获 UPF 的 Node ID、UP Function Features 和 Recovery Time Stamp。
+// 捕获的变量与会话无关，测试用例集的后续步骤中都可引用。UPF 接受 Association Release 后会删除本端的全部会话，
+// 因此同时移除本端的会话上下文
+func (r *testRunner) runAssociation(testcase TestCase, step *report.StepResult) error {
+	cfg, err := loadAssociationConfig(testcase.Path, r.templateData(testcase))
+	if err != nil {
+		return err
+	}
+	name := strings.ReplaceAll(testcase.Type, "_", " ")
+
+	log.Printf("Sending %s request to %s", name, r.t.remoteAddr)
+	reply, err := (&StepContext{r: r}).Request(r.t.associationRequest(testcase.Type, cfg), step)
+	if err != nil {
+		return fmt.Errorf("wait %s response failed: %w", name, err)
+	}
+	if want := associationResponseTypes[testcase.Type]; reply.MessageType != want {
+		return &StepFailure{
+			Step:       testcase.Step,
+			Type:       testcase.Type,
+			Mismatches: []string{fmt.Sprintf("message type: expect %d, got %d", want, reply.MessageType)},
+		}
+	}
+
+	resp, err := message.Parse(reply.Payload)
+	if err != nil {
+		return fmt.Errorf("%s response parse failed: %w", name, err)
+	}
+	v := newResponseView(resp)
+	if v.Cause != nil {
+		if cause, err := v.Cause.Cause(); err == nil {
+			step.SetCause(cause)
+		}
+	}
+
+	if testcase.Type == "association_release" && acceptedCause(v.Cause) == nil {
+		// 其他测试用例集可能仍在运行，只移除本测试用例集建立的会话
+		removed := r.t.forgetSessions(r)
+		r.drainReports()
+		log.Printf("Association with %s released, %d sessions removed", r.t.remoteAddr, removed)
+	}
+
+	if mismatches := testcase.Expect.Evaluate(resp); len(mismatches) > 0 {
+		log.Printf("%s response assertion failed: %v", name, mismatches)
+		return &StepFailure{Step: testcase.Step, Type: testcase.Type, Mismatches: mismatches}
+	}
+
+	vars, failures := captureResponse(testcase.Capture, resp)
+	step.Captures = vars
+	if len(vars) > 0 {
+		if r.vars == nil {
+			r.vars = make(map[string]interface{}, len(vars))
+		}
+		for name, value := range vars {
+			r.vars[name] = value
+		}
+	}
+	if len(failures) > 0 {
+		log.Printf("%s response capture failed: %v", name, failures)
+		return &StepFailure{Step: testcase.Step, Type: testcase.Type, Mismatches: failures}
+	}
+
+	log.Printf("Verified %s response", name)
+	return nil
+}
@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"context"
+	"testing"
+	"upftester/internal/mockupf"
+	"upftester/internal/report"
+
+	"github.com/wmnsk/go-pfcp/ie"
+)
+
+func TestRunSet_Association(t *testing.T) {
+	// 要求 Association 的独立 mock UPF，测试器不预先建立 Association
+	upf, err := mockupf.New(mockupf.Config{N4Addr: "127.0.1.7:0", N3Ip: "127.0.1.7", N6Addr: "127.0.1.7:0", RequireAssociation: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	upf.Start()
+	defer upf.Stop()
+
+	cfg := newTestConfig()
+	cfg.Basic.UpfN4Ip = upf.N4Addr().IP.String()
+	cfg.Basic.UpfN4Port = upf.N4Addr().Port
+	tester, err := NewTester(cfg)
+	if err != nil {
+		t.Fatalf("NewTester() error = %v", err)
+	}
+	defer tester.Close()
+
+	testCases, err := LoadTestCases("./testdata/association/association.yaml", cfg)
+	if err != nil {
+		t.Fatalf("LoadTestCases() error = %v", err)
+	}
+
+	set := report.New().NewSet(0, "association")
+	if err := tester.RunSet(context.Background(), testCases, set); err != nil {
+		t.Fatalf("RunSet() error = %v", err)
+	}
+
+	if cause := set.Steps[0].Cause; cause == nil || *cause != ie.CauseNoEstablishedPFCPAssociation {
+		t.Errorf("expect update before setup rejected, got %+v", set.Steps[0])
+	}
+	setup := set.Steps[1].Captures
+	if setup["upfNodeId"] != "127.0.1.7" || setup["upFeatures"] != "0100" {
+		t.Errorf("unexpected setup captures %v", setup)
+	}
+	recovery, ok := setup["upfRecovery"].(int64)
+	if !ok || recovery == 0 {
+		t.Errorf("expect recovery time stamp captured, got %v", setup["upfRecovery"])
+	}
+	if after := set.Steps[8].Captures["upfRecoveryAfter"]; after != recovery {
+		t.Errorf("expect the same recovery time stamp after re-setup, got %v and %v", recovery, after)
+	}
+	if set.Steps[5].Captures["releasedBy"] != "127.0.1.7" {
+		t.Errorf("unexpected release captures %v", set.Steps[5].Captures)
+	}
+	if set.Teardown != nil {
+		t.Errorf("expect no leftover session, got teardown %+v", set.Teardown)
+	}
+	if n := tester.Sessions().Count(); n != 0 || upf.SessionCount() != 0 {
+		t.Errorf("expect no session left, tester %d, mock UPF %d", n, upf.SessionCount())
+	}
+}
+
+func TestLoadAssociationStep(t *testing.T) {
+	td := newTemplateData(testTester.Config(), nil, nil)
+	features := &Expectation{UPFunctionFeatures: []string{"FTUP", "UEIP"}}
+	if _, err := loadStep(TestStep{Type: "association_setup", Expect: features}, td); err != nil {
+		t.Errorf("loadStep() error = %v", err)
+	}
+
+	tests := []TestStep{
+		{Type: "association_setup", Expect: &Expectation{UPFunctionFeatures: []string{"FOO"}}},
+		{Type: "association_setup", Capture: []Capture{{Var: "seid", From: "upfSeid"}}},
+		{Type: "association_release", Capture: []Capture{{Var: "ts", From: "recoveryTimeStamp"}}},
+		{Type: "session_establishment_response", Capture: []Capture{{Var: "id", From: "nodeId"}}},
+	}
+	for _, step := range tests {
+		if _, err := loadStep(step, td); err == nil {
+			t.Errorf("expect step %+v rejected", step)
+		}
+	}
+}
+
+func TestForgetSessions_Owner(t *testing.T) {
+	releasing, other := &testRunner{t: testTester}, &testRunner{t: testTester}
+	mine := &SessionContext{SEID: 0xf001, State: SessionStateActive, owner: releasing}
+	theirs := &SessionContext{SEID: 0xf002, State: SessionStateActive, owner: other}
+	testTester.sessions.AddSession(mine.SEID, mine)
+	testTester.sessions.AddSession(theirs.SEID, theirs)
+	defer testTester.forgetSessions(other)
+
+	if n := testTester.forgetSessions(releasing); n != 1 {
+		t.Errorf("expect 1 session forgotten, got %d", n)
+	}
+	if _, ok := testTester.sessions.GetSession(mine.SEID); ok || mine.State != SessionStateDeleted {
+		t.Errorf("expect own session removed and deleted, state %v", mine.State)
+	}
+	if _, ok := testTester.sessions.GetSession(theirs.SEID); !ok || theirs.State != SessionStateActive {
+		t.Errorf("expect session of another set kept, state %v", theirs.State)
+	}
+}
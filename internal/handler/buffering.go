@@ -0,0 +1,173 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"time"
+	"upftester/internal/dataplane"
+	"upftester/internal/report"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultFlushTimeout 等待缓存报文送达的默认时间（毫秒）
+const defaultFlushTimeout = 2000
+
+// bufferSettle 发送结束后确认报文未被转发前的等待时间
+const bufferSettle = 100 * time.Millisecond
+
+// BufferingConfig 下行缓存测试配置，由 buffering 步骤的 path 指定，
+// 同一文件中的数据平面测试参数 (packetCount、interval、payloadSize、srcPort、dstPort) 用于 DN 侧发送
+type BufferingConfig struct {
+	FlushTimeout int `yaml:"flushTimeout"` // 会话重新激活后等待缓存报文送达的时间（毫秒），默认 2000
+
+	Traffic *dataplane.DataPlaneTestConfig `yaml:"-"`
+}
+
+// LoadBufferingConfig 从文件加载下行缓存测试配置
+func LoadBufferingConfig(path string, td *TemplateData) (*BufferingConfig, error) {
+	data, err := renderFile(path, td)
+	if err != nil {
+		return nil, err
+	}
+	traffic, err := dataplane.ParseDataPlaneTestConfigAs(data, "buffering")
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg BufferingConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("unmarshal buffering config failed: %w", err)
+	}
+	cfg.Traffic = traffic
+
+	if traffic.PacketCount <= 0 {
+		return nil, fmt.Errorf("buffering: packetCount is required")
+	}
+	if cfg.FlushTimeout == 0 {
+		cfg.FlushTimeout = defaultFlushTimeout
+	}
+
+	return &cfg, nil
+}
+
+// loadBufferingStep 校验下行缓存动作并加载缓存测试配置
+func loadBufferingStep(step TestStep, td *TemplateData) (interface{}, error) {
+	if step.Action != "send" && step.Action != "verify" {
+		return nil, fmt.Errorf("unsupported buffering action: %s", step.Action)
+	}
+	return LoadBufferingConfig(step.Path, td)
+}
+
+// runBuffering 执行下行缓存测试：send 在会话空闲时从 DN 侧发送下行报文并确认未被转发，
+// verify 在会话重新激活后等待缓存的报文送达 gNB 并核对完整性和顺序
+func (r *testRunner) runBuffering(testcase TestCase, step *report.StepResult) error {
+	if r.sessionCtx == nil {
+		return fmt.Errorf("no active session for buffering test")
+	}
+
+	switch testcase.Action {
+	case "send":
+		if r.sessionCtx.DataPlaneTestHandle != nil {
+			return fmt.Errorf("buffering test already running")
+		}
+
+		cfg, err := LoadBufferingConfig(testcase.Path, r.templateData(testcase))
+		if err != nil {
+			return err
+		}
+		globalConfig := r.t.cfg
+
+		gnbIp := globalConfig.DataPlane.GnbIp
+		if r.sessionCtx.GnbIP != "" {
+			gnbIp = r.sessionCtx.GnbIP
+		}
+		dnIp := globalConfig.DataPlane.DnIp
+		if cfg.Traffic.DstIp != "" {
+			dnIp = cfg.Traffic.DstIp
+		}
+
+		dn := dataplane.NewDNEmulator(dnIp, cfg.Traffic.DstPort, globalConfig.DataPlane.N6Ip,
+			globalConfig.DataPlane.N6TunnelPort, r.sessionCtx.UEIP)
+		if err := dn.Start(); err != nil {
+			return err
+		}
+		test := dataplane.NewBufferingTest(cfg.Traffic, gnbIp, r.sessionCtx.UEIP, dnIp, dn)
+		if err := test.Start(); err != nil {
+			test.Stop()
+			return err
+		}
+		r.sessionCtx.DataPlaneTestHandle = test
+
+		select {
+		case <-time.After(bufferSettle):
+		case <-r.ctx.Done():
+			return r.ctx.Err()
+		}
+		delivered := test.Delivered()
+		result := test.GetResult()
+		step.DataPlane = &report.DataPlaneResult{
+			TestType:        result.TestType,
+			PacketsSent:     result.PacketsSent,
+			BytesSent:       result.BytesSent,
+			PacketsReceived: delivered,
+		}
+
+		if delivered > 0 {
+			test.Stop()
+			r.sessionCtx.DataPlaneTestHandle = nil
+			mismatches := []string{fmt.Sprintf("buffering: %d of %d downlink packets delivered while session is idle",
+				delivered, result.PacketsSent)}
+			log.Printf("buffering test failed: %v", mismatches)
+			return &StepFailure{Step: testcase.Step, Type: testcase.Type, Mismatches: mismatches}
+		}
+		log.Printf("Sent %d downlink packets from DN side, none delivered while buffering", result.PacketsSent)
+
+	case "verify":
+		test, ok := r.sessionCtx.DataPlaneTestHandle.(*dataplane.BufferingTest)
+		if !ok {
+			return fmt.Errorf("no buffering test running, add a buffering send step first")
+		}
+		r.sessionCtx.DataPlaneTestHandle = nil
+
+		cfg, err := LoadBufferingConfig(testcase.Path, r.templateData(testcase))
+		if err != nil {
+			test.Stop()
+			return err
+		}
+
+		test.Wait(r.ctx, time.Duration(cfg.FlushTimeout)*time.Millisecond)
+		test.Stop()
+		if err := r.ctx.Err(); err != nil {
+			return err
+		}
+		result := test.GetResult()
+		step.DataPlane = newDataPlaneResult(result)
+
+		// 上行方向没有流量，缓存的报文送达后计入下行
+		r.sessionCtx.Traffic.DownlinkPackets += uint64(result.PacketsReceived)
+		r.sessionCtx.Traffic.DownlinkBytes += uint64(result.BytesReceived)
+
+		var mismatches []string
+		if result.PacketsLost > 0 {
+			mismatches = append(mismatches, fmt.Sprintf("buffering: %d of %d buffered packets not delivered within %dms",
+				result.PacketsLost, result.PacketsSent, cfg.FlushTimeout))
+		}
+		if result.Reordered > 0 {
+			mismatches = append(mismatches, fmt.Sprintf("buffering: %d packets delivered out of order", result.Reordered))
+		}
+		if result.Duplicates > 0 {
+			mismatches = append(mismatches, fmt.Sprintf("buffering: %d duplicate packets delivered", result.Duplicates))
+		}
+		if len(mismatches) > 0 {
+			log.Printf("buffering verification failed: %v", mismatches)
+			return &StepFailure{Step: testcase.Step, Type: testcase.Type, Mismatches: mismatches}
+		}
+		log.Printf("Buffered packets flushed in order, %d packets delivered", result.PacketsReceived)
+
+	default:
+		return fmt.Errorf("unsupported buffering action: %s", testcase.Action)
+	}
+
+	return nil
+}
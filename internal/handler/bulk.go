@@ -0,0 +1,421 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+	"upftester/internal/report"
+
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+	"gopkg.in/yaml.v3"
+)
+
+// BulkConfig 批量会话配置，由 session_bulk 步骤的 path 指定
+type BulkConfig struct {
+	Template       string  `yaml:"template"`       // 会话建立请求模板，相对于本文件所在目录
+	Count          int     `yaml:"count"`          // 建立的会话数
+	Concurrency    int     `yaml:"concurrency"`    // 同时等待响应的请求数，默认 1
+	Rate           float64 `yaml:"rate"`           // 目标建立速率 (会话/秒)，0 表示不限速
+	KeepSessions   bool    `yaml:"keepSessions"`   // 保留建立的会话，由后续 action 为 delete 的 session_bulk 步骤删除，否则在本步骤结束前删除
+	MinSuccessRate float64 `yaml:"minSuccessRate"` // 步骤通过所需的最低成功率 (百分比)，默认 100
+}
+
+// LoadBulkConfig 从文件加载批量会话配置
+func LoadBulkConfig(path string, td *TemplateData) (*BulkConfig, error) {
+	data, err := renderFile(path, td)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg BulkConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("unmarshal bulk config failed: %w", err)
+	}
+
+	if cfg.Template == "" {
+		return nil, fmt.Errorf("session_bulk: template is required")
+	}
+	if !filepath.IsAbs(cfg.Template) {
+		cfg.Template = filepath.Join(filepath.Dir(path), cfg.Template)
+	}
+	if est, err := loadEstablishment(cfg.Template, td); err != nil {
+		return nil, fmt.Errorf("session_bulk: invalid template: %w", err)
+	} else if _, err := est.Encode(nil); err != nil {
+		return nil, fmt.Errorf("session_bulk: invalid template: %w", err)
+	}
+	if cfg.Count <= 0 {
+		return nil, fmt.Errorf("session_bulk: count must be positive")
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 1
+	}
+	if cfg.Rate < 0 {
+		return nil, fmt.Errorf("session_bulk: rate must not be negative")
+	}
+	if cfg.MinSuccessRate == 0 {
+		cfg.MinSuccessRate = 100
+	}
+	if cfg.MinSuccessRate < 0 || cfg.MinSuccessRate > 100 {
+		return nil, fmt.Errorf("session_bulk: minSuccessRate must be within 0-100")
+	}
+
+	return &cfg, nil
+}
+
+// loadBulkStep 校验批量会话动作，establish 时加载批量配置
+func loadBulkStep(step TestStep, td *TemplateData) (interface{}, error) {
+	switch step.Action {
+	case "establish":
+		return LoadBulkConfig(step.Path, td)
+	case "delete":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unsupported session bulk action: %s", step.Action)
+	}
+}
+
+// bulkSessions session_bulk 保留的会话，删除时沿用最近一次建立的并发和速率
+type bulkSessions struct {
+	sessions    []*SessionContext
+	concurrency int
+	rate        float64
+}
+
+// runBulk 执行 session_bulk 步骤：establish 按模板建立 count 个会话，delete 删除之前保留的批量会话
+func (r *testRunner) runBulk(testcase TestCase, step *report.StepResult) error {
+	switch testcase.Action {
+	case "establish":
+		td := r.templateData(testcase)
+		cfg, err := LoadBulkConfig(testcase.Path, td)
+		if err != nil {
+			return err
+		}
+		sessions, result := r.establishBulk(cfg, td)
+		step.Bulk = result
+
+		if cfg.KeepSessions {
+			if r.bulk == nil {
+				r.bulk = &bulkSessions{}
+			}
+			r.bulk.sessions = append(r.bulk.sessions, sessions...)
+			r.bulk.concurrency, r.bulk.rate = cfg.Concurrency, cfg.Rate
+		} else {
+			result.Deleted = r.deleteBulk(sessions, cfg.Concurrency, cfg.Rate).Succeeded
+		}
+
+		rate := float64(result.Succeeded) * 100 / float64(result.Requested)
+		if rate < cfg.MinSuccessRate {
+			mismatch := fmt.Sprintf("success rate: expect >= %.2f%%, got %.2f%% (%d/%d), first failure: %s",
+				cfg.MinSuccessRate, rate, result.Succeeded, result.Requested, result.FirstFailure)
+			return &StepFailure{Step: testcase.Step, Type: testcase.Type, Mismatches: []string{mismatch}}
+		}
+
+	case "delete":
+		if r.bulk == nil || len(r.bulk.sessions) == 0 {
+			return fmt.Errorf("no bulk sessions to delete")
+		}
+		kept := r.bulk
+		r.bulk = nil
+		result := r.deleteBulk(kept.sessions, kept.concurrency, kept.rate)
+		step.Bulk = result
+
+		if result.Failed > 0 {
+			mismatch := fmt.Sprintf("deleted %d of %d sessions, first failure: %s", result.Succeeded, result.Requested, result.FirstFailure)
+			return &StepFailure{Step: testcase.Step, Type: testcase.Type, Mismatches: []string{mismatch}}
+		}
+
+	default:
+		return fmt.Errorf("unsupported session bulk action: %s", testcase.Action)
+	}
+
+	return nil
+}
+
+// establishBulk 按配置的并发和速率建立会话，返回建立成功的会话
+func (r *testRunner) establishBulk(cfg *BulkConfig, td *TemplateData) ([]*SessionContext, *report.BulkResult) {
+	log.Printf("Establishing %d sessions from %s, concurrency=%d, rate=%.1f/s", cfg.Count, cfg.Template, cfg.Concurrency, cfg.Rate)
+
+	var mu sync.Mutex
+	sessions := make([]*SessionContext, 0, cfg.Count)
+	stats := runPaced(cfg.Count, cfg.Concurrency, cfg.Rate, func(int) (time.Duration, error) {
+		ctx, latency, err := r.establishSession(cfg.Template, td, nil)
+		if err == nil {
+			mu.Lock()
+			sessions = append(sessions, ctx)
+			mu.Unlock()
+		}
+		return latency, err
+	})
+
+	result := stats.result()
+	log.Printf("Bulk establishment completed: %d/%d succeeded in %.0fms, %.1f sessions/s",
+		result.Succeeded, result.Requested, result.DurationMs, result.SetupRate)
+	return sessions, result
+}
+
+// deleteBulk 按配置的并发和速率删除会话
+func (r *testRunner) deleteBulk(sessions []*SessionContext, concurrency int, rate float64) *report.BulkResult {
+	if len(sessions) == 0 {
+		return newBulkStats(0).result()
+	}
+	log.Printf("Deleting %d bulk sessions, concurrency=%d", len(sessions), concurrency)
+
+	stats := runPaced(len(sessions), concurrency, rate, func(i int) (time.Duration, error) {
+		return r.t.deleteSession(sessions[i])
+	})
+
+	result := stats.result()
+	log.Printf("Bulk deletion completed: %d/%d succeeded in %.0fms", result.Succeeded, result.Requested, result.DurationMs)
+	return result
+}
+
+// establishSession 按模板建立一个会话，SEID、UE 地址和 TEID 从测试器的资源池分配，返回请求到响应的时延。
+// ch 不为 nil 时在发送前按 CP SEID 注册到分发器，接收 UPF 发起的请求，删除会话时注销
+func (r *testRunner) establishSession(template string, td *TemplateData, ch chan *PFCPMessage) (*SessionContext, time.Duration, error) {
+	res := r.t.resources.NewSession()
+	cfg, err := loadEstablishment(template, td)
+	if err != nil {
+		res.Release()
+		return nil, 0, err
+	}
+	msg, err := cfg.Encode(res)
+	if err != nil {
+		res.Release()
+		return nil, 0, fmt.Errorf("allocate session resources failed: %w", err)
+	}
+	if cfg.FSEID == nil {
+		res.Release()
+		return nil, 0, fmt.Errorf("session establishment request without fseid")
+	}
+
+	ctx := &SessionContext{
+		SEID:      cfg.FSEID.SEID,
+		State:     SessionStateEstablishing,
+		Resources: res,
+		owner:     r,
+	}
+	if cfg.CreatePDRs != nil {
+		for _, pdr := range *cfg.CreatePDRs {
+			if pdr.PDI.UEAddress != nil {
+				ctx.UEIP = pdr.PDI.UEAddress.Ipv4Address
+			}
+		}
+	}
+
+	if ch != nil {
+		r.t.dispatcher.Register(ctx.SEID, ch)
+	}
+	latency, err := r.sendEstablishment(ctx, msg)
+	if err != nil {
+		if ch != nil {
+			r.t.dispatcher.Unregister(ctx.SEID)
+		}
+		res.Release()
+		return nil, latency, err
+	}
+	return ctx, latency, nil
+}
+
+// sendEstablishment 发送会话建立请求并等待响应，成功时将 UPF SEID 记录到 ctx 并加入会话管理器
+func (r *testRunner) sendEstablishment(ctx *SessionContext, msg message.Message) (time.Duration, error) {
+	sentAt := time.Now()
+	txn, err := r.t.request(msg)
+	if err != nil {
+		return 0, err
+	}
+	reply, err := txn.WaitContext(r.ctx)
+	latency := time.Since(sentAt)
+	if err != nil {
+		return latency, fmt.Errorf("wait session establishment response failed: %w", err)
+	}
+	if reply.MessageType != message.MsgTypeSessionEstablishmentResponse {
+		return latency, fmt.Errorf("expect session establishment response, got message type %d", reply.MessageType)
+	}
+
+	resp, err := message.ParseSessionEstablishmentResponse(reply.Payload)
+	if err != nil {
+		return latency, fmt.Errorf("session establishment response parse failed: %w", err)
+	}
+	if err := acceptedCause(resp.Cause); err != nil {
+		return latency, fmt.Errorf("session establishment rejected, %w", err)
+	}
+	if resp.UPFSEID == nil {
+		return latency, fmt.Errorf("session establishment response without UP F-SEID")
+	}
+	fseid, err := resp.UPFSEID.FSEID()
+	if err != nil {
+		return latency, fmt.Errorf("session establishment response fseid parse failed: %w", err)
+	}
+
+	ctx.UPFSEID = fseid.SEID
+	ctx.State = SessionStateActive
+	r.t.sessions.AddSession(ctx.SEID, ctx)
+	return latency, nil
+}
+
+// deleteSession 删除会话，成功后归还会话的资源，返回请求到响应的时延
+func (t *Tester) deleteSession(ctx *SessionContext) (time.Duration, error) {
+	req := message.NewSessionDeletionRequest(0, 0, ctx.UPFSEID, 0, 0)
+
+	ctx.State = SessionStateDeleting
+	sentAt := time.Now()
+	txn, err := t.request(req)
+	if err != nil {
+		return 0, err
+	}
+	// 删除用于清理会话，测试取消后仍等待响应
+	reply, err := txn.Wait()
+	latency := time.Since(sentAt)
+	if err != nil {
+		return latency, fmt.Errorf("wait session deletion response failed: %w", err)
+	}
+	if reply.MessageType != message.MsgTypeSessionDeletionResponse {
+		return latency, fmt.Errorf("expect session deletion response, got message type %d", reply.MessageType)
+	}
+
+	resp, err := message.ParseSessionDeletionResponse(reply.Payload)
+	if err != nil {
+		return latency, fmt.Errorf("session deletion response parse failed: %w", err)
+	}
+	if err := acceptedCause(resp.Cause); err != nil {
+		return latency, fmt.Errorf("session deletion rejected, %w", err)
+	}
+
+	ctx.State = SessionStateDeleted
+	t.sessions.DeleteSession(ctx.SEID)
+	t.dispatcher.Unregister(ctx.SEID)
+	if ctx.Resources != nil {
+		ctx.Resources.Release()
+	}
+	return latency, nil
+}
+
+// acceptedCause 检查响应的 Cause 是否为 Request accepted
+func acceptedCause(i *ie.IE) error {
+	if i == nil {
+		return fmt.Errorf("response without cause")
+	}
+	cause, err := i.Cause()
+	if err != nil {
+		return fmt.Errorf("cause parse failed: %w", err)
+	}
+	if cause != ie.CauseRequestAccepted {
+		return fmt.Errorf("cause: %d", cause)
+	}
+	return nil
+}
+
+// runPaced 以 concurrency 个并发、每秒最多 rate 次 (0 表示不限) 的速度执行 count 次 fn，汇总时延和首个失败
+func runPaced(count, concurrency int, rate float64, fn func(i int) (time.Duration, error)) *bulkStats {
+	stats := newBulkStats(count)
+
+	var tick <-chan time.Time
+	if rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / rate))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i := 0; i < count; i++ {
+		if tick != nil && i > 0 {
+			<-tick
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			latency, err := fn(i)
+			if err != nil {
+				stats.fail(err)
+				return
+			}
+			stats.succeed(latency)
+		}(i)
+	}
+	wg.Wait()
+
+	return stats
+}
+
+// bulkStats 批量请求的统计
+type bulkStats struct {
+	requested int
+	startTime time.Time
+
+	mu           sync.Mutex
+	latencies    []time.Duration
+	failed       int
+	firstFailure string
+}
+
+func newBulkStats(requested int) *bulkStats {
+	return &bulkStats{requested: requested, startTime: time.Now()}
+}
+
+func (s *bulkStats) succeed(latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latencies = append(s.latencies, latency)
+}
+
+func (s *bulkStats) fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failed++
+	if s.firstFailure == "" {
+		s.firstFailure = err.Error()
+		log.Printf("Bulk request failed: %v", err)
+	}
+}
+
+// result 返回统计结果，时延分位数只统计成功的请求
+func (s *bulkStats) result() *report.BulkResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elapsed := time.Since(s.startTime)
+	result := &report.BulkResult{
+		Requested:    s.requested,
+		Succeeded:    len(s.latencies),
+		Failed:       s.requested - len(s.latencies),
+		DurationMs:   durationMs(elapsed),
+		FirstFailure: s.firstFailure,
+	}
+	if elapsed > 0 {
+		result.SetupRate = float64(result.Succeeded) / elapsed.Seconds()
+	}
+
+	latencies := append([]time.Duration(nil), s.latencies...)
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	result.LatencyP50Ms = durationMs(percentile(latencies, 50))
+	result.LatencyP90Ms = durationMs(percentile(latencies, 90))
+	result.LatencyP99Ms = durationMs(percentile(latencies, 99))
+	if len(latencies) > 0 {
+		result.LatencyMaxMs = durationMs(latencies[len(latencies)-1])
+	}
+	return result
+}
+
+// percentile 返回升序时延的 p 分位数 (最近秩法)，空切片返回 0
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,230 @@
+package handler
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+// Capture 从响应中提取取值保存为会话变量，在接收步骤的 capture 字段中配置，
+// 后续步骤文件中以 {{ .Vars.<var> }} 引用
+type Capture struct {
+	Var   string `yaml:"var"`   // 变量名
+	From  string `yaml:"from"`  // 来源: createdPdr、createdTrafficEndpoint、upfSeid，association 步骤为 nodeId、upFunctionFeatures、recoveryTimeStamp
+	Id    uint16 `yaml:"id"`    // createdPdr 的 PDR ID 或 createdTrafficEndpoint 的 Traffic Endpoint ID
+	Field string `yaml:"field"` // teid、ipv4、ipv6 (F-TEID) 或 ueIp、ueIpv6 (UPF 分配的 UE 地址)，其他来源无需配置
+}
+
+// captureFields 各来源支持的字段
+var captureFields = map[string][]string{
+	"createdPdr":             {"teid", "ipv4", "ipv6", "ueIp", "ueIpv6"},
+	"createdTrafficEndpoint": {"teid", "ipv4", "ipv6", "ueIp", "ueIpv6"},
+	"upfSeid":                {""},
+	"nodeId":                 {""},
+	"upFunctionFeatures":     {""},
+	"recoveryTimeStamp":      {""},
+}
+
+// captureSources 各步骤类型支持的来源
+var captureSources = map[string][]string{
+	"session_establishment_response": {"createdPdr", "createdTrafficEndpoint", "upfSeid"},
+	"session_modification_response":  {"createdPdr", "createdTrafficEndpoint", "upfSeid"},
+	"association_setup":              {"nodeId", "upFunctionFeatures", "recoveryTimeStamp"},
+	"association_update":             {"nodeId", "upFunctionFeatures"},
+	"association_release":            {"nodeId"},
+}
+
+// validateCaptures 校验步骤类型是否支持捕获以及各捕获的来源
+func validateCaptures(stepType string, captures []Capture) error {
+	if len(captures) == 0 {
+		return nil
+	}
+	sources, ok := captureSources[stepType]
+	if !ok {
+		return fmt.Errorf("capture is only supported on session establishment and modification responses and association steps")
+	}
+	for _, c := range captures {
+		if err := c.validate(); err != nil {
+			return err
+		}
+		if !containsString(sources, c.From) {
+			return fmt.Errorf("capture %s: source %s is not supported on %s", c.Var, c.From, stepType)
+		}
+	}
+	return nil
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// validate 校验变量名、来源和字段
+func (c Capture) validate() error {
+	if c.Var == "" {
+		return fmt.Errorf("capture var is required")
+	}
+	fields, ok := captureFields[c.From]
+	if !ok {
+		return fmt.Errorf("capture %s: unknown source %q", c.Var, c.From)
+	}
+	for _, field := range fields {
+		if field == c.Field {
+			return nil
+		}
+	}
+	return fmt.Errorf("capture %s: unsupported field %q for %s", c.Var, c.Field, c.From)
+}
+
+// extract 从响应中提取取值，TEID 为 uint32，SEID 为 uint64，地址和 Node ID 为字符串，
+// UP Function Features 为十六进制字符串，Recovery Time Stamp 为 Unix 秒数 (int64)
+func (c Capture) extract(v *responseView) (interface{}, error) {
+	switch c.From {
+	case "nodeId":
+		if v.NodeID == nil {
+			return nil, fmt.Errorf("node ID absent")
+		}
+		nodeID, err := v.NodeID.NodeID()
+		if err != nil {
+			return nil, fmt.Errorf("node ID parse failed: %w", err)
+		}
+		return nodeID, nil
+
+	case "upFunctionFeatures":
+		if v.UPFunctionFeatures == nil {
+			return nil, fmt.Errorf("UP function features absent")
+		}
+		features, err := v.UPFunctionFeatures.UPFunctionFeatures()
+		if err != nil {
+			return nil, fmt.Errorf("UP function features parse failed: %w", err)
+		}
+		return hex.EncodeToString(features), nil
+
+	case "recoveryTimeStamp":
+		if v.RecoveryTimeStamp == nil {
+			return nil, fmt.Errorf("recovery time stamp absent")
+		}
+		ts, err := v.RecoveryTimeStamp.RecoveryTimeStamp()
+		if err != nil {
+			return nil, fmt.Errorf("recovery time stamp parse failed: %w", err)
+		}
+		return ts.Unix(), nil
+
+	case "upfSeid":
+		if v.UPFSEID == nil {
+			return nil, fmt.Errorf("UP F-SEID absent")
+		}
+		fseid, err := v.UPFSEID.FSEID()
+		if err != nil {
+			return nil, fmt.Errorf("UP F-SEID parse failed: %w", err)
+		}
+		return fseid.SEID, nil
+
+	case "createdPdr":
+		for _, item := range v.CreatedPDR {
+			if id, err := item.PDRID(); err != nil || id != c.Id {
+				continue
+			}
+			if c.Field == "ueIp" || c.Field == "ueIpv6" {
+				return c.ueAddress(item)
+			}
+			fteid, err := item.FTEID()
+			if err != nil {
+				return nil, fmt.Errorf("created PDR %d: F-TEID parse failed: %w", c.Id, err)
+			}
+			return c.fteid(fteid)
+		}
+		return nil, fmt.Errorf("created PDR %d absent", c.Id)
+
+	case "createdTrafficEndpoint":
+		for _, item := range v.CreatedTrafficEndpoint {
+			if id, err := item.TrafficEndpointID(); err != nil || uint16(id) != c.Id {
+				continue
+			}
+			if c.Field == "ueIp" || c.Field == "ueIpv6" {
+				return c.ueAddress(item)
+			}
+			fteid, err := item.LocalFTEID()
+			if err != nil {
+				return nil, fmt.Errorf("created traffic endpoint %d: local F-TEID parse failed: %w", c.Id, err)
+			}
+			return c.fteid(fteid)
+		}
+		return nil, fmt.Errorf("created traffic endpoint %d absent", c.Id)
+	}
+	return nil, fmt.Errorf("unknown source %q", c.From)
+}
+
+// placeholder 返回加载校验时代替捕获值的零值
+func (c Capture) placeholder() interface{} {
+	switch {
+	case c.From == "upfSeid":
+		return uint64(0)
+	case c.From == "upFunctionFeatures":
+		return "00"
+	case c.From == "recoveryTimeStamp":
+		return int64(0)
+	case c.Field == "teid":
+		return uint32(0)
+	case c.Field == "ipv6" || c.Field == "ueIpv6":
+		return "::"
+	default:
+		return "0.0.0.0"
+	}
+}
+
+func (c Capture) fteid(fteid *ie.FTEIDFields) (interface{}, error) {
+	switch c.Field {
+	case "teid":
+		return fteid.TEID, nil
+	case "ipv4":
+		return addressString(fteid.IPv4Address, "F-TEID IPv4")
+	default:
+		return addressString(fteid.IPv6Address, "F-TEID IPv6")
+	}
+}
+
+func (c Capture) ueAddress(item *ie.IE) (interface{}, error) {
+	ue, err := item.UEIPAddress()
+	if err != nil {
+		return nil, fmt.Errorf("%s %d: UE IP address parse failed: %w", c.From, c.Id, err)
+	}
+	if c.Field == "ueIp" {
+		return addressString(ue.IPv4Address, "UE IPv4 address")
+	}
+	return addressString(ue.IPv6Address, "UE IPv6 address")
+}
+
+func addressString(ip net.IP, name string) (interface{}, error) {
+	if ip == nil {
+		return nil, fmt.Errorf("%s absent", name)
+	}
+	return ip.String(), nil
+}
+
+// captureResponse 按步骤的 capture 配置从响应中提取变量，返回提取失败的描述
+func captureResponse(captures []Capture, msg message.Message) (map[string]interface{}, []string) {
+	if len(captures) == 0 {
+		return nil, nil
+	}
+
+	v := newResponseView(msg)
+	vars := make(map[string]interface{}, len(captures))
+	var failures []string
+	for _, c := range captures {
+		value, err := c.extract(v)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("capture %s: %v", c.Var, err))
+			continue
+		}
+		vars[c.Var] = value
+	}
+	return vars, failures
+}
@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+func TestCaptureResponse(t *testing.T) {
+	resp := message.NewSessionEstablishmentResponse(0, 0, 1, 1, 0,
+		ie.NewCause(ie.CauseRequestAccepted),
+		ie.NewFSEID(0x20, net.ParseIP("127.0.1.2"), nil),
+		ie.NewCreatedPDR(
+			ie.NewPDRID(1),
+			ie.NewFTEID(0x01, 0x100, net.ParseIP("127.0.1.2"), nil, 0),
+			ie.NewUEIPAddress(0x02, "10.251.0.7", "", 0, 0),
+		),
+		ie.NewCreatedTrafficEndpoint(
+			ie.NewTrafficEndpointID(5),
+			ie.NewFTEID(0x01, 0x200, net.ParseIP("127.0.1.9"), nil, 0),
+		),
+	)
+
+	captures := []Capture{
+		{Var: "seid", From: "upfSeid"},
+		{Var: "teid", From: "createdPdr", Id: 1, Field: "teid"},
+		{Var: "ueIp", From: "createdPdr", Id: 1, Field: "ueIp"},
+		{Var: "teTeid", From: "createdTrafficEndpoint", Id: 5, Field: "teid"},
+		{Var: "teIp", From: "createdTrafficEndpoint", Id: 5, Field: "ipv4"},
+	}
+	vars, failures := captureResponse(captures, resp)
+	if len(failures) > 0 {
+		t.Fatalf("captureResponse() failures = %v", failures)
+	}
+	want := map[string]interface{}{
+		"seid":   uint64(0x20),
+		"teid":   uint32(0x100),
+		"ueIp":   "10.251.0.7",
+		"teTeid": uint32(0x200),
+		"teIp":   "127.0.1.9",
+	}
+	for name, value := range want {
+		if vars[name] != value {
+			t.Errorf("%s = %v (%T), want %v", name, vars[name], vars[name], value)
+		}
+	}
+
+	_, failures = captureResponse([]Capture{
+		{Var: "missing", From: "createdPdr", Id: 2, Field: "teid"},
+		{Var: "ipv6", From: "createdPdr", Id: 1, Field: "ipv6"},
+	}, resp)
+	if len(failures) != 2 || !strings.Contains(failures[0], "created PDR 2 absent") {
+		t.Errorf("expect failures for absent PDR and address, got %v", failures)
+	}
+}
+
+func TestCaptureResponse_Association(t *testing.T) {
+	ts := time.Unix(1700000000, 0)
+	resp := message.NewAssociationSetupResponse(1,
+		ie.NewNodeID("", "", "upf.example.org"),
+		ie.NewCause(ie.CauseRequestAccepted),
+		ie.NewRecoveryTimeStamp(ts),
+		ie.NewUPFunctionFeatures(0x11, 0x00, 0x04, 0x00),
+	)
+
+	vars, failures := captureResponse([]Capture{
+		{Var: "node", From: "nodeId"},
+		{Var: "features", From: "upFunctionFeatures"},
+		{Var: "recovery", From: "recoveryTimeStamp"},
+	}, resp)
+	if len(failures) > 0 {
+		t.Fatalf("captureResponse() failures = %v", failures)
+	}
+	if vars["node"] != "upf.example.org" || vars["features"] != "11000400" || vars["recovery"] != ts.Unix() {
+		t.Errorf("unexpected captures %v", vars)
+	}
+
+	expect := &Expectation{NodeID: stringPtr("upf.example.org"), UPFunctionFeatures: []string{"BUCP", "FTUP", "UEIP", "SSET"}}
+	mismatches := expect.Evaluate(resp)
+	if len(mismatches) != 1 || !strings.Contains(mismatches[0], "SSET") {
+		t.Errorf("expect only SSET mismatch, got %v", mismatches)
+	}
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func TestCaptureValidate(t *testing.T) {
+	tests := []struct {
+		capture Capture
+		wantErr bool
+	}{
+		{Capture{Var: "teid", From: "createdPdr", Id: 1, Field: "teid"}, false},
+		{Capture{Var: "seid", From: "upfSeid"}, false},
+		{Capture{From: "upfSeid"}, true},
+		{Capture{Var: "x", From: "createdFar", Field: "teid"}, true},
+		{Capture{Var: "x", From: "createdPdr", Id: 1}, true},
+		{Capture{Var: "x", From: "upfSeid", Field: "teid"}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.capture.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate(%+v) error = %v, wantErr %v", tt.capture, err, tt.wantErr)
+		}
+	}
+
+	step := TestStep{Step: 1, Type: "sleep", Capture: []Capture{{Var: "seid", From: "upfSeid"}}}
+	if _, err := loadStep(step, newTemplateData(nil, nil, nil)); err == nil {
+		t.Error("expect capture on a sleep step rejected")
+	}
+}
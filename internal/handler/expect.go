@@ -0,0 +1,472 @@
+package handler
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+	"gopkg.in/yaml.v3"
+)
+
+// Expectation 响应断言，在测试步骤的 expect 字段中配置
+type Expectation struct {
+	Cause           *uint8             `yaml:"cause"`           // 期望的 Cause，默认 Request Accepted
+	OffendingIE     *uint16            `yaml:"offendingIe"`     // 期望的 Offending IE 类型，如 108 (FAR ID)
+	CreatedPDRCount *int               `yaml:"createdPdrCount"` // 期望的 Created PDR 数量
+	CreatedPDRs     []CreatedPDRExpect `yaml:"createdPdrs"`     // 期望的 Created PDR 内容
+	Present         []string           `yaml:"present"`         // 必须存在的 IE
+	Absent          []string           `yaml:"absent"`          // 必须不存在的 IE
+
+	ReportType   []string            `yaml:"reportType"`   // Session Report Request 中必须设置的 Report Type，如 USAR
+	UsageReports []UsageReportExpect `yaml:"usageReports"` // 期望的 Usage Report 内容
+
+	DownlinkDataPdrId *uint16 `yaml:"downlinkDataPdrId"` // 期望 Downlink Data Report 中的 PDR ID
+
+	NodeID             *string  `yaml:"nodeId"`             // 期望 UPF 的 Node ID (IP 地址或 FQDN)
+	UPFunctionFeatures []string `yaml:"upFunctionFeatures"` // Association 响应中必须设置的 UP Function Features，如 FTUP
+}
+
+// UsageReportExpect Usage Report 断言，按 URR ID 匹配
+type UsageReportExpect struct {
+	UrrId          uint32        `yaml:"urrId"`
+	Trigger        []string      `yaml:"trigger"` // 必须设置的触发器，如 VOLTH、PERIO
+	UplinkVolume   *VolumeExpect `yaml:"uplinkVolume"`
+	DownlinkVolume *VolumeExpect `yaml:"downlinkVolume"`
+	TotalVolume    *VolumeExpect `yaml:"totalVolume"`
+}
+
+// VolumeExpect 流量断言 (字节)，可写为精确值 1000 或范围 {min: 1000, max: 2000}
+type VolumeExpect struct {
+	Min *uint64 `yaml:"min"`
+	Max *uint64 `yaml:"max"`
+}
+
+func (v *VolumeExpect) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		var exact uint64
+		if err := value.Decode(&exact); err != nil {
+			return err
+		}
+		v.Min, v.Max = &exact, &exact
+		return nil
+	}
+
+	type plain VolumeExpect
+	return value.Decode((*plain)(v))
+}
+
+// check 校验流量值是否在范围内，返回不匹配描述
+func (v *VolumeExpect) check(name string, got *uint64) string {
+	if got == nil {
+		return fmt.Sprintf("%s: expect reported, got absent", name)
+	}
+	if v.Min != nil && v.Max != nil && *v.Min == *v.Max && *got != *v.Min {
+		return fmt.Sprintf("%s: expect %d, got %d", name, *v.Min, *got)
+	}
+	if v.Min != nil && *got < *v.Min {
+		return fmt.Sprintf("%s: expect >= %d, got %d", name, *v.Min, *got)
+	}
+	if v.Max != nil && *got > *v.Max {
+		return fmt.Sprintf("%s: expect <= %d, got %d", name, *v.Max, *got)
+	}
+	return ""
+}
+
+// CreatedPDRExpect Created PDR 断言
+type CreatedPDRExpect struct {
+	PdrId     uint16 `yaml:"pdrId"`
+	FTEIDIpv4 string `yaml:"fteidIpv4"`
+}
+
+// StepFailure 测试步骤失败，携带所有断言不匹配项
+type StepFailure struct {
+	Step       int
+	Type       string
+	Mismatches []string
+}
+
+func (e *StepFailure) Error() string {
+	return fmt.Sprintf("step %d (%s) failed: %s", e.Step, e.Type, strings.Join(e.Mismatches, "; "))
+}
+
+// responseView 各类会话响应及 Session Report Request 中可断言的 IE
+type responseView struct {
+	Request                    bool // 是否为 UPF 发起的请求，请求中没有 Cause
+	Cause                      *ie.IE
+	NodeID                     *ie.IE
+	OffendingIE                *ie.IE
+	UPFSEID                    *ie.IE
+	CreatedPDR                 []*ie.IE
+	CreatedTrafficEndpoint     []*ie.IE
+	LoadControlInformation     *ie.IE
+	OverloadControlInformation *ie.IE
+	FailedRuleID               *ie.IE
+	UsageReport                []*ie.IE
+	ReportType                 *ie.IE
+	DownlinkDataReport         *ie.IE
+	ErrorIndicationReport      *ie.IE
+	RecoveryTimeStamp          *ie.IE
+	UPFunctionFeatures         *ie.IE
+}
+
+func newResponseView(msg message.Message) *responseView {
+	switch resp := msg.(type) {
+	case *message.SessionEstablishmentResponse:
+		return &responseView{
+			Cause:                      resp.Cause,
+			NodeID:                     resp.NodeID,
+			OffendingIE:                resp.OffendingIE,
+			UPFSEID:                    resp.UPFSEID,
+			CreatedPDR:                 resp.CreatedPDR,
+			CreatedTrafficEndpoint:     resp.CreatedTrafficEndpoint,
+			LoadControlInformation:     resp.LoadControlInformation,
+			OverloadControlInformation: resp.OverloadControlInformation,
+			FailedRuleID:               resp.FailedRuleID,
+		}
+	case *message.SessionModificationResponse:
+		return &responseView{
+			Cause:                      resp.Cause,
+			OffendingIE:                resp.OffendingIE,
+			CreatedPDR:                 resp.CreatedPDR,
+			CreatedTrafficEndpoint:     resp.CreatedUpdatedTrafficEndpoint,
+			LoadControlInformation:     resp.LoadControlInformation,
+			OverloadControlInformation: resp.OverloadControlInformation,
+			FailedRuleID:               resp.FailedRuleID,
+			UsageReport:                resp.UsageReport,
+		}
+	case *message.SessionDeletionResponse:
+		return &responseView{
+			Cause:                      resp.Cause,
+			OffendingIE:                resp.OffendingIE,
+			LoadControlInformation:     resp.LoadControlInformation,
+			OverloadControlInformation: resp.OverloadControlInformation,
+			UsageReport:                resp.UsageReport,
+		}
+	case *message.SessionReportRequest:
+		return &responseView{
+			Request:                    true,
+			LoadControlInformation:     resp.LoadControlInformation,
+			OverloadControlInformation: resp.OverloadControlInformation,
+			UsageReport:                resp.UsageReport,
+			ReportType:                 resp.ReportType,
+			DownlinkDataReport:         resp.DownlinkDataReport,
+			ErrorIndicationReport:      resp.ErrorIndicationReport,
+		}
+	case *message.AssociationSetupResponse:
+		return &responseView{
+			Cause:              resp.Cause,
+			NodeID:             resp.NodeID,
+			RecoveryTimeStamp:  resp.RecoveryTimeStamp,
+			UPFunctionFeatures: resp.UPFunctionFeatures,
+		}
+	case *message.AssociationUpdateResponse:
+		return &responseView{
+			Cause:              resp.Cause,
+			NodeID:             resp.NodeID,
+			UPFunctionFeatures: resp.UPFunctionFeatures,
+		}
+	case *message.AssociationReleaseResponse:
+		return &responseView{
+			Cause:  resp.Cause,
+			NodeID: resp.NodeID,
+		}
+	default:
+		return &responseView{}
+	}
+}
+
+// present 判断指定名称的 IE 是否存在
+func (v *responseView) present(name string) (bool, error) {
+	switch name {
+	case "cause":
+		return v.Cause != nil, nil
+	case "nodeId":
+		return v.NodeID != nil, nil
+	case "offendingIe":
+		return v.OffendingIE != nil, nil
+	case "upfSeid", "fseid":
+		return v.UPFSEID != nil, nil
+	case "createdPdr":
+		return len(v.CreatedPDR) > 0, nil
+	case "createdTrafficEndpoint":
+		return len(v.CreatedTrafficEndpoint) > 0, nil
+	case "loadControlInformation":
+		return v.LoadControlInformation != nil, nil
+	case "overloadControlInformation":
+		return v.OverloadControlInformation != nil, nil
+	case "failedRuleId":
+		return v.FailedRuleID != nil, nil
+	case "usageReport":
+		return len(v.UsageReport) > 0, nil
+	case "reportType":
+		return v.ReportType != nil, nil
+	case "downlinkDataReport":
+		return v.DownlinkDataReport != nil, nil
+	case "errorIndicationReport":
+		return v.ErrorIndicationReport != nil, nil
+	case "recoveryTimeStamp":
+		return v.RecoveryTimeStamp != nil, nil
+	case "upFunctionFeatures":
+		return v.UPFunctionFeatures != nil, nil
+	default:
+		return false, fmt.Errorf("unknown IE name %q", name)
+	}
+}
+
+// expectedCause 返回期望的 Cause，未配置断言时为 Request Accepted
+func (e *Expectation) expectedCause() uint8 {
+	if e == nil || e.Cause == nil {
+		return ie.CauseRequestAccepted
+	}
+	return *e.Cause
+}
+
+// Evaluate 对解析后的响应进行断言，返回所有不匹配项
+func (e *Expectation) Evaluate(msg message.Message) []string {
+	v := newResponseView(msg)
+	var mismatches []string
+
+	if v.Request {
+		// UPF 发起的请求中没有 Cause
+	} else if v.Cause == nil {
+		mismatches = append(mismatches, "cause IE missing")
+	} else if cause, err := v.Cause.Cause(); err != nil {
+		mismatches = append(mismatches, fmt.Sprintf("cause parse failed: %v", err))
+	} else if cause != e.expectedCause() {
+		mismatches = append(mismatches, fmt.Sprintf("cause: expect %d, got %d", e.expectedCause(), cause))
+	}
+
+	if e == nil {
+		return mismatches
+	}
+
+	if e.OffendingIE != nil {
+		if v.OffendingIE == nil {
+			mismatches = append(mismatches, fmt.Sprintf("offending IE: expect %d, got none", *e.OffendingIE))
+		} else if got, err := v.OffendingIE.OffendingIE(); err != nil {
+			mismatches = append(mismatches, fmt.Sprintf("offending IE parse failed: %v", err))
+		} else if got != *e.OffendingIE {
+			mismatches = append(mismatches, fmt.Sprintf("offending IE: expect %d, got %d", *e.OffendingIE, got))
+		}
+	}
+
+	if e.CreatedPDRCount != nil && len(v.CreatedPDR) != *e.CreatedPDRCount {
+		mismatches = append(mismatches, fmt.Sprintf("created PDR count: expect %d, got %d", *e.CreatedPDRCount, len(v.CreatedPDR)))
+	}
+
+	for _, want := range e.CreatedPDRs {
+		mismatches = append(mismatches, want.evaluate(v.CreatedPDR)...)
+	}
+
+	for _, name := range e.Present {
+		ok, err := v.present(name)
+		if err != nil {
+			mismatches = append(mismatches, err.Error())
+		} else if !ok {
+			mismatches = append(mismatches, fmt.Sprintf("%s: expect present, got absent", name))
+		}
+	}
+
+	for _, name := range e.Absent {
+		ok, err := v.present(name)
+		if err != nil {
+			mismatches = append(mismatches, err.Error())
+		} else if ok {
+			mismatches = append(mismatches, fmt.Sprintf("%s: expect absent, got present", name))
+		}
+	}
+
+	if len(e.ReportType) > 0 {
+		mismatches = append(mismatches, e.evaluateReportType(v.ReportType)...)
+	}
+
+	if e.DownlinkDataPdrId != nil {
+		if v.DownlinkDataReport == nil {
+			mismatches = append(mismatches, fmt.Sprintf("downlink data report: expect PDR %d, got none", *e.DownlinkDataPdrId))
+		} else if got, err := v.DownlinkDataReport.PDRID(); err != nil {
+			mismatches = append(mismatches, fmt.Sprintf("downlink data report PDR ID parse failed: %v", err))
+		} else if got != *e.DownlinkDataPdrId {
+			mismatches = append(mismatches, fmt.Sprintf("downlink data report: expect PDR %d, got %d", *e.DownlinkDataPdrId, got))
+		}
+	}
+
+	if e.NodeID != nil {
+		if v.NodeID == nil {
+			mismatches = append(mismatches, fmt.Sprintf("node ID: expect %s, got none", *e.NodeID))
+		} else if got, err := v.NodeID.NodeID(); err != nil {
+			mismatches = append(mismatches, fmt.Sprintf("node ID parse failed: %v", err))
+		} else if got != *e.NodeID {
+			mismatches = append(mismatches, fmt.Sprintf("node ID: expect %s, got %s", *e.NodeID, got))
+		}
+	}
+
+	if len(e.UPFunctionFeatures) > 0 {
+		mismatches = append(mismatches, e.evaluateUPFunctionFeatures(v.UPFunctionFeatures)...)
+	}
+
+	if len(e.UsageReports) > 0 {
+		var reports []UsageReport
+		for _, item := range v.UsageReport {
+			report, err := parseUsageReport(item)
+			if err != nil {
+				mismatches = append(mismatches, fmt.Sprintf("usage report parse failed: %v", err))
+				continue
+			}
+			reports = append(reports, report)
+		}
+		for _, want := range e.UsageReports {
+			mismatches = append(mismatches, want.evaluate(reports)...)
+		}
+	}
+
+	return mismatches
+}
+
+// validate 校验断言中引用的 IE 名称、Report Type 和触发器名称
+func (e *Expectation) validate() error {
+	if e == nil {
+		return nil
+	}
+
+	view := &responseView{}
+	for _, name := range append(append([]string{}, e.Present...), e.Absent...) {
+		if _, err := view.present(name); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range e.ReportType {
+		if _, ok := reportTypes[name]; !ok {
+			return fmt.Errorf("unknown report type %q", name)
+		}
+	}
+
+	for _, name := range e.UPFunctionFeatures {
+		if _, ok := upFunctionFeatures[name]; !ok {
+			return fmt.Errorf("unknown UP function feature %q", name)
+		}
+	}
+
+	for _, report := range e.UsageReports {
+		for _, name := range report.Trigger {
+			if _, ok := usageReportTriggers[name]; !ok {
+				return fmt.Errorf("unknown usage report trigger %q", name)
+			}
+		}
+		for _, volume := range []*VolumeExpect{report.UplinkVolume, report.DownlinkVolume, report.TotalVolume} {
+			if volume != nil && volume.Min != nil && volume.Max != nil && *volume.Min > *volume.Max {
+				return fmt.Errorf("usage report %d: volume min %d greater than max %d", report.UrrId, *volume.Min, *volume.Max)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (e *Expectation) evaluateReportType(reportType *ie.IE) []string {
+	if reportType == nil {
+		return []string{fmt.Sprintf("report type: expect %v, got none", e.ReportType)}
+	}
+
+	got, err := reportType.ReportType()
+	if err != nil {
+		return []string{fmt.Sprintf("report type parse failed: %v", err)}
+	}
+
+	var mismatches []string
+	for _, name := range e.ReportType {
+		if got&reportTypes[name] == 0 {
+			mismatches = append(mismatches, fmt.Sprintf("report type: expect %s set, got 0x%02x", name, got))
+		}
+	}
+	return mismatches
+}
+
+func (e *Expectation) evaluateUPFunctionFeatures(features *ie.IE) []string {
+	if features == nil {
+		return []string{fmt.Sprintf("UP function features: expect %v, got none", e.UPFunctionFeatures)}
+	}
+
+	got, err := features.UPFunctionFeatures()
+	if err != nil {
+		return []string{fmt.Sprintf("UP function features parse failed: %v", err)}
+	}
+
+	var mismatches []string
+	for _, name := range e.UPFunctionFeatures {
+		bit := upFunctionFeatures[name]
+		if int(bit[0]) >= len(got) || got[bit[0]]&bit[1] == 0 {
+			mismatches = append(mismatches, fmt.Sprintf("UP function features: expect %s set, got 0x%x", name, got))
+		}
+	}
+	return mismatches
+}
+
+func (u UsageReportExpect) evaluate(reports []UsageReport) []string {
+	for _, report := range reports {
+		if report.URRID != u.UrrId {
+			continue
+		}
+
+		var mismatches []string
+		for _, name := range u.Trigger {
+			found := false
+			for _, got := range report.Triggers {
+				if got == name {
+					found = true
+					break
+				}
+			}
+			if !found {
+				mismatches = append(mismatches, fmt.Sprintf("usage report %d: expect trigger %s, got %v", u.UrrId, name, report.Triggers))
+			}
+		}
+
+		volumes := []struct {
+			name string
+			want *VolumeExpect
+			got  *uint64
+		}{
+			{"uplink volume", u.UplinkVolume, report.UplinkVolume},
+			{"downlink volume", u.DownlinkVolume, report.DownlinkVolume},
+			{"total volume", u.TotalVolume, report.TotalVolume},
+		}
+		for _, volume := range volumes {
+			if volume.want == nil {
+				continue
+			}
+			if mismatch := volume.want.check(volume.name, volume.got); mismatch != "" {
+				mismatches = append(mismatches, fmt.Sprintf("usage report %d: %s", u.UrrId, mismatch))
+			}
+		}
+		return mismatches
+	}
+
+	return []string{fmt.Sprintf("usage report %d: expect present, got absent", u.UrrId)}
+}
+
+func (c CreatedPDRExpect) evaluate(createdPDRs []*ie.IE) []string {
+	for _, item := range createdPDRs {
+		pdrId, err := item.PDRID()
+		if err != nil || pdrId != c.PdrId {
+			continue
+		}
+
+		if c.FTEIDIpv4 == "" {
+			return nil
+		}
+
+		fteid, err := item.FTEID()
+		if err != nil {
+			return []string{fmt.Sprintf("created PDR %d: F-TEID parse failed: %v", c.PdrId, err)}
+		}
+		if !fteid.IPv4Address.Equal(net.ParseIP(c.FTEIDIpv4)) {
+			return []string{fmt.Sprintf("created PDR %d: expect F-TEID IPv4 %s, got %s", c.PdrId, c.FTEIDIpv4, fteid.IPv4Address)}
+		}
+		return nil
+	}
+
+	return []string{fmt.Sprintf("created PDR %d: expect present, got absent", c.PdrId)}
+}
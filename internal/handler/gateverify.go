@@ -0,0 +1,247 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"upftester/internal/dataplane"
+	"upftester/internal/report"
+
+	"github.com/wmnsk/go-pfcp/ie"
+	"gopkg.in/yaml.v3"
+)
+
+// 门控核对中各方向的观测结果
+const (
+	gateForwarded = "forwarded"
+	gateBlocked   = "blocked"
+	gateUntested  = "untested" // 该方向没有流量经过，无法判断
+)
+
+// GateVerifyConfig 门控核对配置，由 gate_verification 步骤的 path 指定，
+// 同一文件中的数据平面测试参数用于发送流量
+type GateVerifyConfig struct {
+	UrrId  *uint32     `yaml:"urrId"`  // 查询该 URR 的上行用量，用于在没有回送时判断上行是否被转发 (可选)
+	Expect *GateExpect `yaml:"expect"` // 期望的门控状态，默认取会话当前 QER 的门控状态
+
+	Traffic *dataplane.DataPlaneTestConfig `yaml:"-"`
+}
+
+// GateExpect 期望的上行/下行门控状态，取值 OPEN 或 CLOSED
+type GateExpect struct {
+	UL string `yaml:"ul"`
+	DL string `yaml:"dl"`
+}
+
+// LoadGateVerifyConfig 从文件加载门控核对配置，action 为运行的数据平面测试类型
+func LoadGateVerifyConfig(path, action string, td *TemplateData) (*GateVerifyConfig, error) {
+	data, err := renderFile(path, td)
+	if err != nil {
+		return nil, err
+	}
+	traffic, err := dataplane.ParseDataPlaneTestConfigAs(data, action)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg GateVerifyConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("unmarshal gate verification config failed: %w", err)
+	}
+	cfg.Traffic = traffic
+
+	// 上行没有送达 DN 仿真器时由其发起下行探测，参数需满足下行测试的要求
+	if traffic.DnEmulator {
+		if _, err := dataplane.ParseDataPlaneTestConfigAs(data, "downlink"); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.Expect != nil {
+		for _, gate := range []string{cfg.Expect.UL, cfg.Expect.DL} {
+			if _, err := parseGate(gate); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return &cfg, nil
+}
+
+// loadGateVerificationStep 校验门控核对动作并加载核对配置
+func loadGateVerificationStep(step TestStep, td *TemplateData) (interface{}, error) {
+	if step.Action != "icmp" && step.Action != "udp" {
+		return nil, fmt.Errorf("unsupported gate verification action: %s", step.Action)
+	}
+	return LoadGateVerifyConfig(step.Path, step.Action, td)
+}
+
+func parseGate(name string) (uint8, error) {
+	switch strings.ToUpper(name) {
+	case "OPEN":
+		return ie.GateStatusOpen, nil
+	case "CLOSED":
+		return ie.GateStatusClosed, nil
+	default:
+		return 0, fmt.Errorf("invalid gate status %q, expect OPEN or CLOSED", name)
+	}
+}
+
+func gateName(gate uint8) string {
+	if gate == ie.GateStatusOpen {
+		return "OPEN"
+	}
+	return "CLOSED"
+}
+
+// expectedGates 返回期望的上行/下行门控状态
+func (cfg *GateVerifyConfig) expectedGates(ctx *SessionContext) (ul, dl uint8) {
+	if cfg.Expect == nil {
+		return ctx.GateStatus()
+	}
+	ul, _ = parseGate(cfg.Expect.UL)
+	dl, _ = parseGate(cfg.Expect.DL)
+	return ul, dl
+}
+
+// evaluateGates 根据回送的包数和 (可选的) 单方向观测结果判断两个方向是否被转发，并与期望的门控状态比较。
+// 上行流量经 DN 侧回送后才能在 gNB 侧观测到：收到回送说明两个方向都已转发；没有回送时，
+// 上行按 DN 仿真器或 URR 上行用量判断，都没有时由打开的下行门控推断上行被阻断；
+// 下行按 DN 仿真器发起的下行探测判断，没有探测时上行被转发而没有回送说明下行被阻断。
+// 无法判断的方向期望打开时计为失败，期望关闭时记为跳过
+func evaluateGates(expectUL, expectDL uint8, delivered int, uplinkForwarded, downlinkForwarded *bool) ([]report.GateCheck, []string) {
+	uplink := gateUntested
+	switch {
+	case delivered > 0:
+		uplink = gateForwarded
+	case uplinkForwarded != nil && *uplinkForwarded:
+		uplink = gateForwarded
+	case uplinkForwarded != nil, expectDL == ie.GateStatusOpen:
+		uplink = gateBlocked
+	}
+
+	downlink := gateUntested
+	switch {
+	case delivered > 0:
+		downlink = gateForwarded
+	case downlinkForwarded != nil && *downlinkForwarded:
+		downlink = gateForwarded
+	case downlinkForwarded != nil, uplink == gateForwarded:
+		downlink = gateBlocked
+	}
+
+	var checks []report.GateCheck
+	var mismatches []string
+	for _, c := range []struct {
+		direction string
+		expect    uint8
+		observed  string
+	}{
+		{"uplink", expectUL, uplink},
+		{"downlink", expectDL, downlink},
+	} {
+		check := report.GateCheck{
+			Direction: c.direction,
+			Expected:  gateName(c.expect),
+			Observed:  c.observed,
+		}
+		switch {
+		case c.observed != gateUntested:
+			check.Passed = (c.expect == ie.GateStatusOpen) == (c.observed == gateForwarded)
+			if !check.Passed {
+				mismatches = append(mismatches, fmt.Sprintf("gate %s: expect %s, traffic %s (%d packets delivered)",
+					c.direction, check.Expected, c.observed, delivered))
+			}
+		case c.expect == ie.GateStatusOpen:
+			mismatches = append(mismatches, fmt.Sprintf("gate %s: expect OPEN, no traffic observed in this direction",
+				c.direction))
+		default:
+			check.Skipped = true
+		}
+		checks = append(checks, check)
+	}
+
+	return checks, mismatches
+}
+
+// runGateVerification 发送流量并按会话 QER 的门控状态核对上下行是否被转发
+func (r *testRunner) runGateVerification(testcase TestCase, step *report.StepResult) error {
+	if r.sessionCtx == nil {
+		return fmt.Errorf("no active session for gate verification")
+	}
+
+	cfg, err := LoadGateVerifyConfig(testcase.Path, testcase.Action, r.templateData(testcase))
+	if err != nil {
+		return err
+	}
+	expectUL, expectDL := cfg.expectedGates(r.sessionCtx)
+	log.Printf("Verifying gate status, expect UL=%s DL=%s", gateName(expectUL), gateName(expectDL))
+
+	result, err := r.runDataPlaneTest(testcase.Action, cfg.Traffic)
+	if err != nil {
+		return err
+	}
+	step.DataPlane = newDataPlaneResult(result)
+
+	// 启用 DN 仿真器时上行在 DN 侧直接观测；上行没有送达时没有回送，由 DN 仿真器发起下行探测
+	var uplinkForwarded, downlinkForwarded *bool
+	var probe *dataplane.DataPlaneTestResult
+	if d := result.Directions; d != nil {
+		forwarded := d.UplinkReceived > 0
+		uplinkForwarded = &forwarded
+		if d.DownlinkSent == 0 {
+			log.Printf("No uplink traffic echoed by the DN emulator, probing downlink from DN")
+			probe, err = r.runDataPlaneTest("downlink", cfg.Traffic)
+			if err != nil {
+				return err
+			}
+			forwarded := probe.PacketsReceived > 0
+			downlinkForwarded = &forwarded
+		}
+	}
+
+	if cfg.UrrId != nil {
+		usageReports, err := r.queryURR([]uint32{*cfg.UrrId}, step)
+		if err != nil {
+			return err
+		}
+		var reports []UsageReport
+		for _, item := range usageReports {
+			usage, err := parseUsageReport(item)
+			if err != nil {
+				return fmt.Errorf("parse usage report failed: %w", err)
+			}
+			reports = append(reports, usage)
+		}
+		usage, _ := mergeUsageReports(reports, *cfg.UrrId)
+		forwarded := (usage.UplinkPackets != nil && *usage.UplinkPackets > 0) ||
+			(usage.UplinkVolume != nil && *usage.UplinkVolume > 0)
+		uplinkForwarded = &forwarded
+	}
+
+	checks, mismatches := evaluateGates(expectUL, expectDL, result.PacketsReceived, uplinkForwarded, downlinkForwarded)
+	step.GateChecks = checks
+
+	// 只有被转发的流量计入 URR 核对的测试端计数；查询 URR 后 UPF 重新计量，测试端计数同步清零
+	if cfg.UrrId != nil {
+		r.sessionCtx.Traffic = TrafficCounters{}
+	} else {
+		if checks[0].Observed == gateForwarded {
+			r.sessionCtx.Traffic.UplinkPackets += uint64(result.PacketsSent)
+			r.sessionCtx.Traffic.UplinkBytes += uint64(result.BytesSent)
+		}
+		r.sessionCtx.Traffic.DownlinkPackets += uint64(result.PacketsReceived)
+		r.sessionCtx.Traffic.DownlinkBytes += uint64(result.BytesReceived)
+		if probe != nil {
+			r.sessionCtx.Traffic.DownlinkPackets += uint64(probe.PacketsReceived)
+			r.sessionCtx.Traffic.DownlinkBytes += uint64(probe.BytesReceived)
+		}
+	}
+
+	if len(mismatches) > 0 {
+		log.Printf("gate verification failed: %v", mismatches)
+		return &StepFailure{Step: testcase.Step, Type: testcase.Type, Mismatches: mismatches}
+	}
+	log.Printf("Gate verification passed, %d packets delivered", result.PacketsReceived)
+	return nil
+}
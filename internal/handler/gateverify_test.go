@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/wmnsk/go-pfcp/ie"
+)
+
+func TestEvaluateGates(t *testing.T) {
+	open, closed := ie.GateStatusOpen, ie.GateStatusClosed
+	yes, no := true, false
+
+	tests := []struct {
+		name              string
+		expectUL          uint8
+		expectDL          uint8
+		delivered         int
+		uplinkForwarded   *bool
+		downlinkForwarded *bool
+		want              [2]string
+		wantSkipped       [2]bool
+		wantMismatches    int
+	}{
+		{name: "open and delivered", expectUL: open, expectDL: open, delivered: 5,
+			want: [2]string{gateForwarded, gateForwarded}},
+		{name: "open but nothing delivered", expectUL: open, expectDL: open,
+			want: [2]string{gateBlocked, gateUntested}, wantMismatches: 2},
+		{name: "uplink closed but delivered", expectUL: closed, expectDL: open, delivered: 5,
+			want: [2]string{gateForwarded, gateForwarded}, wantMismatches: 1},
+		{name: "downlink closed without urr", expectUL: open, expectDL: closed,
+			want: [2]string{gateUntested, gateUntested}, wantSkipped: [2]bool{false, true}, wantMismatches: 1},
+		{name: "downlink closed with uplink usage", expectUL: open, expectDL: closed, uplinkForwarded: &yes,
+			want: [2]string{gateForwarded, gateBlocked}},
+		{name: "downlink closed without uplink usage", expectUL: open, expectDL: closed, uplinkForwarded: &no,
+			want: [2]string{gateBlocked, gateUntested}, wantSkipped: [2]bool{false, true}, wantMismatches: 1},
+		{name: "uplink closed without downlink probe", expectUL: closed, expectDL: open,
+			want: [2]string{gateBlocked, gateUntested}, wantMismatches: 1},
+		{name: "uplink closed with downlink probe", expectUL: closed, expectDL: open, uplinkForwarded: &no, downlinkForwarded: &yes,
+			want: [2]string{gateBlocked, gateForwarded}},
+		{name: "both closed without observation", expectUL: closed, expectDL: closed,
+			want: [2]string{gateUntested, gateUntested}, wantSkipped: [2]bool{true, true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checks, mismatches := evaluateGates(tt.expectUL, tt.expectDL, tt.delivered, tt.uplinkForwarded, tt.downlinkForwarded)
+			if got := [2]string{checks[0].Observed, checks[1].Observed}; got != tt.want {
+				t.Errorf("evaluateGates() observed = %v, want %v", got, tt.want)
+			}
+			if got := [2]bool{checks[0].Skipped, checks[1].Skipped}; got != tt.wantSkipped {
+				t.Errorf("evaluateGates() skipped = %v, want %v", got, tt.wantSkipped)
+			}
+			if len(mismatches) != tt.wantMismatches {
+				t.Errorf("evaluateGates() mismatches = %v, want %d", mismatches, tt.wantMismatches)
+			}
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+	"upftester/internal/dataplane"
+	"upftester/internal/report"
+
+	"github.com/wmnsk/go-pfcp/ie"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultEndMarkerTimeout 等待 End Marker 的默认时间（毫秒）
+const defaultEndMarkerTimeout = 1000
+
+// HandoverConfig 路径切换配置，由 handover 步骤的 path 指定，
+// 同一文件中的数据平面测试参数用于切换后在目标 gNB 上发送流量
+type HandoverConfig struct {
+	FarId            uint32 `yaml:"farId"`            // 下行 FAR
+	TargetGnbIp      string `yaml:"targetGnbIp"`      // 目标 gNB 地址，默认取 dataPlane.targetGnbIp
+	Teid             uint32 `yaml:"teid"`             // 目标 gNB 的下行 TEID，默认取 dataPlane.targetGnbTeid
+	Sndem            bool   `yaml:"sndem"`            // 在 Update Forwarding Parameters 中设置 PFCPSMReq-Flags 的 SNDEM，要求 UPF 在源路径发送 End Marker
+	EndMarkerTimeout int    `yaml:"endMarkerTimeout"` // 等待 End Marker 的时间（毫秒），默认 1000
+
+	Traffic *dataplane.DataPlaneTestConfig `yaml:"-"`
+}
+
+// LoadHandoverConfig 从文件加载路径切换配置，action 为运行的数据平面测试类型
+func LoadHandoverConfig(path, action string, td *TemplateData) (*HandoverConfig, error) {
+	data, err := renderFile(path, td)
+	if err != nil {
+		return nil, err
+	}
+	traffic, err := dataplane.ParseDataPlaneTestConfigAs(data, action)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg HandoverConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("unmarshal handover config failed: %w", err)
+	}
+	cfg.Traffic = traffic
+
+	if cfg.FarId == 0 {
+		return nil, fmt.Errorf("handover: farId is required")
+	}
+	if cfg.EndMarkerTimeout == 0 {
+		cfg.EndMarkerTimeout = defaultEndMarkerTimeout
+	}
+
+	return &cfg, nil
+}
+
+// loadHandoverStep 校验切换动作并加载切换配置
+func loadHandoverStep(step TestStep, td *TemplateData) (interface{}, error) {
+	if step.Action != "icmp" && step.Action != "udp" {
+		return nil, fmt.Errorf("unsupported handover action: %s", step.Action)
+	}
+	return LoadHandoverConfig(step.Path, step.Action, td)
+}
+
+// pathMonitor 切换期间监听源 gNB，记录 End Marker 和仍然到达源路径的下行报文
+type pathMonitor struct {
+	receiver *dataplane.Receiver
+	endMark  chan struct{}
+
+	mu         sync.Mutex
+	endMarkers int
+	firstMark  time.Time
+	packets    int
+}
+
+func newPathMonitor(gnbIP string, teid uint32, ueIP string) *pathMonitor {
+	m := &pathMonitor{
+		receiver: dataplane.NewReceiver(gnbIP, 2152, teid, ueIP),
+		endMark:  make(chan struct{}),
+	}
+	m.receiver.SetHandler(func(inner []byte, receivedAt time.Time) {
+		m.mu.Lock()
+		m.packets++
+		m.mu.Unlock()
+	})
+	m.receiver.SetEndMarkerHandler(func(teid uint32, receivedAt time.Time) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.endMarkers++
+		if m.endMarkers == 1 {
+			m.firstMark = receivedAt
+			close(m.endMark)
+		}
+	})
+	return m
+}
+
+// waitEndMarker 等待首个 End Marker，超时或 ctx 取消时返回 false
+func (m *pathMonitor) waitEndMarker(ctx context.Context, timeout time.Duration) bool {
+	select {
+	case <-m.endMark:
+		return true
+	case <-time.After(timeout):
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// runHandover 更新下行 FAR 的 Outer Header Creation 将下行切换到目标 gNB，
+// 核对源路径上的 End Marker，并在目标 gNB 上发送流量确认下行已切换
+func (r *testRunner) runHandover(testcase TestCase, step *report.StepResult) error {
+	if r.sessionCtx == nil {
+		return fmt.Errorf("no active session for handover")
+	}
+
+	cfg, err := LoadHandoverConfig(testcase.Path, testcase.Action, r.templateData(testcase))
+	if err != nil {
+		return err
+	}
+	globalConfig := r.t.cfg
+
+	targetIP := cfg.TargetGnbIp
+	if targetIP == "" {
+		targetIP = globalConfig.DataPlane.TargetGnbIp
+	}
+	if targetIP == "" {
+		return fmt.Errorf("handover: target gNB IP is not configured")
+	}
+	targetTEID := cfg.Teid
+	if targetTEID == 0 {
+		targetTEID = globalConfig.DataPlane.TargetGnbTeid
+	}
+	if targetTEID == 0 && r.sessionCtx.Resources != nil {
+		if targetTEID, err = r.sessionCtx.Resources.AllocateTEID(); err != nil {
+			return err
+		}
+	}
+	if targetTEID == 0 {
+		return fmt.Errorf("handover: target TEID is not configured")
+	}
+
+	sourceIP := globalConfig.DataPlane.GnbIp
+	if r.sessionCtx.GnbIP != "" {
+		sourceIP = r.sessionCtx.GnbIP
+	}
+	result := &report.HandoverResult{
+		SourceGnbIp:        sourceIP,
+		SourceTeid:         r.sessionCtx.DownlinkTEID,
+		TargetGnbIp:        targetIP,
+		TargetTeid:         targetTEID,
+		EndMarkerRequested: cfg.Sndem,
+	}
+	step.Handover = result
+
+	monitor := newPathMonitor(sourceIP, r.sessionCtx.DownlinkTEID, r.sessionCtx.UEIP)
+	if err := monitor.receiver.Start(); err != nil {
+		return fmt.Errorf("start source path monitor failed: %w", err)
+	}
+	defer monitor.receiver.Stop()
+
+	forwarding := []*ie.IE{
+		ie.NewDestinationInterface(ie.DstInterfaceAccess),
+		ie.NewOuterHeaderCreation(0x0100, targetTEID, targetIP, "", 0, 0, 0),
+	}
+	// SNDEM 属于 Update Forwarding Parameters 中的 PFCPSMReq-Flags (3GPP TS 29.244 表 7.5.4.3-2)
+	if cfg.Sndem {
+		forwarding = append(forwarding, ie.NewPFCPSMReqFlags(0x02))
+	}
+	ies := []*ie.IE{
+		ie.NewUpdateFAR(
+			ie.NewFARID(cfg.FarId),
+			ie.NewUpdateForwardingParameters(forwarding...),
+		),
+	}
+
+	log.Printf("Sending handover request, UPF SEID: 0x%016x, FAR ID: %d, %s/%d -> %s/%d, SNDEM: %v",
+		r.upfSeid, cfg.FarId, sourceIP, result.SourceTeid, targetIP, targetTEID, cfg.Sndem)
+	sentAt := time.Now()
+	if _, err := r.modify(ies, "handover", step); err != nil {
+		return err
+	}
+
+	r.sessionCtx.DownlinkTEID = targetTEID
+	r.sessionCtx.GnbIP = targetIP
+	r.t.sessions.UpdateSession(r.smfSeid, r.sessionCtx)
+
+	var mismatches []string
+	if cfg.Sndem && !monitor.waitEndMarker(r.ctx, time.Duration(cfg.EndMarkerTimeout)*time.Millisecond) {
+		mismatches = append(mismatches, fmt.Sprintf("end marker: none received on %s within %dms",
+			sourceIP, cfg.EndMarkerTimeout))
+	}
+
+	traffic, err := r.runDataPlaneTest(testcase.Action, cfg.Traffic)
+	if err != nil {
+		return err
+	}
+	step.DataPlane = newDataPlaneResult(traffic)
+
+	monitor.mu.Lock()
+	result.EndMarkers = monitor.endMarkers
+	result.SourcePathPackets = monitor.packets
+	if monitor.endMarkers > 0 {
+		latency := float64(monitor.firstMark.Sub(sentAt)) / float64(time.Millisecond)
+		result.EndMarkerLatencyMs = &latency
+	}
+	monitor.mu.Unlock()
+
+	r.sessionCtx.Traffic.UplinkPackets += uint64(traffic.PacketsSent)
+	r.sessionCtx.Traffic.UplinkBytes += uint64(traffic.BytesSent)
+	r.sessionCtx.Traffic.DownlinkPackets += uint64(traffic.PacketsReceived)
+	r.sessionCtx.Traffic.DownlinkBytes += uint64(traffic.BytesReceived)
+
+	if traffic.PacketsReceived == 0 {
+		mismatches = append(mismatches, fmt.Sprintf("downlink: no packets received on target gNB %s", targetIP))
+	}
+	if result.SourcePathPackets > 0 {
+		mismatches = append(mismatches, fmt.Sprintf("downlink: %d packets still delivered to source gNB %s",
+			result.SourcePathPackets, sourceIP))
+	}
+
+	if len(mismatches) > 0 {
+		log.Printf("handover verification failed: %v", mismatches)
+		return &StepFailure{Step: testcase.Step, Type: testcase.Type, Mismatches: mismatches}
+	}
+	log.Printf("Handover verified, %d end markers, %d packets delivered to target gNB",
+		result.EndMarkers, traffic.PacketsReceived)
+	return nil
+}
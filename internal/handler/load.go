@@ -0,0 +1,392 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sync"
+	"time"
+	"upftester/internal/report"
+
+	"github.com/wmnsk/go-pfcp/message"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultLoadInterval CPS 负载默认的统计间隔（毫秒）
+const defaultLoadInterval = 1000
+
+// loadLatencyBuckets 响应时延直方图的桶上限（毫秒）
+var loadLatencyBuckets = []float64{1, 2, 5, 10, 20, 50, 100, 200, 500, 1000, 2000, 5000}
+
+// CPSConfig CPS 负载配置，由 load 步骤的 path 指定
+type CPSConfig struct {
+	Template       string  `yaml:"template"`       // 会话建立请求模板，相对于本文件所在目录
+	Cps            float64 `yaml:"cps"`            // 目标建立速率 (会话/秒)
+	Duration       int     `yaml:"duration"`       // 发起新会话的时长（秒），之后等待保持中的会话删除完成
+	HoldTime       int     `yaml:"holdTime"`       // 会话建立后保持的时间（毫秒），到期后删除
+	MaxOutstanding int     `yaml:"maxOutstanding"` // 同时存在的会话上限，达到时跳过本次建立，0 表示不限
+	Interval       int     `yaml:"interval"`       // 统计间隔（毫秒），默认 1000
+	MinSuccessRate float64 `yaml:"minSuccessRate"` // 步骤通过所需的最低建立成功率 (百分比)，默认 100
+}
+
+// LoadCPSConfig 从文件加载 CPS 负载配置
+func LoadCPSConfig(path string, td *TemplateData) (*CPSConfig, error) {
+	data, err := renderFile(path, td)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg CPSConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("unmarshal load config failed: %w", err)
+	}
+
+	if cfg.Template == "" {
+		return nil, fmt.Errorf("load: template is required")
+	}
+	if !filepath.IsAbs(cfg.Template) {
+		cfg.Template = filepath.Join(filepath.Dir(path), cfg.Template)
+	}
+	if est, err := loadEstablishment(cfg.Template, td); err != nil {
+		return nil, fmt.Errorf("load: invalid template: %w", err)
+	} else if _, err := est.Encode(nil); err != nil {
+		return nil, fmt.Errorf("load: invalid template: %w", err)
+	}
+	if cfg.Cps <= 0 {
+		return nil, fmt.Errorf("load: cps must be positive")
+	}
+	if cfg.Duration <= 0 {
+		return nil, fmt.Errorf("load: duration must be positive")
+	}
+	if cfg.HoldTime < 0 || cfg.MaxOutstanding < 0 {
+		return nil, fmt.Errorf("load: holdTime and maxOutstanding must not be negative")
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultLoadInterval
+	}
+	if cfg.MinSuccessRate == 0 {
+		cfg.MinSuccessRate = 100
+	}
+	if cfg.MinSuccessRate < 0 || cfg.MinSuccessRate > 100 {
+		return nil, fmt.Errorf("load: minSuccessRate must be within 0-100")
+	}
+
+	return &cfg, nil
+}
+
+// loadCPSStep 校验负载动作并加载 CPS 配置
+func loadCPSStep(step TestStep, td *TemplateData) (interface{}, error) {
+	if step.Action != "cps" {
+		return nil, fmt.Errorf("unsupported load action: %s", step.Action)
+	}
+	return LoadCPSConfig(step.Path, td)
+}
+
+// runLoad 执行 load 步骤，按目标 CPS 持续建立、保持并删除会话
+func (r *testRunner) runLoad(testcase TestCase, step *report.StepResult) error {
+	if testcase.Action != "cps" {
+		return fmt.Errorf("unsupported load action: %s", testcase.Action)
+	}
+
+	td := r.templateData(testcase)
+	cfg, err := LoadCPSConfig(testcase.Path, td)
+	if err != nil {
+		return err
+	}
+	result := newLoadGenerator(r, cfg, td).run()
+	step.Load = result
+
+	var mismatches []string
+	if result.Attempted == 0 {
+		mismatches = append(mismatches, "no session attempted")
+	} else if rate := float64(result.Established) * 100 / float64(result.Attempted); rate < cfg.MinSuccessRate {
+		mismatches = append(mismatches, fmt.Sprintf("establishment success rate: expect >= %.2f%%, got %.2f%% (%d/%d)",
+			cfg.MinSuccessRate, rate, result.Established, result.Attempted))
+	}
+	if result.DeleteFailed > 0 {
+		mismatches = append(mismatches, fmt.Sprintf("deletion failed for %d sessions", result.DeleteFailed))
+	}
+	if len(mismatches) > 0 {
+		if result.FirstFailure != "" {
+			mismatches = append(mismatches, "first failure: "+result.FirstFailure)
+		}
+		log.Printf("load verification failed: %v", mismatches)
+		return &StepFailure{Step: testcase.Step, Type: testcase.Type, Mismatches: mismatches}
+	}
+	return nil
+}
+
+// loadCounters CPS 负载的累计计数
+type loadCounters struct {
+	attempted       int
+	established     int
+	establishFailed int
+	deleted         int
+	deleteFailed    int
+	timeouts        int
+	throttled       int
+	reports         int
+}
+
+// loadGenerator CPS 负载生成器，每个会话由一个 goroutine 建立、保持并删除，
+// 保持期间会话按 CP SEID 注册到分发器，接收 UPF 发起的 Session Report Request
+type loadGenerator struct {
+	r   *testRunner
+	cfg *CPSConfig
+	td  *TemplateData // 渲染会话建立请求模板的数据
+	wg  sync.WaitGroup
+
+	mu           sync.Mutex
+	start        time.Time
+	total        loadCounters
+	last         loadCounters // 上一个统计间隔结束时的计数
+	lastSample   time.Time
+	outstanding  int // 建立中和已建立未删除的会话
+	peak         int
+	establishLat *latencyHistogram
+	deleteLat    *latencyHistogram
+	intervals    []report.LoadInterval
+	firstFailure string
+}
+
+func newLoadGenerator(r *testRunner, cfg *CPSConfig, td *TemplateData) *loadGenerator {
+	return &loadGenerator{
+		r:            r,
+		cfg:          cfg,
+		td:           td,
+		establishLat: newLatencyHistogram(),
+		deleteLat:    newLatencyHistogram(),
+	}
+}
+
+// run 在配置的时长内按目标速率发起会话，然后等待所有会话删除完成，返回结果。ctx 取消时停止发起并提前删除保持中的会话
+func (g *loadGenerator) run() *report.LoadResult {
+	log.Printf("Starting CPS load: %.1f cps for %ds, hold %dms, template %s", g.cfg.Cps, g.cfg.Duration, g.cfg.HoldTime, g.cfg.Template)
+
+	g.start = time.Now()
+	g.lastSample = g.start
+
+	launch := time.NewTicker(time.Duration(float64(time.Second) / g.cfg.Cps))
+	defer launch.Stop()
+	sample := time.NewTicker(time.Duration(g.cfg.Interval) * time.Millisecond)
+	defer sample.Stop()
+	end := time.After(time.Duration(g.cfg.Duration) * time.Second)
+
+generate:
+	for {
+		select {
+		case <-end:
+			break generate
+		case <-g.r.ctx.Done():
+			break generate
+		case <-sample.C:
+			g.sample()
+		case <-launch.C:
+			g.launch()
+		}
+	}
+	generated := time.Since(g.start)
+
+	// 停止发起后继续统计，直到保持中的会话全部删除
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+drain:
+	for {
+		select {
+		case <-done:
+			break drain
+		case <-sample.C:
+			g.sample()
+		}
+	}
+	g.sample()
+
+	result := g.result(generated)
+	log.Printf("CPS load completed: %.1f/%.1f cps, established %d/%d, deleted %d, timeouts %d, peak outstanding %d",
+		result.AchievedCps, result.TargetCps, result.Established, result.Attempted, result.Deleted, result.Timeouts, result.PeakOutstanding)
+	return result
+}
+
+// launch 发起一个会话，达到会话数上限时跳过
+func (g *loadGenerator) launch() {
+	g.mu.Lock()
+	if g.cfg.MaxOutstanding > 0 && g.outstanding >= g.cfg.MaxOutstanding {
+		g.total.throttled++
+		g.mu.Unlock()
+		return
+	}
+	g.total.attempted++
+	g.outstanding++
+	if g.outstanding > g.peak {
+		g.peak = g.outstanding
+	}
+	g.mu.Unlock()
+
+	g.wg.Add(1)
+	go g.session()
+}
+
+// session 建立会话，保持 holdTime 后删除
+func (g *loadGenerator) session() {
+	defer g.wg.Done()
+
+	ch := make(chan *PFCPMessage, 5)
+	ctx, latency, err := g.r.establishSession(g.cfg.Template, g.td, ch)
+	g.mu.Lock()
+	g.observe(g.establishLat, latency, err)
+	if err != nil {
+		g.total.establishFailed++
+		g.outstanding--
+		g.mu.Unlock()
+		return
+	}
+	g.total.established++
+	g.mu.Unlock()
+
+	hold := time.NewTimer(time.Duration(g.cfg.HoldTime) * time.Millisecond)
+	defer hold.Stop()
+wait:
+	for {
+		select {
+		case msg := <-ch:
+			if msg.MessageType == message.MsgTypeSessionReportRequest {
+				g.mu.Lock()
+				g.total.reports++
+				g.mu.Unlock()
+			}
+		case <-hold.C:
+			break wait
+		case <-g.r.ctx.Done():
+			break wait
+		}
+	}
+
+	latency, err = g.r.t.deleteSession(ctx)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.observe(g.deleteLat, latency, err)
+	if err != nil {
+		// 删除失败的会话仍留在 UPF 上，继续计入 outstanding
+		g.total.deleteFailed++
+		return
+	}
+	g.total.deleted++
+	g.outstanding--
+}
+
+// observe 记录一次请求的结果，调用时需持有 g.mu。超时计入 timeouts，收到响应的请求 (包括被拒绝的) 计入直方图
+func (g *loadGenerator) observe(h *latencyHistogram, latency time.Duration, err error) {
+	if err != nil {
+		if g.firstFailure == "" {
+			g.firstFailure = err.Error()
+			log.Printf("CPS load request failed: %v", err)
+		}
+		if errors.Is(err, ErrNoResponse) {
+			g.total.timeouts++
+			return
+		}
+	}
+	if latency > 0 {
+		h.add(latency)
+	}
+}
+
+// sample 结束一个统计间隔
+func (g *loadGenerator) sample() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+	elapsed := now.Sub(g.lastSample)
+	if elapsed <= 0 {
+		return
+	}
+	interval := report.LoadInterval{
+		OffsetMs:    durationMs(now.Sub(g.start)),
+		Established: g.total.established - g.last.established,
+		Deleted:     g.total.deleted - g.last.deleted,
+		Failed:      g.total.establishFailed + g.total.deleteFailed - g.last.establishFailed - g.last.deleteFailed,
+		Timeouts:    g.total.timeouts - g.last.timeouts,
+		Outstanding: g.outstanding,
+	}
+	interval.Cps = float64(interval.Established) / elapsed.Seconds()
+	interval.TimeoutRate = float64(interval.Timeouts) / elapsed.Seconds()
+	g.intervals = append(g.intervals, interval)
+	g.last = g.total
+	g.lastSample = now
+
+	log.Printf("CPS load at %.0fms: %.1f cps, outstanding=%d, failed=%d, timeouts=%d",
+		interval.OffsetMs, interval.Cps, interval.Outstanding, interval.Failed, interval.Timeouts)
+}
+
+// result 返回负载结果，generated 为发起新会话的时长
+func (g *loadGenerator) result(generated time.Duration) *report.LoadResult {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	result := &report.LoadResult{
+		TargetCps:        g.cfg.Cps,
+		DurationMs:       durationMs(time.Since(g.start)),
+		Attempted:        g.total.attempted,
+		Established:      g.total.established,
+		EstablishFailed:  g.total.establishFailed,
+		Deleted:          g.total.deleted,
+		DeleteFailed:     g.total.deleteFailed,
+		Timeouts:         g.total.timeouts,
+		Throttled:        g.total.throttled,
+		PeakOutstanding:  g.peak,
+		SessionReports:   g.total.reports,
+		EstablishLatency: g.establishLat.result(),
+		DeleteLatency:    g.deleteLat.result(),
+		Intervals:        g.intervals,
+		FirstFailure:     g.firstFailure,
+	}
+	if generated > 0 {
+		result.AchievedCps = float64(g.total.established) / generated.Seconds()
+	}
+	return result
+}
+
+// latencyHistogram 按 loadLatencyBuckets 分桶的时延直方图
+type latencyHistogram struct {
+	counts   []int
+	overflow int
+	count    int
+	max      time.Duration
+}
+
+func newLatencyHistogram() *latencyHistogram {
+	return &latencyHistogram{counts: make([]int, len(loadLatencyBuckets))}
+}
+
+func (h *latencyHistogram) add(d time.Duration) {
+	h.count++
+	if d > h.max {
+		h.max = d
+	}
+	ms := durationMs(d)
+	for i, upper := range loadLatencyBuckets {
+		if ms <= upper {
+			h.counts[i]++
+			return
+		}
+	}
+	h.overflow++
+}
+
+func (h *latencyHistogram) result() *report.LatencyHistogram {
+	result := &report.LatencyHistogram{
+		Count:    h.count,
+		Buckets:  make([]report.LatencyBucket, len(loadLatencyBuckets)),
+		Overflow: h.overflow,
+		MaxMs:    durationMs(h.max),
+	}
+	for i, upper := range loadLatencyBuckets {
+		result.Buckets[i] = report.LatencyBucket{UpperMs: upper, Count: h.counts[i]}
+	}
+	return result
+}
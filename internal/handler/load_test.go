@@ -0,0 +1,28 @@
+package handler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLatencyHistogram(t *testing.T) {
+	h := newLatencyHistogram()
+	for _, d := range []time.Duration{
+		500 * time.Microsecond,
+		time.Millisecond,
+		3 * time.Millisecond,
+		3 * time.Millisecond,
+		6 * time.Second,
+	} {
+		h.add(d)
+	}
+
+	result := h.result()
+	if result.Count != 5 || result.Overflow != 1 || result.MaxMs != 6000 {
+		t.Fatalf("unexpected histogram %+v", result)
+	}
+	// 桶上限 1ms 包含 1ms，3ms 落在 (2, 5] 桶
+	if result.Buckets[0].Count != 2 || result.Buckets[2].UpperMs != 5 || result.Buckets[2].Count != 2 {
+		t.Errorf("unexpected buckets %+v", result.Buckets)
+	}
+}
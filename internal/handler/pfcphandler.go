@@ -3,6 +3,7 @@ package handler
 import (
 	"log"
 	"sync"
+	"time"
 	"upftester/internal/network"
 
 	"github.com/wmnsk/go-pfcp/message"
@@ -13,33 +14,35 @@ type PFCPMessage struct {
 	Sequence    uint32
 	SEID        uint64
 	Payload     []byte
+	ReceivedAt  time.Time // 分发器收到消息的时间
 }
 
+// PFCPDispatcher 接收 N4 消息，将响应按序列号匹配到请求事务，其余消息按 SEID 分发给注册的会话。
+// UPF 发起的 Heartbeat Request 和 Session Report Request 由分发器直接应答
 type PFCPDispatcher struct {
 	transport *network.UDPTransport
+	sessions  *SessionManager // 记录 Session Report Request 的会话
 
-	sessionMap sync.Map
-	wg         sync.WaitGroup
-	stopChan   chan struct{}
-}
-
-var (
-	dispatcherInstance *PFCPDispatcher
-	once               sync.Once
-)
+	recoveryTime time.Time // Heartbeat Response 中的 Recovery Time Stamp
+	reportCause  uint8     // Session Report Response 的 Cause，0 表示 Request Accepted
 
-func GetPFCPDispatcher() *PFCPDispatcher {
-	return dispatcherInstance
+	sessionMap   sync.Map
+	transactions sync.Map // 序列号 -> *Transaction
+	wg           sync.WaitGroup
+	stopChan     chan struct{}
 }
 
-func NewPFCPDispatcher(t *network.UDPTransport) *PFCPDispatcher {
-	once.Do(func() {
-		dispatcherInstance = &PFCPDispatcher{
-			transport: t,
-			stopChan:  make(chan struct{}),
-		}
-	})
-	return dispatcherInstance
+// NewPFCPDispatcher 创建分发器，sessions 为 nil 时所有 Session Report Request 以 Session context not found 应答
+func NewPFCPDispatcher(t *network.UDPTransport, sessions *SessionManager) *PFCPDispatcher {
+	if sessions == nil {
+		sessions = NewSessionManager()
+	}
+	return &PFCPDispatcher{
+		transport:    t,
+		sessions:     sessions,
+		recoveryTime: time.Now(),
+		stopChan:     make(chan struct{}),
+	}
 }
 
 func (d *PFCPDispatcher) Register(seid uint64, ch chan *PFCPMessage) {
@@ -74,6 +77,16 @@ func (d *PFCPDispatcher) run() {
 			if msg == nil {
 				continue
 			}
+			if d.matchTransaction(msg) {
+				continue
+			}
+			if msg.MessageType == message.MsgTypeHeartbeatRequest {
+				d.handleHeartbeat(msg, pkt.Addr)
+				continue
+			}
+			if msg.MessageType == message.MsgTypeSessionReportRequest {
+				d.handleSessionReport(msg, pkt.Addr)
+			}
 			d.dispatch(msg)
 		}
 	}
@@ -94,6 +107,7 @@ func (d *PFCPDispatcher) decodePFCP(data []byte) *PFCPMessage {
 			Sequence:    header.SequenceNumber,
 			SEID:        0, // maybe the default value is zero
 			Payload:     data,
+			ReceivedAt:  time.Now(),
 		}
 	} else {
 		return &PFCPMessage{
@@ -101,6 +115,7 @@ func (d *PFCPDispatcher) decodePFCP(data []byte) *PFCPMessage {
 			Sequence:    header.SequenceNumber,
 			SEID:        header.SEID,
 			Payload:     data,
+			ReceivedAt:  time.Now(),
 		}
 	}
 }
@@ -2,6 +2,10 @@ package handler
 
 import (
 	"sync"
+	"upftester/encoding/pfcp"
+	"upftester/internal/resource"
+
+	"github.com/wmnsk/go-pfcp/ie"
 )
 
 // SessionState 会话状态
@@ -19,24 +23,140 @@ const (
 // SessionContext 会话上下文，保存会话相关信息
 type SessionContext struct {
 	// 信令面标识
-	SEID uint64 // SMF 分配的 SEID
+	SEID    uint64 // SMF 分配的 SEID
 	UPFSEID uint64 // UPF 返回的 SEID
 
 	// 数据面标识
 	UplinkTEID   uint32 // 上行 TEID (N3 接口)
 	UplinkPDRID  uint16 // 上行 PDR ID (用于查找 TEID)
 	DownlinkTEID uint32 // 下行 TEID (N3 接口)
+	GnbIP        string // 下行当前指向的 gNB 地址，空表示配置的 gnbIp
 	UEIP         string // UE IP 地址
 
+	// 会话从资源池分配的 SEID、UE 地址和 TEID，会话删除时归还
+	Resources *resource.Session
+
+	// 会话当前的 QER，按 QER ID 索引
+	QERs map[uint32]pfcp.QER
+
+	// 接收步骤从响应中捕获的变量，后续步骤文件中以 {{ .Vars.<name> }} 引用
+	Vars map[string]interface{}
+
 	// 会话状态
 	State SessionState
 
+	// 建立会话的测试用例集，测试用例集结束时删除其遗留在 UPF 上的会话
+	owner *testRunner
+
 	// 数据平面测试句柄
 	DataPlaneTestHandle interface{}
 
+	// 自上次 URR 核对以来数据平面测试收发的流量
+	Traffic TrafficCounters
+
 	// 其他信息
 	CreatedAt int64
 	UpdatedAt int64
+
+	// UPF 上报的会话报告
+	reportsMu sync.Mutex
+	reports   []*SessionReport
+}
+
+// TrafficCounters 测试端收发的流量，字节数为内层 IP 包长度
+type TrafficCounters struct {
+	UplinkPackets   uint64
+	UplinkBytes     uint64
+	DownlinkPackets uint64
+	DownlinkBytes   uint64
+}
+
+// MBR 返回会话各 QER 中最小的上行/下行 MBR (kbps)，未配置时为 0
+func (ctx *SessionContext) MBR() (ul, dl uint64) {
+	for _, qer := range ctx.QERs {
+		if qer.MBR == nil {
+			continue
+		}
+		if qer.MBR.UL != nil && (ul == 0 || *qer.MBR.UL < ul) {
+			ul = *qer.MBR.UL
+		}
+		if qer.MBR.DL != nil && (dl == 0 || *qer.MBR.DL < dl) {
+			dl = *qer.MBR.DL
+		}
+	}
+	return ul, dl
+}
+
+// GateStatus 返回会话的上行/下行门控状态，任一 QER 关闭即为关闭 (ie.GateStatusClosed)
+func (ctx *SessionContext) GateStatus() (ul, dl uint8) {
+	for _, qer := range ctx.QERs {
+		if qer.GateStatus == nil {
+			continue
+		}
+		if qer.GateStatus.UL != ie.GateStatusOpen {
+			ul = ie.GateStatusClosed
+		}
+		if qer.GateStatus.DL != ie.GateStatusOpen {
+			dl = ie.GateStatusClosed
+		}
+	}
+	return ul, dl
+}
+
+// UpdateQER 按 Update QER 更新会话的 QER，只覆盖配置了的字段
+func (ctx *SessionContext) UpdateQER(update pfcp.QER) {
+	if update.QerId == nil {
+		return
+	}
+	if ctx.QERs == nil {
+		ctx.QERs = make(map[uint32]pfcp.QER)
+	}
+	qer := ctx.QERs[*update.QerId]
+	qer.QerId = update.QerId
+	if update.GateStatus != nil {
+		qer.GateStatus = update.GateStatus
+	}
+	if update.MBR != nil {
+		qer.MBR = update.MBR
+	}
+	ctx.QERs[*update.QerId] = qer
+}
+
+// SetVars 保存捕获的变量，同名变量覆盖旧值
+func (ctx *SessionContext) SetVars(vars map[string]interface{}) {
+	if len(vars) == 0 {
+		return
+	}
+	if ctx.Vars == nil {
+		ctx.Vars = make(map[string]interface{}, len(vars))
+	}
+	for name, value := range vars {
+		ctx.Vars[name] = value
+	}
+}
+
+// AddReport 记录 UPF 上报的会话报告
+func (ctx *SessionContext) AddReport(report *SessionReport) {
+	ctx.reportsMu.Lock()
+	defer ctx.reportsMu.Unlock()
+	ctx.reports = append(ctx.reports, report)
+}
+
+// Reports 返回已收到的会话报告
+func (ctx *SessionContext) Reports() []*SessionReport {
+	ctx.reportsMu.Lock()
+	defer ctx.reportsMu.Unlock()
+	return append([]*SessionReport(nil), ctx.reports...)
+}
+
+// LastReport 返回最近一次收到的会话报告，没有时返回 nil
+func (ctx *SessionContext) LastReport() *SessionReport {
+	ctx.reportsMu.Lock()
+	defer ctx.reportsMu.Unlock()
+	if len(ctx.reports) == 0 {
+		return nil
+	}
+	return ctx.reports[len(ctx.reports)-1]
 }
 
 // SessionManager 会话管理器
@@ -81,11 +201,28 @@ func (sm *SessionManager) DeleteSession(seid uint64) {
 	delete(sm.sessions, seid)
 }
 
+// RemoveSessions 在锁内将满足 match 的会话标记为已删除并移除，返回被移除的会话
+func (sm *SessionManager) RemoveSessions(match func(*SessionContext) bool) []*SessionContext {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	var removed []*SessionContext
+	for seid, ctx := range sm.sessions {
+		if !match(ctx) {
+			continue
+		}
+		ctx.State = SessionStateDeleted
+		delete(sm.sessions, seid)
+		removed = append(removed, ctx)
+	}
+	return removed
+}
+
 // GetAllSessions 获取所有会话
 func (sm *SessionManager) GetAllSessions() []*SessionContext {
 	sm.mu.RLock()
 	defer sm.mu.RUnlock()
-	
+
 	sessions := make([]*SessionContext, 0, len(sm.sessions))
 	for _, ctx := range sm.sessions {
 		sessions = append(sessions, ctx)
@@ -99,6 +236,3 @@ func (sm *SessionManager) Count() int {
 	defer sm.mu.RUnlock()
 	return len(sm.sessions)
 }
-
-// 全局会话管理器
-var GlobalSessionManager = NewSessionManager()
@@ -0,0 +1,218 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+// SessionReport UPF 上报的 Session Report Request
+type SessionReport struct {
+	ReceivedAt       time.Time
+	Sequence         uint32
+	ReportType       uint8         // Report Type 标志位 (DLDR/USAR/ERIR/UPIR)
+	UsageReports     []UsageReport // Usage Report
+	DownlinkDataPDRs []uint16      // Downlink Data Report 中的 PDR ID
+	ErrorIndications []string      // Error Indication Report 中的远端 F-TEID，格式 teid@ip
+}
+
+// UsageReport 解析后的 Usage Report，未携带的流量值为 nil
+type UsageReport struct {
+	URRID          uint32
+	URSEQN         uint32
+	Triggers       []string
+	UplinkVolume   *uint64
+	DownlinkVolume *uint64
+	TotalVolume    *uint64
+
+	UplinkPackets   *uint64
+	DownlinkPackets *uint64
+	TotalPackets    *uint64
+}
+
+// reportTypes Report Type 标志位名称 (3GPP TS 29.244 8.2.21)
+var reportTypes = map[string]uint8{
+	"DLDR": 0x01,
+	"USAR": 0x02,
+	"ERIR": 0x04,
+	"UPIR": 0x08,
+}
+
+// usageReportTriggers Usage Report Trigger 标志位，按 [字节, 掩码] 排列 (3GPP TS 29.244 8.2.41)
+var usageReportTriggers = map[string][2]uint8{
+	"PERIO": {0, 0x01},
+	"VOLTH": {0, 0x02},
+	"TIMTH": {0, 0x04},
+	"QUHTI": {0, 0x08},
+	"START": {0, 0x10},
+	"STOPT": {0, 0x20},
+	"DROTH": {0, 0x40},
+	"IMMER": {0, 0x80},
+	"VOLQU": {1, 0x01},
+	"TIMQU": {1, 0x02},
+	"LIUSA": {1, 0x04},
+	"TERMR": {1, 0x08},
+	"MONIT": {1, 0x10},
+	"ENVCL": {1, 0x20},
+	"MACAR": {1, 0x40},
+	"EVETH": {1, 0x80},
+	"EVEQU": {2, 0x01},
+	"TEBUR": {2, 0x02},
+	"IPMJL": {2, 0x04},
+	"QUVTI": {2, 0x08},
+	"EMRRE": {2, 0x10},
+	"UPINT": {2, 0x20},
+}
+
+// hasTrigger 判断触发器字节中是否设置了指定标志位
+func hasTrigger(octets []byte, name string) bool {
+	bit, ok := usageReportTriggers[name]
+	if !ok || int(bit[0]) >= len(octets) {
+		return false
+	}
+	return octets[bit[0]]&bit[1] != 0
+}
+
+// parseUsageReport 解析 Usage Report IE，适用于修改/删除响应和 Session Report Request
+func parseUsageReport(item *ie.IE) (UsageReport, error) {
+	var report UsageReport
+
+	ies, err := item.UsageReport()
+	if err != nil {
+		return report, err
+	}
+
+	for _, x := range ies {
+		switch x.Type {
+		case ie.URRID:
+			if report.URRID, err = x.URRID(); err != nil {
+				return report, fmt.Errorf("parse URR ID failed: %w", err)
+			}
+		case ie.URSEQN:
+			if report.URSEQN, err = x.URSEQN(); err != nil {
+				return report, fmt.Errorf("parse UR-SEQN failed: %w", err)
+			}
+		case ie.UsageReportTrigger:
+			octets, err := x.UsageReportTrigger()
+			if err != nil {
+				return report, fmt.Errorf("parse usage report trigger failed: %w", err)
+			}
+			for name := range usageReportTriggers {
+				if hasTrigger(octets, name) {
+					report.Triggers = append(report.Triggers, name)
+				}
+			}
+			sort.Strings(report.Triggers)
+		case ie.VolumeMeasurement:
+			volume, err := x.VolumeMeasurement()
+			if err != nil {
+				return report, fmt.Errorf("parse volume measurement failed: %w", err)
+			}
+			if volume.HasULVOL() {
+				report.UplinkVolume = &volume.UplinkVolume
+			}
+			if volume.HasDLVOL() {
+				report.DownlinkVolume = &volume.DownlinkVolume
+			}
+			if volume.HasTOVOL() {
+				report.TotalVolume = &volume.TotalVolume
+			}
+			if volume.HasULNOP() {
+				report.UplinkPackets = &volume.UplinkNumberOfPackets
+			}
+			if volume.HasDLNOP() {
+				report.DownlinkPackets = &volume.DownlinkNumberOfPackets
+			}
+			if volume.HasTONOP() {
+				report.TotalPackets = &volume.TotalNumberOfPackets
+			}
+		}
+	}
+
+	return report, nil
+}
+
+// newSessionReport 从 Session Report Request 中提取上报内容
+func newSessionReport(req *message.SessionReportRequest) *SessionReport {
+	report := &SessionReport{
+		ReceivedAt: time.Now(),
+		Sequence:   req.Sequence(),
+	}
+
+	if req.ReportType != nil {
+		if typ, err := req.ReportType.ReportType(); err == nil {
+			report.ReportType = typ
+		}
+	}
+
+	for _, item := range req.UsageReport {
+		usage, err := parseUsageReport(item)
+		if err != nil {
+			log.Printf("parse usage report failed: %v", err)
+			continue
+		}
+		report.UsageReports = append(report.UsageReports, usage)
+	}
+
+	if req.DownlinkDataReport != nil {
+		ies, err := req.DownlinkDataReport.DownlinkDataReport()
+		if err == nil {
+			for _, x := range ies {
+				if pdrId, err := x.PDRID(); err == nil {
+					report.DownlinkDataPDRs = append(report.DownlinkDataPDRs, pdrId)
+				}
+			}
+		}
+	}
+
+	if req.ErrorIndicationReport != nil {
+		ies, err := req.ErrorIndicationReport.ErrorIndicationReport()
+		if err == nil {
+			for _, x := range ies {
+				if fteid, err := x.FTEID(); err == nil {
+					report.ErrorIndications = append(report.ErrorIndications, fmt.Sprintf("%d@%s", fteid.TEID, fteid.IPv4Address))
+				}
+			}
+		}
+	}
+
+	return report
+}
+
+// handleSessionReport 记录 UPF 上报的会话报告并应答 Session Report Response
+func (d *PFCPDispatcher) handleSessionReport(msg *PFCPMessage, addr *net.UDPAddr) {
+	req, err := message.ParseSessionReportRequest(msg.Payload)
+	if err != nil {
+		log.Printf("session report request parse failed: %v", err)
+		return
+	}
+
+	cause := uint8(ie.CauseRequestAccepted)
+	if d.reportCause != 0 {
+		cause = d.reportCause
+	}
+
+	var upfSeid uint64
+	ctx, ok := d.sessions.GetSession(msg.SEID)
+	if ok {
+		ctx.AddReport(newSessionReport(req))
+		upfSeid = ctx.UPFSEID
+		log.Printf("Received session report request, SEID: 0x%016x, report type: 0x%02x", msg.SEID, ctx.LastReport().ReportType)
+	} else {
+		log.Printf("Received session report request for unknown SEID: 0x%016x", msg.SEID)
+		cause = ie.CauseSessionContextNotFound
+	}
+
+	resp := message.NewSessionReportResponse(0, 0, upfSeid, msg.Sequence, 0, ie.NewCause(cause))
+	data := make([]byte, resp.MarshalLen())
+	if err := resp.MarshalTo(data); err != nil {
+		log.Printf("marshal session report response failed: %v", err)
+		return
+	}
+	d.transport.Send(data, addr)
+}
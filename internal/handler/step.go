@@ -0,0 +1,227 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+	"upftester/encoding/pfcp"
+	"upftester/internal/dataplane"
+	"upftester/internal/network"
+	"upftester/internal/report"
+
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+// StepExecutor 测试步骤类型的加载与执行，通过 RegisterStep 按步骤类型注册
+type StepExecutor interface {
+	// Load 在加载测试用例集时校验步骤参数并加载步骤文件，不发送任何消息。
+	// 返回值保存在 TestCase.Config 中，td 为按空会话渲染的模板数据
+	Load(step TestStep, td *TemplateData) (interface{}, error)
+
+	// Run 执行步骤，将 Cause、时延等结果记录到 result；返回 *StepFailure 时其不匹配项记录到报告
+	Run(ctx *StepContext, testcase TestCase, result *report.StepResult) error
+}
+
+var (
+	stepsMu sync.RWMutex
+	steps   = make(map[string]StepExecutor)
+)
+
+// RegisterStep 注册步骤类型，需在加载测试用例集之前调用 (通常在 init 中)。
+// 同一类型重复注册或 executor 为 nil 时 panic
+func RegisterStep(stepType string, executor StepExecutor) {
+	stepsMu.Lock()
+	defer stepsMu.Unlock()
+	if executor == nil {
+		panic("handler: RegisterStep executor is nil")
+	}
+	if _, dup := steps[stepType]; dup {
+		panic("handler: RegisterStep called twice for step type " + stepType)
+	}
+	steps[stepType] = executor
+}
+
+// lookupStep 返回步骤类型的 executor
+func lookupStep(stepType string) (StepExecutor, bool) {
+	stepsMu.RLock()
+	defer stepsMu.RUnlock()
+	executor, ok := steps[stepType]
+	return executor, ok
+}
+
+// StepTypes 返回已注册的步骤类型，按名称排序
+func StepTypes() []string {
+	stepsMu.RLock()
+	defer stepsMu.RUnlock()
+	types := make([]string, 0, len(steps))
+	for stepType := range steps {
+		types = append(types, stepType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// StepContext 步骤执行时的运行上下文，在同一测试用例集的步骤之间共享
+type StepContext struct {
+	r *testRunner
+}
+
+// Session 返回当前会话上下文，尚未建立会话时为 nil
+func (c *StepContext) Session() *SessionContext {
+	return c.r.sessionCtx
+}
+
+// UPFSEID 返回当前会话的 UPF SEID，用于填写会话相关请求的头部
+func (c *StepContext) UPFSEID() uint64 {
+	return c.r.upfSeid
+}
+
+// Context 返回测试运行的 context，取消时步骤应尽快返回
+func (c *StepContext) Context() context.Context {
+	return c.r.ctx
+}
+
+// Tester 返回执行步骤的测试器
+func (c *StepContext) Tester() *Tester {
+	return c.r.t
+}
+
+// Transport 返回 N4 传输
+func (c *StepContext) Transport() *network.UDPTransport {
+	return c.r.t.transport
+}
+
+// RemoteAddr 返回 UPF 的 N4 地址
+func (c *StepContext) RemoteAddr() *net.UDPAddr {
+	return c.r.t.remoteAddr
+}
+
+// RenderFile 按当前会话渲染步骤文件
+func (c *StepContext) RenderFile(testcase TestCase) ([]byte, error) {
+	return renderFile(testcase.Path, c.r.templateData(testcase))
+}
+
+// Request 分配序列号后发送 PFCP 请求，按 T1/N1 重传并等待响应，将时延和重传次数记录到 result
+func (c *StepContext) Request(msg message.Message, result *report.StepResult) (*PFCPMessage, error) {
+	c.r.requestSentAt = time.Now()
+	txn, err := c.r.t.request(msg)
+	if err != nil {
+		return nil, err
+	}
+	c.r.txn = txn
+	resp, err := c.r.waitResponse(result)
+	if err != nil {
+		return nil, err
+	}
+	result.SetLatency(time.Since(c.r.requestSentAt))
+	return resp, nil
+}
+
+// builtinStep 内置步骤，执行函数直接访问测试用例集的执行状态
+type builtinStep struct {
+	load func(step TestStep, td *TemplateData) (interface{}, error)
+	run  func(r *testRunner, testcase TestCase, step *report.StepResult) error
+}
+
+func (s builtinStep) Load(step TestStep, td *TemplateData) (interface{}, error) {
+	return s.load(step, td)
+}
+
+func (s builtinStep) Run(ctx *StepContext, testcase TestCase, result *report.StepResult) error {
+	return s.run(ctx.r, testcase, result)
+}
+
+func init() {
+	builtins := map[string]builtinStep{
+		"session_establishment_request":  {loadEstablishmentStep, (*testRunner).runEstablishmentRequest},
+		"session_establishment_response": {loadExpectStep, (*testRunner).runEstablishmentResponse},
+		"session_modification_request":   {loadModificationStep, (*testRunner).runModificationRequest},
+		"session_modification_response":  {loadExpectStep, (*testRunner).runModificationResponse},
+		"session_deletion_request":       {loadDeletionStep, (*testRunner).runDeletionRequest},
+		"session_deletion_response":      {loadExpectStep, (*testRunner).runDeletionResponse},
+		"session_report_request":         {loadExpectStep, (*testRunner).runSessionReport},
+		"urr_verification":               {loadURRVerificationStep, (*testRunner).runURRVerification},
+		"gate_verification":              {loadGateVerificationStep, (*testRunner).runGateVerification},
+		"handover":                       {loadHandoverStep, (*testRunner).runHandover},
+		"buffering":                      {loadBufferingStep, (*testRunner).runBuffering},
+		"session_bulk":                   {loadBulkStep, (*testRunner).runBulk},
+		"load":                           {loadCPSStep, (*testRunner).runLoad},
+		"sleep":                          {loadSleepStep, (*testRunner).runSleep},
+		"data_plane_test":                {loadDataPlaneStep, (*testRunner).runDataPlaneStep},
+		"association_setup":              {loadAssociationStep, (*testRunner).runAssociation},
+		"association_update":             {loadAssociationStep, (*testRunner).runAssociation},
+		"association_release":            {loadAssociationStep, (*testRunner).runAssociation},
+	}
+	for stepType, step := range builtins {
+		RegisterStep(stepType, step)
+	}
+}
+
+// loadEstablishmentStep 渲染并编码会话建立请求，auto 字段编码为占位值
+func loadEstablishmentStep(step TestStep, td *TemplateData) (interface{}, error) {
+	if step.Path == "" {
+		return nil, fmt.Errorf("path is required")
+	}
+	cfg, err := loadEstablishment(step.Path, td)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := cfg.Encode(nil); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// loadModificationStep 渲染并编码会话修改请求
+func loadModificationStep(step TestStep, td *TemplateData) (interface{}, error) {
+	if step.Path == "" {
+		return nil, fmt.Errorf("path is required")
+	}
+	cfg, err := loadModification(step.Path, td)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := cfg.Encode(nil); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// loadDeletionStep 生成会话删除请求，SEID 在发送时设置
+func loadDeletionStep(step TestStep, td *TemplateData) (interface{}, error) {
+	return new(pfcp.DeletionRequestConfig).Marshal(step.Path)
+}
+
+// loadExpectStep 校验响应断言
+func loadExpectStep(step TestStep, td *TemplateData) (interface{}, error) {
+	if err := step.Expect.validate(); err != nil {
+		return nil, fmt.Errorf("invalid expect: %w", err)
+	}
+	return nil, nil
+}
+
+// loadSleepStep 校验 path 字段中的睡眠秒数
+func loadSleepStep(step TestStep, td *TemplateData) (interface{}, error) {
+	if step.Path != "" {
+		if _, err := strconv.Atoi(step.Path); err != nil {
+			return nil, fmt.Errorf("invalid sleep duration %q", step.Path)
+		}
+	}
+	return nil, nil
+}
+
+// loadDataPlaneStep 渲染并解析数据平面测试配置
+func loadDataPlaneStep(step TestStep, td *TemplateData) (interface{}, error) {
+	if step.Action != "icmp" && step.Action != "udp" && step.Action != "throughput" && step.Action != "downlink" {
+		return nil, fmt.Errorf("unsupported data plane test action: %s", step.Action)
+	}
+	data, err := renderFile(step.Path, td)
+	if err != nil {
+		return nil, err
+	}
+	return dataplane.ParseDataPlaneTestConfigAs(data, step.Action)
+}
@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+	"upftester/internal/report"
+
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+	"gopkg.in/yaml.v3"
+)
+
+// heartbeatStep 自定义步骤：按步骤文件中的 count 发送 Heartbeat Request
+type heartbeatStep struct{}
+
+type heartbeatConfig struct {
+	Count int `yaml:"count"`
+}
+
+func (heartbeatStep) Load(step TestStep, td *TemplateData) (interface{}, error) {
+	data, err := os.ReadFile(step.Path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := new(heartbeatConfig)
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, err
+	}
+	if cfg.Count <= 0 {
+		return nil, fmt.Errorf("invalid heartbeat count %d", cfg.Count)
+	}
+	return cfg, nil
+}
+
+func (heartbeatStep) Run(ctx *StepContext, testcase TestCase, result *report.StepResult) error {
+	for i := 0; i < testcase.Config.(*heartbeatConfig).Count; i++ {
+		req := message.NewHeartbeatRequest(0, ie.NewRecoveryTimeStamp(time.Now()), nil)
+		resp, err := ctx.Request(req, result)
+		if err != nil {
+			return err
+		}
+		if resp.MessageType != message.MsgTypeHeartbeatResponse {
+			return &StepFailure{Step: testcase.Step, Type: testcase.Type,
+				Mismatches: []string{fmt.Sprintf("message type: expect %d, got %d", message.MsgTypeHeartbeatResponse, resp.MessageType)}}
+		}
+	}
+	return nil
+}
+
+func init() {
+	RegisterStep("test_heartbeat", heartbeatStep{})
+}
+
+func TestRunSet_CustomStep(t *testing.T) {
+	testCases, err := LoadTestCases("./testdata/custom/custom.yaml", testTester.Config())
+	if err != nil {
+		t.Fatalf("LoadTestCases() error = %v", err)
+	}
+	if cfg, ok := testCases[0].Config.(*heartbeatConfig); !ok || cfg.Count != 2 {
+		t.Fatalf("expect the loaded step config kept on the test case, got %v", testCases[0].Config)
+	}
+
+	set := report.New().NewSet(0, "custom")
+	if err = testTester.RunSet(context.Background(), testCases, set); err != nil {
+		t.Fatalf("RunSet() error = %v", err)
+	}
+	if step := set.Steps[0]; step.Verdict != report.VerdictPassed || step.LatencyMs == nil {
+		t.Errorf("expect custom step passed with latency recorded, got %+v", step)
+	}
+}
+
+func TestLoadStep(t *testing.T) {
+	td := newTemplateData(nil, nil, nil)
+	if _, err := loadStep(TestStep{Type: "no_such_step"}, td); err == nil || !strings.Contains(err.Error(), "unknown step type") {
+		t.Errorf("expect unknown step type rejected, got %v", err)
+	}
+	if _, err := loadStep(TestStep{Type: "test_heartbeat", Path: "./testdata/custom/custom.yaml"}, td); err == nil {
+		t.Error("expect custom step loader error returned")
+	}
+
+	found := false
+	for _, stepType := range StepTypes() {
+		found = found || stepType == "session_establishment_request"
+	}
+	if !found {
+		t.Errorf("expect built-in steps registered, got %v", StepTypes())
+	}
+}
+
+func TestRegisterStep_Duplicate(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expect duplicate registration to panic")
+		}
+	}()
+	RegisterStep("sleep", heartbeatStep{})
+}
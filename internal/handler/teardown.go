@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+	"upftester/internal/report"
+
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+// teardownConcurrency 清理会话时的并发删除数
+const teardownConcurrency = 16
+
+// Teardown 删除会话管理器中所有遗留在 UPF 上的会话，如测试用例集中途失败或运行被取消时建立的会话。
+// 仍有会话无法删除时，按配置发送 Session Set Deletion Request 和 Association Release Request。
+// 没有遗留会话时返回 nil
+func (t *Tester) Teardown() *report.TeardownResult {
+	result := t.teardownSessions(t.leftoverSessions(nil))
+	if result == nil {
+		return nil
+	}
+
+	if !result.Clean() && t.cfg.Teardown.SessionSetDeletion {
+		result.SessionSetDeletion = teardownOutcome(t.deleteSessionSet())
+	}
+	if !result.Clean() && t.cfg.Teardown.AssociationRelease {
+		result.AssociationRelease = teardownOutcome(t.releaseAssociation())
+	}
+	if result.SessionSetDeletion == report.TeardownAccepted || result.AssociationRelease == report.TeardownAccepted {
+		t.forgetSessions(nil)
+	}
+	return result
+}
+
+// leftoverSessions 返回会话管理器中 owner 建立的尚未删除的会话，owner 为 nil 时返回全部
+func (t *Tester) leftoverSessions(owner *testRunner) []*SessionContext {
+	var sessions []*SessionContext
+	for _, s := range t.sessions.GetAllSessions() {
+		if owner != nil && s.owner != owner {
+			continue
+		}
+		switch s.State {
+		case SessionStateEstablishing, SessionStateActive, SessionStateModifying, SessionStateDeleting:
+			sessions = append(sessions, s)
+		}
+	}
+	return sessions
+}
+
+// teardownSessions 逐个发送 Session Deletion Request 删除会话并等待响应，没有会话时返回 nil。
+// 删除请求按 T1/N1 重传，不受运行的 context 影响；尚未收到建立响应的会话没有 UP F-SEID，计入 Unresolved
+func (t *Tester) teardownSessions(sessions []*SessionContext) *report.TeardownResult {
+	if len(sessions) == 0 {
+		return nil
+	}
+
+	result := &report.TeardownResult{}
+	pending := make([]*SessionContext, 0, len(sessions))
+	for _, s := range sessions {
+		if s.UPFSEID == 0 {
+			log.Printf("Teardown: session 0x%016x has no UP F-SEID, cannot delete", s.SEID)
+			result.Unresolved++
+			continue
+		}
+		pending = append(pending, s)
+	}
+	result.Requested = len(pending)
+	if len(pending) == 0 {
+		return result
+	}
+
+	log.Printf("Teardown: deleting %d leftover sessions", len(pending))
+	var mu sync.Mutex
+	stats := runPaced(len(pending), teardownConcurrency, 0, func(i int) (time.Duration, error) {
+		latency, err := t.deleteSession(pending[i])
+		if err != nil {
+			mu.Lock()
+			result.Failures = append(result.Failures, fmt.Sprintf("session 0x%016x: %v", pending[i].SEID, err))
+			mu.Unlock()
+		}
+		return latency, err
+	}).result()
+	result.Deleted, result.Failed = stats.Succeeded, stats.Failed
+	sort.Strings(result.Failures)
+
+	if result.Failed > 0 {
+		log.Printf("Teardown: deleted %d of %d sessions, first failure: %s", result.Deleted, result.Requested, stats.FirstFailure)
+	} else {
+		log.Printf("Teardown: deleted %d sessions", result.Deleted)
+	}
+	return result
+}
+
+// deleteSessionSet 发送以本端 Node ID 标识的 Session Set Deletion Request，请求 UPF 删除本端的全部会话
+func (t *Tester) deleteSessionSet() error {
+	req := message.NewSessionSetDeletionRequest(0, t.localNodeID(""), nil)
+
+	log.Printf("Teardown: sending session set deletion request to %s", t.remoteAddr)
+	txn, err := t.request(req)
+	if err != nil {
+		return err
+	}
+	reply, err := txn.Wait()
+	if err != nil {
+		return fmt.Errorf("wait session set deletion response failed: %w", err)
+	}
+	if reply.MessageType != message.MsgTypeSessionSetDeletionResponse {
+		return fmt.Errorf("expect session set deletion response, got message type %d", reply.MessageType)
+	}
+
+	resp, err := message.ParseSessionSetDeletionResponse(reply.Payload)
+	if err != nil {
+		return fmt.Errorf("session set deletion response parse failed: %w", err)
+	}
+	if err := acceptedCause(resp.Cause); err != nil {
+		return fmt.Errorf("session set deletion rejected, %w", err)
+	}
+	return nil
+}
+
+// forgetSessions UPF 接受 Session Set Deletion 或 Association Release 后，移除 owner 建立的会话上下文并归还资源，
+// owner 为 nil 时移除全部，返回移除的会话数
+func (t *Tester) forgetSessions(owner *testRunner) int {
+	removed := t.sessions.RemoveSessions(func(s *SessionContext) bool {
+		return owner == nil || s.owner == owner
+	})
+	for _, s := range removed {
+		t.dispatcher.Unregister(s.SEID)
+		if s.Resources != nil {
+			s.Resources.Release()
+		}
+	}
+	return len(removed)
+}
+
+// teardownOutcome 返回报告中记录的 Session Set Deletion 或 Association Release 结果
+func teardownOutcome(err error) string {
+	if err != nil {
+		log.Printf("Teardown: %v", err)
+		return err.Error()
+	}
+	return report.TeardownAccepted
+}
+
+// teardown 删除本测试用例集遗留在 UPF 上的会话，没有遗留会话时返回 nil。
+// 其他测试用例集可能仍在运行，这里不发送 Session Set Deletion 或 Association Release
+func (r *testRunner) teardown() *report.TeardownResult {
+	return r.t.teardownSessions(r.t.leftoverSessions(r))
+}
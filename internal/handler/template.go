@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/template"
+	"upftester/encoding/pfcp"
+	"upftester/internal/config"
+)
+
+// TemplateData 步骤文件中模板可以引用的数据，步骤文件在加载时和每次执行前按 text/template 渲染
+type TemplateData struct {
+	Config  *config.Config         // 测试器配置，如 {{ .Config.DataPlane.GnbIp }}
+	Vars    map[string]interface{} // 测试用例集文件中的 vars 及会话捕获的变量，如 {{ .Vars.ueIp }}
+	Session *SessionContext        // 当前会话，如 {{ .Session.UPFSEID }}、{{ .Session.UplinkTEID }}，加载时为空会话
+}
+
+// newTemplateData 创建模板数据，会话捕获的变量覆盖同名的 vars。
+// session 为 nil 时使用空会话
+func newTemplateData(cfg *config.Config, vars map[string]interface{}, session *SessionContext) *TemplateData {
+	if session == nil {
+		session = &SessionContext{}
+	}
+	if len(session.Vars) > 0 {
+		merged := make(map[string]interface{}, len(vars)+len(session.Vars))
+		for name, value := range vars {
+			merged[name] = value
+		}
+		for name, value := range session.Vars {
+			merged[name] = value
+		}
+		vars = merged
+	}
+	return &TemplateData{Config: cfg, Vars: vars, Session: session}
+}
+
+// templateData 返回当前会话的模板数据，association 步骤捕获的变量覆盖同名的 vars
+func (r *testRunner) templateData(testcase TestCase) *TemplateData {
+	vars := testcase.Vars
+	if len(r.vars) > 0 {
+		vars = make(map[string]interface{}, len(testcase.Vars)+len(r.vars))
+		for name, value := range testcase.Vars {
+			vars[name] = value
+		}
+		for name, value := range r.vars {
+			vars[name] = value
+		}
+	}
+	return newTemplateData(r.t.cfg, vars, r.sessionCtx)
+}
+
+// renderFile 读取步骤文件并渲染模板，不含模板动作的文件原样返回；引用不存在的变量时返回错误
+func renderFile(path string, data *TemplateData) ([]byte, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read step file failed: %w", err)
+	}
+	if !bytes.Contains(raw, []byte("{{")) {
+		return raw, nil
+	}
+
+	tmpl, err := template.New(filepath.Base(path)).Option("missingkey=error").Parse(string(raw))
+	if err != nil {
+		return nil, fmt.Errorf("parse template %s failed: %w", path, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("render template %s failed: %w", path, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// loadEstablishment 渲染并解析会话建立请求步骤文件
+func loadEstablishment(path string, td *TemplateData) (*pfcp.EstablishmentRequestConfig, error) {
+	data, err := renderFile(path, td)
+	if err != nil {
+		return nil, err
+	}
+	cfg := new(pfcp.EstablishmentRequestConfig)
+	if err := cfg.Parse(data); err != nil {
+		return nil, fmt.Errorf("unmarshal session establishment request %s failed: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// loadModification 渲染并解析会话修改请求步骤文件
+func loadModification(path string, td *TemplateData) (*pfcp.ModificationRequestConfig, error) {
+	data, err := renderFile(path, td)
+	if err != nil {
+		return nil, err
+	}
+	cfg := new(pfcp.ModificationRequestConfig)
+	if err := cfg.Parse(data); err != nil {
+		return nil, fmt.Errorf("unmarshal session modification request %s failed: %w", path, err)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"upftester/internal/config"
+)
+
+func TestRenderFile(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	td := &TemplateData{
+		Config:  &config.Config{DataPlane: config.DataPlaneConfig{GnbIp: "127.0.1.1"}},
+		Vars:    map[string]interface{}{"ueIp": "10.250.0.9"},
+		Session: &SessionContext{UPFSEID: 0x10, UplinkTEID: 7},
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		wantErr string
+	}{
+		{"plain", "dstIp: \"10.60.0.1\"\n", "dstIp: \"10.60.0.1\"\n", ""},
+		{"config", "ip: {{ .Config.DataPlane.GnbIp }}", "ip: 127.0.1.1", ""},
+		{"vars", "ip: {{ .Vars.ueIp }}", "ip: 10.250.0.9", ""},
+		{"session", "seid: {{ .Session.UPFSEID }}\nteid: {{ .Session.UplinkTEID }}", "seid: 16\nteid: 7", ""},
+		{"missing var", "ip: {{ .Vars.peerIp }}", "", "map has no entry for key"},
+		{"bad syntax", "ip: {{ .Vars.ueIp", "", "parse template"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := renderFile(write(tt.name+".yaml", tt.content), td)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("renderFile() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("renderFile() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("renderFile() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,58 +1,74 @@
 package handler
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
-	"net"
 	"os"
 	"path/filepath"
 	"sort"
 	"strconv"
-	"sync"
 	"time"
-	"upftester/encoding"
 	"upftester/encoding/pfcp"
 	"upftester/internal/config"
 	"upftester/internal/dataplane"
-	"upftester/internal/network"
+	"upftester/internal/report"
+	"upftester/internal/resource"
 
 	"github.com/wmnsk/go-pfcp/ie"
 	"github.com/wmnsk/go-pfcp/message"
 	"gopkg.in/yaml.v3"
 )
 
-var GlobalTestCases = make([][]TestCase, 0)
+// reportWaitTimeout 等待 Session Report Request 的超时时间
+const reportWaitTimeout = 10 * time.Second
+
+// dataPlaneStopGrace 数据平面测试超过配置时长后等待其自行结束的时间
+const dataPlaneStopGrace = 2 * time.Second
+
+// ErrStepTimeout 步骤未在配置的 timeout 内完成
+var ErrStepTimeout = errors.New("step timeout")
 
 type TestCase struct {
+	Source  string                 // 所属测试用例集文件
+	Vars    map[string]interface{} // 测试用例集的 vars，所有步骤共用
+	Step    int
 	Type    string
 	Action  string
 	Path    string
-	Config  encoding.MessageConfig
-	Message message.Message
+	Expect  *Expectation
+	Capture []Capture
+	Timeout time.Duration // 步骤超时，0 表示不限制
+	Config  interface{}   // StepExecutor.Load 返回的步骤配置
 }
 
 type TestStep struct {
-	Step   int    `yaml:"step"`
-	Type   string `yaml:"type"`
-	Action string `yaml:"action"`
-	Path   string `yaml:"path"`
+	Step    int          `yaml:"step"`
+	Type    string       `yaml:"type"`
+	Action  string       `yaml:"action"`
+	Path    string       `yaml:"path"`
+	Expect  *Expectation `yaml:"expect"`
+	Capture []Capture    `yaml:"capture"` // 从响应中捕获的变量
+	Timeout int          `yaml:"timeout"` // 步骤超时（毫秒），0 表示不限制
 }
 
-func LoadTestCases(path string, globalTestCases *[][]TestCase) {
+// LoadTestCases 加载测试用例集文件，按配置和 vars 渲染步骤文件中的模板，校验并编码所有步骤，不发送任何消息。
+// 引用会话及捕获变量的模板在加载时按空会话渲染，执行时按当前会话重新渲染
+func LoadTestCases(path string, cfg *config.Config) ([]TestCase, error) {
 
 	data, err := os.ReadFile(path)
 	if err != nil {
-		log.Printf("read test case file failed: %v", err)
-		return
+		return nil, fmt.Errorf("read test case file failed: %w", err)
 	}
 
 	var wrapper struct {
-		TestSteps []TestStep `yaml:"testSteps"`
+		Vars      map[string]interface{} `yaml:"vars"`
+		TestSteps []TestStep             `yaml:"testSteps"`
 	}
 	err = yaml.Unmarshal(data, &wrapper)
 	if err != nil {
-		log.Printf("unmarshal test case file failed: %v", err)
-		return
+		return nil, fmt.Errorf("unmarshal test case file %s failed: %w", path, err)
 	}
 
 	sort.Slice(wrapper.TestSteps, func(i, j int) bool {
@@ -61,7 +77,7 @@ func LoadTestCases(path string, globalTestCases *[][]TestCase) {
 
 	absPath, err := filepath.Abs(path)
 	if err != nil {
-		log.Printf("resolve absolute path failed: %v", err)
+		return nil, fmt.Errorf("resolve absolute path failed: %w", err)
 	}
 	yamlDir := filepath.Dir(absPath)
 
@@ -71,371 +87,832 @@ func LoadTestCases(path string, globalTestCases *[][]TestCase) {
 		}
 	}
 
+	// 加载时会话为空，捕获的变量以零值占位，引用尚未捕获的变量时报错
+	session := &SessionContext{}
 	testCases := make([]TestCase, 0, len(wrapper.TestSteps))
 	for _, step := range wrapper.TestSteps {
-		var msg message.Message
-		var msgConfig encoding.MessageConfig
-
-		switch step.Type {
-		case "session_establishment_request":
-			msgConfig = new(pfcp.EstablishmentRequestConfig)
-			msg, err = msgConfig.Marshal(step.Path)
-			if err != nil {
-				log.Fatal(err)
-				return
-			}
-
-		case "session_modification_request":
-			msgConfig = new(pfcp.ModificationRequestConfig)
-			msg, err = msgConfig.Marshal(step.Path)
-			if err != nil {
-				log.Fatal(err)
-				return
-			}
-
-		case "session_deletion_request":
-			msgConfig = new(pfcp.DeletionRequestConfig)
-			msg, err = msgConfig.Marshal(step.Path)
-			if err != nil {
-				log.Fatal(err)
-				return
-			}
+		stepConfig, err := loadStep(step, newTemplateData(cfg, wrapper.Vars, session))
+		if err != nil {
+			return nil, fmt.Errorf("%s step %d (%s): %w", path, step.Step, step.Type, err)
+		}
+		for _, c := range step.Capture {
+			session.SetVars(map[string]interface{}{c.Var: c.placeholder()})
 		}
 
 		testCases = append(testCases, TestCase{
+			Source:  path,
+			Vars:    wrapper.Vars,
+			Step:    step.Step,
 			Type:    step.Type,
 			Action:  step.Action,
 			Path:    step.Path,
-			Config:  msgConfig,
-			Message: msg,
+			Expect:  step.Expect,
+			Capture: step.Capture,
+			Timeout: time.Duration(step.Timeout) * time.Millisecond,
+			Config:  stepConfig,
 		})
 	}
 
-	*globalTestCases = append(*globalTestCases, testCases)
+	return testCases, nil
 }
 
-func RunTestCases(remoteAddr *net.UDPAddr, conn *network.UDPTransport) {
-	var wg sync.WaitGroup
-	for i, testCases := range GlobalTestCases {
-		wg.Add(1)
-		go func(tc []TestCase, index int) {
-			defer wg.Done()
-			log.Printf("Starting test case set %d", index)
-			err := HandleSingleTest(tc, remoteAddr, conn)
-			if err != nil {
-				log.Printf("Test case set %d failed: %v", index, err)
-			} else {
-				log.Printf("Test case set %d completed successfully", index)
-			}
-		}(testCases, i)
+// loadStep 校验步骤的通用字段，并由步骤类型的 executor 校验参数、加载步骤文件，不发送任何消息
+func loadStep(step TestStep, td *TemplateData) (interface{}, error) {
+	if step.Timeout < 0 {
+		return nil, fmt.Errorf("invalid timeout %d", step.Timeout)
 	}
-	wg.Wait()
+	if err := validateCaptures(step.Type, step.Capture); err != nil {
+		return nil, err
+	}
+
+	executor, ok := lookupStep(step.Type)
+	if !ok {
+		return nil, fmt.Errorf("unknown step type")
+	}
+	return executor.Load(step, td)
 }
 
-func HandleSingleTest(testCases []TestCase, remoteAddr *net.UDPAddr, conn *network.UDPTransport) error {
+// testRunner 单个测试用例集的执行状态
+type testRunner struct {
+	t           *Tester
+	ctx         context.Context // 当前步骤的 context，配置了步骤超时时带有截止时间
+	stepTimeout time.Duration   // 当前步骤的超时，0 表示不限制
+	ch          chan *PFCPMessage
 
-	ch := make(chan *PFCPMessage, 5)
+	upfSeid    uint64
+	smfSeid    uint64
+	sessionCtx *SessionContext
 
-	var upfSeid uint64
-	var smfSeid uint64
-	var sessionCtx *SessionContext
+	txn           *Transaction // 等待响应的请求事务
+	requestSentAt time.Time    // 最近一次请求的发送时间，用于计算响应时延
+	answered      []answeredStep
+	reportsSince  time.Time // 此前收到的 Session Report Request 已过期，不再由等待报告的步骤取走
 
-	for _, testcase := range testCases {
-		switch testcase.Type {
-		case "session_establishment_request":
+	modification *pfcp.ModificationRequestConfig // 等待响应的会话修改，响应 Request Accepted 后才更新会话上下文
 
-			msg := testcase.Config.(*pfcp.EstablishmentRequestConfig)
-			smfSeid = msg.FSEID.SEID
-			GetPFCPDispatcher().Register(smfSeid, ch)
+	bulk *bulkSessions // session_bulk 保留的会话
 
-			// 创建会话上下文
-			sessionCtx = &SessionContext{
-				SEID:  smfSeid,
-				State: SessionStateEstablishing,
-			}
-			if msg.CreatePDRs != nil && len(*msg.CreatePDRs) > 0 {
-				for _, pdr := range *msg.CreatePDRs {
-					if pdr.PDI.UEAddress != nil {
-						sessionCtx.UEIP = pdr.PDI.UEAddress.Ipv4Address
-					}
-					// Check for Uplink PDR (SourceInterface = Access)
-					if pdr.PDI.SourceInterface != nil && *pdr.PDI.SourceInterface == 0 {
-						sessionCtx.UplinkPDRID = pdr.PdrId
-						log.Printf("Identified Uplink PDR ID: %d", sessionCtx.UplinkPDRID)
-					}
-				}
-			}
-			GlobalSessionManager.AddSession(smfSeid, sessionCtx)
+	vars map[string]interface{} // association 步骤捕获的变量，与会话无关，在测试用例集的后续步骤中都可引用
+}
 
-			data := make([]byte, (testcase.Message).MarshalLen())
-			err := testcase.Message.MarshalTo(data)
-			if err != nil {
-				log.Println("marshal session establishment request failed:", err)
-				return err
-			}
+// stopBackgroundTest 停止跨步骤运行的数据平面测试 (如未执行 verify 的下行缓存测试)，释放 gNB 端口
+func (r *testRunner) stopBackgroundTest() {
+	if r.sessionCtx == nil {
+		return
+	}
+	if test, ok := r.sessionCtx.DataPlaneTestHandle.(interface{ Stop() error }); ok {
+		test.Stop()
+	}
+	r.sessionCtx.DataPlaneTestHandle = nil
+}
 
-			log.Printf("Sending session establishment request, SEID: 0x%016x", smfSeid)
-			conn.Send(data, remoteAddr)
+// releaseResources 归还当前会话从资源池分配的 SEID、UE 地址和 TEID
+func (r *testRunner) releaseResources() {
+	if r.sessionCtx != nil && r.sessionCtx.Resources != nil {
+		r.sessionCtx.Resources.Release()
+	}
+}
 
+// answeredStep 等待过响应的步骤及其请求事务
+type answeredStep struct {
+	step *report.StepResult
+	txn  *Transaction
+}
 
-		case "session_establishment_response":
-			select {
-			case <-time.After(time.Second * 5):
-				return fmt.Errorf("wait session establishment response timeout")
+// waitResponse 等待最近一次请求的响应，并记录重传次数和重复响应数
+func (r *testRunner) waitResponse(step *report.StepResult) (*PFCPMessage, error) {
+	if r.txn == nil {
+		return nil, fmt.Errorf("no pending request")
+	}
+	txn := r.txn
+	r.txn = nil
+
+	msg, err := txn.WaitContext(r.ctx)
+	step.Retries = txn.Retries()
+	step.DuplicateResponses = txn.Duplicates()
+	r.answered = append(r.answered, answeredStep{step: step, txn: txn})
+	return msg, err
+}
 
-			case msg := <-ch:
+// recordDuplicates 重复响应通常在步骤拿到第一个响应之后才到达，测试用例集结束时重新记录各步骤丢弃的重复响应数
+func (r *testRunner) recordDuplicates() {
+	for _, a := range r.answered {
+		a.step.DuplicateResponses = a.txn.Duplicates()
+	}
+}
 
-				if msg.MessageType != message.MsgTypeSessionEstablishmentResponse {
-					log.Printf("expect session establishment response, but got %v", msg.MessageType)
-					return fmt.Errorf("expect session establishment response, but got %v", msg.MessageType)
-				}
+// waitReport 等待当前会话在 reportsSince 之后上报的 Session Report Request，应答已由分发器发送，
+// 更早或其他会话的报告直接丢弃。步骤未配置超时时最多等待 reportWaitTimeout
+func (r *testRunner) waitReport() (*PFCPMessage, error) {
+	var timeout <-chan time.Time
+	if r.stepTimeout == 0 {
+		timer := time.NewTimer(reportWaitTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	for {
+		select {
+		case <-timeout:
+			return nil, fmt.Errorf("wait session report request timeout")
+		case <-r.ctx.Done():
+			return nil, r.ctx.Err()
+		case msg := <-r.ch:
+			switch {
+			case msg.MessageType != message.MsgTypeSessionReportRequest:
+				log.Printf("expect session report request, ignore message type %d", msg.MessageType)
+			case msg.SEID != r.smfSeid:
+				log.Printf("drop session report request for SEID 0x%016x, expect 0x%016x", msg.SEID, r.smfSeid)
+			case msg.ReceivedAt.Before(r.reportsSince):
+				log.Printf("drop stale session report request received at %s", msg.ReceivedAt.Format(time.RFC3339Nano))
+			default:
+				return msg, nil
+			}
+		}
+	}
+}
 
-				resp, err := message.ParseSessionEstablishmentResponse(msg.Payload)
-				if err != nil {
-					log.Println("session establishment response parse failed:", err)
-					return err
-				}
+// drainReports 会话结束时丢弃队列中未被取走的消息，避免被后续会话的步骤取走或占满队列
+func (r *testRunner) drainReports() {
+	for {
+		select {
+		case msg := <-r.ch:
+			log.Printf("drop unconsumed message type %d for SEID 0x%016x", msg.MessageType, msg.SEID)
+		default:
+			return
+		}
+	}
+}
 
-				value, err := resp.Cause.ValueAsUint8()
-				if err != nil {
-					log.Println("session establishment response cause parse failed:", err)
-					return err
-				}
+// queryURR 通过带 Query URR 的会话修改请求获取即时用量上报
+func (r *testRunner) queryURR(urrIds []uint32, step *report.StepResult) ([]*ie.IE, error) {
+	ies := make([]*ie.IE, 0, len(urrIds))
+	for _, id := range urrIds {
+		ies = append(ies, ie.NewQueryURR(ie.NewURRID(id)))
+	}
 
-				if value != ie.CauseRequestAccepted {
-					log.Println("session establishment request was rejected")
-					return fmt.Errorf("session establishment request was rejected")
-				}
+	log.Printf("Sending query URR request, UPF SEID: 0x%016x, URR IDs: %v", r.upfSeid, urrIds)
+	resp, err := r.modify(ies, "query urr", step)
+	if err != nil {
+		return nil, err
+	}
+	return resp.UsageReport, nil
+}
 
-				fseid, err := resp.UPFSEID.FSEID()
-				if err != nil {
-					log.Println("session establishment response fseid parse failed:", err)
-					return err
-				}
+// modify 发送由测试步骤生成的会话修改请求并等待响应，响应被拒绝时返回错误
+func (r *testRunner) modify(ies []*ie.IE, name string, step *report.StepResult) (*message.SessionModificationResponse, error) {
+	req := message.NewSessionModificationRequest(0, 0, r.upfSeid, 0, 0, ies...)
 
-				upfSeid = fseid.SEID
-				log.Printf("Session established successfully, SMF SEID: 0x%016x, UPF SEID: 0x%016x", smfSeid, upfSeid)
-
-				// Update session context
-				if sessionCtx != nil {
-					sessionCtx.UPFSEID = upfSeid
-					sessionCtx.State = SessionStateActive
-
-					// Parse Created PDRs to get allocated F-TEID
-					// We match the Created PDR with our identified Uplink PDR ID
-					for _, item := range resp.CreatedPDR {
-						pdrId, err := item.PDRID()
-						if err != nil {
-							continue
-						}
-						
-						if pdrId == sessionCtx.UplinkPDRID {
-							fteid, err := item.FTEID()
-							if err == nil {
-								sessionCtx.UplinkTEID = fteid.TEID
-								log.Printf("Updated Uplink TEID: %d (from PDR ID: %d)", sessionCtx.UplinkTEID, pdrId)
-								break
-							}
-						}
-					}
-					
-					GlobalSessionManager.UpdateSession(smfSeid, sessionCtx)
-				}
-			}
+	r.requestSentAt = time.Now()
+	txn, err := r.t.request(req)
+	if err != nil {
+		return nil, fmt.Errorf("send %s request failed: %w", name, err)
+	}
+	r.txn = txn
 
-		case "session_modification_request":
-			msg := testcase.Message.(*message.SessionModificationRequest)
-			msg.Header.SEID = upfSeid
+	msg, err := r.waitResponse(step)
+	if err != nil {
+		return nil, fmt.Errorf("wait %s response failed: %w", name, err)
+	}
+	if msg.MessageType != message.MsgTypeSessionModificationResponse {
+		return nil, fmt.Errorf("expect session modification response, got message type %d", msg.MessageType)
+	}
 
-			if sessionCtx != nil {
-				sessionCtx.State = SessionStateModifying
-				GlobalSessionManager.UpdateSession(smfSeid, sessionCtx)
-			}
+	resp, err := message.ParseSessionModificationResponse(msg.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("%s response parse failed: %w", name, err)
+	}
 
-			data := make([]byte, (testcase.Message).MarshalLen())
-			err := testcase.Message.MarshalTo(data)
-			if err != nil {
-				log.Println("marshal session modification request failed:", err)
-				return err
-			}
+	step.SetLatency(time.Since(r.requestSentAt))
+	if resp.Cause == nil {
+		return nil, fmt.Errorf("%s response without cause", name)
+	}
+	cause, err := resp.Cause.Cause()
+	if err != nil {
+		return nil, fmt.Errorf("%s response cause parse failed: %w", name, err)
+	}
+	step.SetCause(cause)
+	if cause != ie.CauseRequestAccepted {
+		return nil, fmt.Errorf("%s rejected, cause: %d", name, cause)
+	}
 
-			log.Printf("Sending session modification request, UPF SEID: 0x%016x", upfSeid)
-			conn.Send(data, remoteAddr)
+	return resp, nil
+}
 
-		case "session_modification_response":
-			select {
-			case <-time.After(time.Second * 5):
-				return fmt.Errorf("wait session modification response timeout")
+// reportedUsage 等待 UPF 通过 Session Report Request 上报的用量
+func (r *testRunner) reportedUsage() ([]*ie.IE, error) {
+	msg, err := r.waitReport()
+	if err != nil {
+		return nil, err
+	}
 
-			case msg := <-ch:
-				if msg.MessageType != message.MsgTypeSessionModificationResponse {
-					log.Printf("expect session modification response, but got %v", msg.MessageType)
-					return fmt.Errorf("expect session modification response, but got %v", msg.MessageType)
-				}
+	req, err := message.ParseSessionReportRequest(msg.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("session report request parse failed: %w", err)
+	}
+	return req.UsageReport, nil
+}
 
-				resp, err := message.ParseSessionModificationResponse(msg.Payload)
-				if err != nil {
-					log.Println("session modification response parse failed:", err)
-					return err
-				}
+// runStep 由步骤类型的 executor 执行单个测试步骤，并将 Cause、时延和数据面结果记录到 step。
+// 步骤配置了超时时在 ctx 上附加截止时间，超时返回 ErrStepTimeout
+func (r *testRunner) runStep(ctx context.Context, testcase TestCase, step *report.StepResult) error {
+	executor, ok := lookupStep(testcase.Type)
+	if !ok {
+		return fmt.Errorf("unknown step type %q", testcase.Type)
+	}
 
-				value, err := resp.Cause.ValueAsUint8()
-				if err != nil {
-					log.Println("session modification response cause parse failed:", err)
-					return err
-				}
+	stepCtx := ctx
+	if testcase.Timeout > 0 {
+		var cancel context.CancelFunc
+		stepCtx, cancel = context.WithTimeout(ctx, testcase.Timeout)
+		defer cancel()
+	}
+	r.ctx, r.stepTimeout = stepCtx, testcase.Timeout
+	defer func() {
+		r.ctx, r.stepTimeout = ctx, 0
+	}()
+
+	err := executor.Run(&StepContext{r: r}, testcase, step)
+	if err != nil && ctx.Err() == nil && errors.Is(stepCtx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w after %s: %v", ErrStepTimeout, testcase.Timeout, err)
+	}
+	return err
+}
 
-				if value != ie.CauseRequestAccepted {
-					log.Printf("session modification request was rejected, cause: %d", value)
-					return fmt.Errorf("session modification request was rejected")
-				}
+// runEstablishmentRequest 按会话资源编码并发送会话建立请求，创建会话上下文
+func (r *testRunner) runEstablishmentRequest(testcase TestCase, step *report.StepResult) error {
+	// 每次发送时重新编码，配置为 auto 的 SEID、UE 地址和 TEID 从资源池分配
+	msg, err := loadEstablishment(testcase.Path, r.templateData(testcase))
+	if err != nil {
+		return err
+	}
+	res := r.t.resources.NewSession()
+	req, err := msg.Encode(res)
+	if err != nil {
+		res.Release()
+		return fmt.Errorf("allocate session resources failed: %w", err)
+	}
+	if msg.FSEID == nil {
+		res.Release()
+		return fmt.Errorf("session establishment request without fseid")
+	}
 
-				log.Printf("Session modified successfully")
+	r.smfSeid = msg.FSEID.SEID
+	r.t.dispatcher.Register(r.smfSeid, r.ch)
 
-				if sessionCtx != nil {
-					sessionCtx.State = SessionStateActive
-					GlobalSessionManager.UpdateSession(smfSeid, sessionCtx)
-				}
+	// 创建会话上下文
+	r.sessionCtx = &SessionContext{
+		SEID:      r.smfSeid,
+		State:     SessionStateEstablishing,
+		Resources: res,
+		owner:     r,
+	}
+	if msg.CreatePDRs != nil && len(*msg.CreatePDRs) > 0 {
+		for _, pdr := range *msg.CreatePDRs {
+			if pdr.PDI.UEAddress != nil {
+				r.sessionCtx.UEIP = pdr.PDI.UEAddress.Ipv4Address
+			}
+			// Check for Uplink PDR (SourceInterface = Access)
+			if pdr.PDI.SourceInterface != nil && *pdr.PDI.SourceInterface == 0 {
+				r.sessionCtx.UplinkPDRID = pdr.PdrId
+				log.Printf("Identified Uplink PDR ID: %d", r.sessionCtx.UplinkPDRID)
+			}
+		}
+	}
+	if msg.CreateFARs != nil {
+		for _, far := range *msg.CreateFARs {
+			// 下行 FAR (DestinationInterface = Access) 的 Outer Header Creation TEID
+			if fp := far.ForwardingParameters; fp != nil && fp.DestinationInterface == 0 && fp.OuterHeaderCreation != nil {
+				r.sessionCtx.DownlinkTEID = fp.OuterHeaderCreation.TEID
 			}
+		}
+	}
+	if msg.CreateQERs != nil {
+		r.sessionCtx.QERs = make(map[uint32]pfcp.QER)
+		for _, qer := range *msg.CreateQERs {
+			if qer.QerId != nil {
+				r.sessionCtx.QERs[*qer.QerId] = qer
+			}
+		}
+	}
+	r.t.sessions.AddSession(r.smfSeid, r.sessionCtx)
 
-		case "session_deletion_request":
-			msg := testcase.Message.(*message.SessionDeletionRequest)
-			msg.Header.SEID = upfSeid
+	log.Printf("Sending session establishment request, SEID: 0x%016x, UE IP: %s", r.smfSeid, r.sessionCtx.UEIP)
+	r.requestSentAt = time.Now()
+	r.txn, err = r.t.request(req)
+	return err
+}
 
-			if sessionCtx != nil {
-				sessionCtx.State = SessionStateDeleting
-				GlobalSessionManager.UpdateSession(smfSeid, sessionCtx)
-			}
+// runEstablishmentResponse 等待会话建立响应并断言，记录 UPF SEID、上行 F-TEID 和捕获的变量
+func (r *testRunner) runEstablishmentResponse(testcase TestCase, step *report.StepResult) error {
+	msg, err := r.waitResponse(step)
+	if err != nil {
+		return fmt.Errorf("wait session establishment response failed: %w", err)
+	}
 
-			data := make([]byte, (testcase.Message).MarshalLen())
-			err := testcase.Message.MarshalTo(data)
-			if err != nil {
-				log.Println("marshal session deletion request failed:", err)
-				return err
-			}
+	if msg.MessageType != message.MsgTypeSessionEstablishmentResponse {
+		log.Printf("expect session establishment response, but got %v", msg.MessageType)
+		return &StepFailure{
+			Step:       testcase.Step,
+			Type:       testcase.Type,
+			Mismatches: []string{fmt.Sprintf("message type: expect %d, got %d", message.MsgTypeSessionEstablishmentResponse, msg.MessageType)},
+		}
+	}
 
-			log.Printf("Sending session deletion request, UPF SEID: 0x%016x", upfSeid)
-			conn.Send(data, remoteAddr)
+	resp, err := message.ParseSessionEstablishmentResponse(msg.Payload)
+	if err != nil {
+		log.Println("session establishment response parse failed:", err)
+		return err
+	}
 
-		case "session_deletion_response":
-			select {
-			case <-time.After(time.Second * 5):
-				return fmt.Errorf("wait session deletion response timeout")
+	step.SetLatency(time.Since(r.requestSentAt))
+	if resp.Cause != nil {
+		if cause, err := resp.Cause.Cause(); err == nil {
+			step.SetCause(cause)
+		}
+	}
 
-			case msg := <-ch:
-				if msg.MessageType != message.MsgTypeSessionDeletionResponse {
-					log.Printf("expect session deletion response, but got %v", msg.MessageType)
-					return fmt.Errorf("expect session deletion response, but got %v", msg.MessageType)
-				}
+	// 被拒绝的会话不在 UPF 上，无论断言是否通过都清理会话上下文并归还资源；
+	// 被接受的会话先记录 UP F-SEID，断言失败时测试用例集结束后仍可删除
+	if acceptedCause(resp.Cause) != nil {
+		r.t.sessions.DeleteSession(r.smfSeid)
+		r.t.dispatcher.Unregister(r.smfSeid)
+		r.drainReports()
+		r.releaseResources()
+	} else if r.sessionCtx != nil && resp.UPFSEID != nil {
+		if fseid, err := resp.UPFSEID.FSEID(); err == nil {
+			r.sessionCtx.UPFSEID = fseid.SEID
+			r.sessionCtx.State = SessionStateActive
+		}
+	}
 
-				resp, err := message.ParseSessionDeletionResponse(msg.Payload)
-				if err != nil {
-					log.Println("session deletion response parse failed:", err)
-					return err
-				}
+	if mismatches := testcase.Expect.Evaluate(resp); len(mismatches) > 0 {
+		log.Printf("session establishment response assertion failed: %v", mismatches)
+		return &StepFailure{Step: testcase.Step, Type: testcase.Type, Mismatches: mismatches}
+	}
 
-				value, err := resp.Cause.ValueAsUint8()
-				if err != nil {
-					log.Println("session deletion response cause parse failed:", err)
-					return err
-				}
+	if cause := testcase.Expect.expectedCause(); cause != ie.CauseRequestAccepted {
+		log.Printf("Session establishment rejected as expected, cause: %d", cause)
+		return nil
+	}
 
-				if value != ie.CauseRequestAccepted {
-					log.Printf("session deletion request was rejected, cause: %d", value)
-					return fmt.Errorf("session deletion request was rejected")
-				}
+	if resp.UPFSEID == nil {
+		log.Println("session establishment response without UP F-SEID")
+		return &StepFailure{Step: testcase.Step, Type: testcase.Type, Mismatches: []string{"missing UP F-SEID"}}
+	}
+	fseid, err := resp.UPFSEID.FSEID()
+	if err != nil {
+		log.Println("session establishment response fseid parse failed:", err)
+		return err
+	}
 
-				log.Printf("Session deleted successfully, SEID: 0x%016x", smfSeid)
+	r.upfSeid = fseid.SEID
+	log.Printf("Session established successfully, SMF SEID: 0x%016x, UPF SEID: 0x%016x", r.smfSeid, r.upfSeid)
 
-				// 清理会话上下文
-				GlobalSessionManager.DeleteSession(smfSeid)
-				GetPFCPDispatcher().Unregister(smfSeid)
-			}
+	// Update session context
+	if r.sessionCtx != nil {
+		r.sessionCtx.UPFSEID = r.upfSeid
+		r.sessionCtx.State = SessionStateActive
 
-		case "sleep":
-			// 从 path 字段解析睡眠时长（秒）
-			duration := 5 // 默认 5 秒
-			if testcase.Path != "" {
-				var err error
-				duration, err = strconv.Atoi(testcase.Path)
-				if err != nil {
-					log.Printf("parse sleep duration failed: %v, using default 5s", err)
-					duration = 5
-				}
-			}
-			log.Printf("Sleeping for %d seconds...", duration)
-			time.Sleep(time.Duration(duration) * time.Second)
-
-		case "data_plane_test":
-			// 数据平面测试
-			if sessionCtx == nil {
-				log.Println("No active session for data plane test")
-				return fmt.Errorf("no active session for data plane test")
+		// Parse Created PDRs to get allocated F-TEID
+		// We match the Created PDR with our identified Uplink PDR ID
+		for _, item := range resp.CreatedPDR {
+			pdrId, err := item.PDRID()
+			if err != nil {
+				continue
 			}
 
-			// 加载数据平面测试配置
-			config, err := dataplane.LoadDataPlaneTestConfig(testcase.Path)
-			if err != nil {
-				log.Printf("Load data plane test config failed: %v", err)
-				return err
+			// 请求 UPF 分配 UE 地址 (CHV4) 时，数据平面测试使用 Created PDR 中的地址
+			if ue, err := item.UEIPAddress(); err == nil && ue.IPv4Address != nil && r.sessionCtx.UEIP == "" {
+				r.sessionCtx.UEIP = ue.IPv4Address.String()
+				log.Printf("Updated UE IP: %s (from PDR ID: %d)", r.sessionCtx.UEIP, pdrId)
 			}
 
-			// 获取全局配置
-			globalConfig, err := getGlobalConfig()
-			if err != nil {
-				log.Printf("Get global config failed: %v", err)
-				return err
+			if pdrId == r.sessionCtx.UplinkPDRID {
+				fteid, err := item.FTEID()
+				if err == nil {
+					r.sessionCtx.UplinkTEID = fteid.TEID
+					log.Printf("Updated Uplink TEID: %d (from PDR ID: %d)", r.sessionCtx.UplinkTEID, pdrId)
+				}
 			}
+		}
+
+		vars, failures := captureResponse(testcase.Capture, resp)
+		step.Captures = vars
+		r.sessionCtx.SetVars(vars)
+		r.t.sessions.UpdateSession(r.smfSeid, r.sessionCtx)
+		if len(failures) > 0 {
+			log.Printf("session establishment response capture failed: %v", failures)
+			return &StepFailure{Step: testcase.Step, Type: testcase.Type, Mismatches: failures}
+		}
+	}
+	return nil
+}
+
+// runModificationRequest 渲染并发送会话修改请求，同步会话上下文中的下行 TEID 和 QER
+func (r *testRunner) runModificationRequest(testcase TestCase, step *report.StepResult) error {
+	// 每次发送时重新编码，auto 的 UE 地址沿用会话已分配的地址，auto 的 TEID 分配新值
+	cfg, err := loadModification(testcase.Path, r.templateData(testcase))
+	if err != nil {
+		return err
+	}
+	var res *resource.Session
+	if r.sessionCtx != nil {
+		res = r.sessionCtx.Resources
+	}
+	if res == nil {
+		// 没有会话承载分配的资源，编码后即归还
+		res = r.t.resources.NewSession()
+		defer res.Release()
+	}
+	m, err := cfg.Encode(res)
+	if err != nil {
+		return fmt.Errorf("allocate session resources failed: %w", err)
+	}
+	msg := m.(*message.SessionModificationRequest)
+	msg.Header.SEID = r.upfSeid
+
+	r.modification = nil
+	if r.sessionCtx != nil {
+		r.sessionCtx.State = SessionStateModifying
+		r.t.sessions.UpdateSession(r.smfSeid, r.sessionCtx)
+		r.modification = cfg
+	}
 
-			// 确定目标 IP
-			dstIp := globalConfig.DataPlane.DnIp
-			if config.DstIp != "" {
-				dstIp = config.DstIp
+	log.Printf("Sending session modification request, UPF SEID: 0x%016x", r.upfSeid)
+	r.requestSentAt = time.Now()
+	r.txn, err = r.t.request(msg)
+	return err
+}
+
+// applyModification 将 UPF 已接受的会话修改 (下行 TEID、QER) 更新到会话上下文
+func (r *testRunner) applyModification(cfg *pfcp.ModificationRequestConfig) {
+	for _, far := range cfg.DownlinkFARs() {
+		r.sessionCtx.DownlinkTEID = far.ForwardingParameters.OuterHeaderCreation.TEID
+	}
+	for _, list := range []*[]pfcp.QER{cfg.CreateQERs, cfg.UpdateQERs} {
+		if list != nil {
+			for _, qer := range *list {
+				r.sessionCtx.UpdateQER(qer)
 			}
+		}
+	}
+	if cfg.RemoveQERs != nil {
+		for _, id := range *cfg.RemoveQERs {
+			delete(r.sessionCtx.QERs, id)
+		}
+	}
+	r.t.sessions.UpdateSession(r.smfSeid, r.sessionCtx)
+}
 
-			// 根据测试类型创建测试
-			switch testcase.Action {
-			case "icmp":
-				// 创建 ICMP 测试
-				icmpTest := dataplane.NewICMPTest(
-					config,
-					globalConfig.DataPlane.GnbIp,
-					globalConfig.DataPlane.N3Ip,
-					sessionCtx.UplinkTEID,
-					sessionCtx.UEIP,
-					dstIp,
-				)
-
-				err = icmpTest.Start()
-				if err != nil {
-					log.Printf("Start ICMP test failed: %v", err)
-					return err
-				}
+// runModificationResponse 等待会话修改响应并断言，UPF 接受时更新会话上下文，记录捕获的变量
+func (r *testRunner) runModificationResponse(testcase TestCase, step *report.StepResult) error {
+	modification := r.modification
+	r.modification = nil
 
-				// 等待测试完成
-				time.Sleep(time.Duration(config.Duration) * time.Second)
-				icmpTest.Stop()
+	msg, err := r.waitResponse(step)
+	if err != nil {
+		return fmt.Errorf("wait session modification response failed: %w", err)
+	}
 
-				result := icmpTest.GetResult()
-				log.Printf("ICMP Test completed: Sent=%d, Success=%v", result.PacketsSent, result.Success)
+	if msg.MessageType != message.MsgTypeSessionModificationResponse {
+		log.Printf("expect session modification response, but got %v", msg.MessageType)
+		return &StepFailure{
+			Step:       testcase.Step,
+			Type:       testcase.Type,
+			Mismatches: []string{fmt.Sprintf("message type: expect %d, got %d", message.MsgTypeSessionModificationResponse, msg.MessageType)},
+		}
+	}
 
-			default:
-				log.Printf("Unsupported data plane test action: %s", testcase.Action)
-				return fmt.Errorf("unsupported data plane test action: %s", testcase.Action)
+	resp, err := message.ParseSessionModificationResponse(msg.Payload)
+	if err != nil {
+		log.Println("session modification response parse failed:", err)
+		return err
+	}
+
+	step.SetLatency(time.Since(r.requestSentAt))
+	if resp.Cause != nil {
+		if cause, err := resp.Cause.Cause(); err == nil {
+			step.SetCause(cause)
+			if cause == ie.CauseRequestAccepted && modification != nil && r.sessionCtx != nil {
+				r.applyModification(modification)
 			}
 		}
+	}
+
+	if mismatches := testcase.Expect.Evaluate(resp); len(mismatches) > 0 {
+		log.Printf("session modification response assertion failed: %v", mismatches)
+		return &StepFailure{Step: testcase.Step, Type: testcase.Type, Mismatches: mismatches}
+	}
 
+	if cause := testcase.Expect.expectedCause(); cause != ie.CauseRequestAccepted {
+		log.Printf("Session modification rejected as expected, cause: %d", cause)
+		if r.sessionCtx != nil {
+			r.sessionCtx.State = SessionStateActive
+			r.t.sessions.UpdateSession(r.smfSeid, r.sessionCtx)
+		}
+		return nil
 	}
 
+	log.Printf("Session modified successfully")
+
+	vars, failures := captureResponse(testcase.Capture, resp)
+	step.Captures = vars
+	if r.sessionCtx != nil {
+		r.sessionCtx.State = SessionStateActive
+		r.sessionCtx.SetVars(vars)
+		r.t.sessions.UpdateSession(r.smfSeid, r.sessionCtx)
+	}
+	if len(failures) > 0 {
+		log.Printf("session modification response capture failed: %v", failures)
+		return &StepFailure{Step: testcase.Step, Type: testcase.Type, Mismatches: failures}
+	}
 	return nil
 }
 
-// getGlobalConfig 获取全局配置（临时实现，后续需要改进）
-func getGlobalConfig() (*config.Config, error) {
-	var cfg config.Config
-	err := cfg.LoadConfig("../config/config.yaml")
+// runDeletionRequest 发送会话删除请求
+func (r *testRunner) runDeletionRequest(testcase TestCase, step *report.StepResult) error {
+	// 删除请求不携带 IE，每次发送时新建，避免并发执行同一测试用例集时共用消息
+	msg := message.NewSessionDeletionRequest(0, 0, r.upfSeid, 0, 0)
+
+	if r.sessionCtx != nil {
+		r.sessionCtx.State = SessionStateDeleting
+		r.t.sessions.UpdateSession(r.smfSeid, r.sessionCtx)
+	}
+
+	log.Printf("Sending session deletion request, UPF SEID: 0x%016x", r.upfSeid)
+	r.requestSentAt = time.Now()
+	var err error
+	r.txn, err = r.t.request(msg)
+	return err
+}
+
+// runDeletionResponse 等待会话删除响应并断言，成功后清理会话上下文并归还资源
+func (r *testRunner) runDeletionResponse(testcase TestCase, step *report.StepResult) error {
+	msg, err := r.waitResponse(step)
+	if err != nil {
+		return fmt.Errorf("wait session deletion response failed: %w", err)
+	}
+
+	if msg.MessageType != message.MsgTypeSessionDeletionResponse {
+		log.Printf("expect session deletion response, but got %v", msg.MessageType)
+		return &StepFailure{
+			Step:       testcase.Step,
+			Type:       testcase.Type,
+			Mismatches: []string{fmt.Sprintf("message type: expect %d, got %d", message.MsgTypeSessionDeletionResponse, msg.MessageType)},
+		}
+	}
+
+	resp, err := message.ParseSessionDeletionResponse(msg.Payload)
 	if err != nil {
+		log.Println("session deletion response parse failed:", err)
+		return err
+	}
+
+	step.SetLatency(time.Since(r.requestSentAt))
+	if resp.Cause != nil {
+		if cause, err := resp.Cause.Cause(); err == nil {
+			step.SetCause(cause)
+		}
+	}
+
+	if mismatches := testcase.Expect.Evaluate(resp); len(mismatches) > 0 {
+		log.Printf("session deletion response assertion failed: %v", mismatches)
+		return &StepFailure{Step: testcase.Step, Type: testcase.Type, Mismatches: mismatches}
+	}
+
+	if cause := testcase.Expect.expectedCause(); cause != ie.CauseRequestAccepted {
+		log.Printf("Session deletion rejected as expected, cause: %d", cause)
+		if r.sessionCtx != nil {
+			r.sessionCtx.State = SessionStateActive
+			r.t.sessions.UpdateSession(r.smfSeid, r.sessionCtx)
+		}
+		return nil
+	}
+
+	log.Printf("Session deleted successfully, SEID: 0x%016x", r.smfSeid)
+
+	// 清理会话上下文，归还会话从资源池分配的资源
+	r.t.sessions.DeleteSession(r.smfSeid)
+	r.t.dispatcher.Unregister(r.smfSeid)
+	r.drainReports()
+	r.releaseResources()
+	return nil
+}
+
+// runSessionReport 等待 UPF 发起的 Session Report Request 并断言
+func (r *testRunner) runSessionReport(testcase TestCase, step *report.StepResult) error {
+	msg, err := r.waitReport()
+	if err != nil {
+		return err
+	}
+
+	req, err := message.ParseSessionReportRequest(msg.Payload)
+	if err != nil {
+		log.Println("session report request parse failed:", err)
+		return err
+	}
+
+	if mismatches := testcase.Expect.Evaluate(req); len(mismatches) > 0 {
+		log.Printf("session report request assertion failed: %v", mismatches)
+		return &StepFailure{Step: testcase.Step, Type: testcase.Type, Mismatches: mismatches}
+	}
+
+	log.Printf("Session report request verified, SEID: 0x%016x", r.smfSeid)
+	return nil
+}
+
+// runSleep 按 path 字段配置的秒数等待，默认 5 秒
+func (r *testRunner) runSleep(testcase TestCase, step *report.StepResult) error {
+	// 从 path 字段解析睡眠时长（秒）
+	duration := 5 // 默认 5 秒
+	if testcase.Path != "" {
+		var err error
+		duration, err = strconv.Atoi(testcase.Path)
+		if err != nil {
+			log.Printf("parse sleep duration failed: %v, using default 5s", err)
+			duration = 5
+		}
+	}
+	log.Printf("Sleeping for %d seconds...", duration)
+	select {
+	case <-time.After(time.Duration(duration) * time.Second):
+		return nil
+	case <-r.ctx.Done():
+		return r.ctx.Err()
+	}
+}
+
+// runDataPlaneStep 在当前会话上运行数据平面测试，累计流量并按需核对 MBR
+func (r *testRunner) runDataPlaneStep(testcase TestCase, step *report.StepResult) error {
+	// 数据平面测试
+	if r.sessionCtx == nil {
+		log.Println("No active session for data plane test")
+		return fmt.Errorf("no active session for data plane test")
+	}
+
+	// 加载数据平面测试配置
+	data, err := renderFile(testcase.Path, r.templateData(testcase))
+	if err != nil {
+		return err
+	}
+	config, err := dataplane.ParseDataPlaneTestConfigAs(data, testcase.Action)
+	if err != nil {
+		log.Printf("Load data plane test config failed: %v", err)
+		return err
+	}
+
+	result, err := r.runDataPlaneTest(testcase.Action, config)
+	if err != nil {
+		return err
+	}
+	log.Printf("%s Test completed: Sent=%d, Received=%d, Success=%v", result.TestType, result.PacketsSent, result.PacketsReceived, result.Success)
+	step.DataPlane = newDataPlaneResult(result)
+
+	// 下行测试由 DN 侧发起，没有上行流量
+	if result.TestType != "DOWNLINK" {
+		r.sessionCtx.Traffic.UplinkPackets += uint64(result.PacketsSent)
+		r.sessionCtx.Traffic.UplinkBytes += uint64(result.BytesSent)
+	}
+	r.sessionCtx.Traffic.DownlinkPackets += uint64(result.PacketsReceived)
+	r.sessionCtx.Traffic.DownlinkBytes += uint64(result.BytesReceived)
+
+	if !result.Success {
+		mismatch := fmt.Sprintf("%s test failed: sent %d, received %d, loss rate %.2f%%",
+			result.TestType, result.PacketsSent, result.PacketsReceived, result.PacketLossRate)
+		if result.ErrorMessage != "" {
+			mismatch += ": " + result.ErrorMessage
+		}
+		log.Printf("Data plane test failed: %s", mismatch)
+		return &StepFailure{Step: testcase.Step, Type: testcase.Type, Mismatches: []string{mismatch}}
+	}
+
+	if config.VerifyMbr {
+		// 上行在 DN 仿真器处按 MBR-UL 核对，下行在 gNB 处按 MBR-DL 核对
+		ul, dl := r.sessionCtx.MBR()
+		if ul == 0 && dl == 0 {
+			return fmt.Errorf("session has no QER with MBR to verify")
+		}
+
+		step.DataPlane.MbrUlMbps = float64(ul) / 1000
+		step.DataPlane.MbrDlMbps = float64(dl) / 1000
+		if mismatches := result.VerifyMBR(step.DataPlane.MbrUlMbps, step.DataPlane.MbrDlMbps, config.MbrTolerance); len(mismatches) > 0 {
+			log.Printf("MBR verification failed: %v", mismatches)
+			return &StepFailure{Step: testcase.Step, Type: testcase.Type, Mismatches: mismatches}
+		}
+	}
+	return nil
+}
+
+// runDataPlaneTest 在当前会话上运行一次数据平面测试，等待其结束并返回结果
+func (r *testRunner) runDataPlaneTest(action string, config *dataplane.DataPlaneTestConfig) (*dataplane.DataPlaneTestResult, error) {
+	globalConfig := r.t.cfg
+
+	// 确定目标 IP
+	dstIp := globalConfig.DataPlane.DnIp
+	if config.DstIp != "" {
+		dstIp = config.DstIp
+	}
+
+	// 切换后使用会话当前服务的 gNB
+	gnbIp := globalConfig.DataPlane.GnbIp
+	if r.sessionCtx.GnbIP != "" {
+		gnbIp = r.sessionCtx.GnbIP
+	}
+
+	// DN 仿真器在 N6 侧应答上行或发起下行，使上下行可以分别统计
+	var dn *dataplane.DNEmulator
+	if config.DnEmulator || action == "downlink" {
+		dn = dataplane.NewDNEmulator(dstIp, config.DstPort, globalConfig.DataPlane.N6Ip,
+			globalConfig.DataPlane.N6TunnelPort, r.sessionCtx.UEIP)
+		if err := dn.Start(); err != nil {
+			log.Printf("Start DN emulator failed: %v", err)
+			return nil, err
+		}
+		defer dn.Close()
+	}
+
+	// 根据测试类型创建测试
+	var test dataplane.DataPlaneTest
+	switch action {
+	case "icmp":
+		test = dataplane.NewICMPTest(config, gnbIp, globalConfig.DataPlane.N3Ip,
+			r.sessionCtx.UplinkTEID, r.sessionCtx.DownlinkTEID, r.sessionCtx.UEIP, dstIp)
+	case "udp":
+		test = dataplane.NewUDPTest(config, gnbIp, globalConfig.DataPlane.N3Ip,
+			r.sessionCtx.UplinkTEID, r.sessionCtx.DownlinkTEID, r.sessionCtx.UEIP, dstIp)
+	case "throughput":
+		test = dataplane.NewThroughputTest(config, gnbIp, globalConfig.DataPlane.N3Ip,
+			r.sessionCtx.UplinkTEID, r.sessionCtx.DownlinkTEID, r.sessionCtx.UEIP, dstIp, dn)
+	case "downlink":
+		test = dataplane.NewDownlinkTest(config, gnbIp, r.sessionCtx.DownlinkTEID, r.sessionCtx.UEIP, dstIp, dn)
+	default:
+		log.Printf("Unsupported data plane test action: %s", action)
+		return nil, fmt.Errorf("unsupported data plane test action: %s", action)
+	}
+
+	if err := test.Start(); err != nil {
+		log.Printf("Start %s test failed: %v", action, err)
 		return nil, err
 	}
-	return &cfg, nil
+
+	// 等待测试完成，测试自行结束 (包数到达且应答收齐) 时提前返回
+	if err := dataplane.Wait(r.ctx, test, time.Duration(config.Duration)*time.Second+dataPlaneStopGrace); err != nil {
+		return nil, err
+	}
+
+	result := test.GetResult()
+	if dn != nil {
+		result.SetDNStats(dn.Stats())
+	}
+	return result, nil
+}
+
+// newDataPlaneResult 将数据平面测试结果转换为报告格式
+func newDataPlaneResult(result *dataplane.DataPlaneTestResult) *report.DataPlaneResult {
+	ms := func(d time.Duration) float64 {
+		return float64(d) / float64(time.Millisecond)
+	}
+
+	var windows []report.ThroughputWindow
+	for _, w := range result.Windows {
+		windows = append(windows, report.ThroughputWindow{
+			OffsetMs:     ms(w.Offset),
+			OfferedMbps:  w.OfferedMbps,
+			UplinkMbps:   w.UplinkMbps,
+			DownlinkMbps: w.DownlinkMbps,
+		})
+	}
+
+	var directions *report.DirectionResult
+	if d := result.Directions; d != nil {
+		directions = &report.DirectionResult{
+			UplinkSent:       d.UplinkSent,
+			UplinkReceived:   d.UplinkReceived,
+			UplinkLost:       d.UplinkLost,
+			DownlinkSent:     d.DownlinkSent,
+			DownlinkReceived: d.DownlinkReceived,
+			DownlinkLost:     d.DownlinkLost,
+		}
+	}
+
+	return &report.DataPlaneResult{
+		TestType:        result.TestType,
+		PacketsSent:     result.PacketsSent,
+		PacketsReceived: result.PacketsReceived,
+		BytesSent:       result.BytesSent,
+		BytesReceived:   result.BytesReceived,
+		PacketsLost:     result.PacketsLost,
+		PacketLossRate:  result.PacketLossRate,
+		AvgLatencyMs:    ms(result.AvgLatency),
+		MinLatencyMs:    ms(result.MinLatency),
+		MaxLatencyMs:    ms(result.MaxLatency),
+		RoundTrip:       result.RoundTrip,
+		ThroughputMbps:  result.Throughput,
+		Reordered:       result.Reordered,
+		Duplicates:      result.Duplicates,
+		JitterMs:        ms(result.Jitter),
+		Windows:         windows,
+		Directions:      directions,
+		Success:         result.Success,
+	}
 }
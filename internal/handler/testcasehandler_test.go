@@ -0,0 +1,701 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+	"upftester/encoding/pfcp"
+	"upftester/internal/config"
+	"upftester/internal/mockupf"
+	"upftester/internal/report"
+
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+const (
+	testGnbIp       = "127.0.1.1"
+	testN3Ip        = "127.0.1.2"
+	testTargetGnbIp = "127.0.1.3"
+	testDnIp        = "127.0.1.4"
+)
+
+var (
+	testUPF    *mockupf.MockUPF
+	testTester *Tester
+)
+
+func TestMain(m *testing.M) {
+	var err error
+	testUPF, err = mockupf.New(mockupf.Config{N4Addr: testN3Ip + ":0", N3Ip: testN3Ip, N6Addr: testN3Ip + ":0"})
+	if err != nil {
+		log.Fatal(err)
+	}
+	testUPF.Start()
+
+	testTester, err = NewTester(newTestConfig())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	code := m.Run()
+
+	testTester.Close()
+	testUPF.Stop()
+	os.Exit(code)
+}
+
+// newTestConfig 返回连接 mock UPF 的配置，本端 N4 使用空闲端口
+func newTestConfig() *config.Config {
+	return &config.Config{
+		Basic: config.BasicConfig{
+			LocalN4Ip:   testGnbIp,
+			LocalN4Port: freeUDPPort(testGnbIp),
+			UpfN4Ip:     testN3Ip,
+			UpfN4Port:   testUPF.N4Addr().Port,
+		},
+		DataPlane: config.DataPlaneConfig{
+			GnbIp: testGnbIp,
+			N3Ip:  testN3Ip,
+			N6Ip:  testN3Ip,
+			DnIp:  "10.60.0.1",
+
+			TargetGnbIp:  testTargetGnbIp,
+			N6TunnelPort: testUPF.N6Addr().Port,
+		},
+		Resource: config.ResourceConfig{QueueSize: 100, StartUeIp: "10.250.1.1"},
+		Pfcp:     config.PfcpConfig{T1: 200},
+	}
+}
+
+// freeUDPPort 返回 ip 上一个空闲的 UDP 端口
+func freeUDPPort(ip string) int {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP(ip)})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+func TestRunSet_Lifecycle(t *testing.T) {
+	testCases, err := LoadTestCases("./testdata/lifecycle/lifecycle.yaml", testTester.Config())
+	if err != nil {
+		t.Fatalf("LoadTestCases() error = %v", err)
+	}
+
+	set := report.New().NewSet(0, "lifecycle")
+	err = testTester.RunSet(context.Background(), testCases, set)
+	if err != nil {
+		t.Fatalf("RunSet() error = %v", err)
+	}
+
+	if len(set.Steps) != 7 {
+		t.Fatalf("expect 7 step results, got %d", len(set.Steps))
+	}
+	if resp := set.Steps[1]; resp.Cause == nil || *resp.Cause != 1 || resp.LatencyMs == nil {
+		t.Errorf("expect establishment response cause and latency recorded, got %+v", resp)
+	}
+	if dp := set.Steps[2].DataPlane; dp == nil || dp.PacketsSent != 3 || dp.PacketsReceived != 3 || dp.MaxLatencyMs <= 0 {
+		t.Errorf("expect data plane result with 3 echo replies matched, got %+v", dp)
+	}
+
+	if testUPF.SessionCount() != 0 {
+		t.Errorf("expect session deleted on mock UPF, got %d", testUPF.SessionCount())
+	}
+	if stats := testUPF.GetStats(); stats.UplinkPackets != 3 {
+		t.Errorf("expect 3 uplink packets on mock UPF, got %d", stats.UplinkPackets)
+	}
+}
+
+func TestRunSet_Retransmission(t *testing.T) {
+	testCases, err := LoadTestCases("./testdata/tester/tester.yaml", testTester.Config())
+	if err != nil {
+		t.Fatalf("LoadTestCases() error = %v", err)
+	}
+
+	testUPF.DropRequests(1)
+	testUPF.SetDuplicateResponses(true)
+	defer testUPF.SetDuplicateResponses(false)
+
+	set := report.New().NewSet(0, "retransmission")
+	if err = testTester.RunSet(context.Background(), testCases, set); err != nil {
+		t.Fatalf("RunSet() error = %v", err)
+	}
+
+	// 第一个请求被丢弃后重传一次，重传的应答被发送两次
+	if step := set.Steps[1]; step.Retries != 1 || step.DuplicateResponses != 1 {
+		t.Errorf("expect 1 retransmission and 1 duplicate response recorded, got %+v", step)
+	}
+	if step := set.Steps[3]; step.Retries != 0 {
+		t.Errorf("expect no retransmission for session deletion, got %+v", step)
+	}
+}
+
+func TestRunSet_SessionReport(t *testing.T) {
+	testCases, err := LoadTestCases("./testdata/report/report.yaml", testTester.Config())
+	if err != nil {
+		t.Fatalf("LoadTestCases() error = %v", err)
+	}
+
+	set := report.New().NewSet(0, "report")
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- testTester.RunSet(context.Background(), testCases, set)
+	}()
+
+	// 会话建立后由模拟 UPF 上报用量，CP SEID 由资源池分配
+	usage := ie.NewUsageReportWithinSessionReportRequest(
+		ie.NewURRID(1),
+		ie.NewURSEQN(0),
+		ie.NewUsageReportTrigger(0x02, 0x00, 0x00),
+		ie.NewVolumeMeasurement(0x07, 2000, 1000, 1000, 0, 0, 0),
+	)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if seids := testUPF.CPSEIDs(); len(seids) == 1 {
+			if err := testUPF.SendSessionReport(seids[0], ie.NewReportType(0, 0, 1, 0), usage); err != nil {
+				t.Fatalf("SendSessionReport() error = %v", err)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("session not established, sessions: %d", testUPF.SessionCount())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := <-errChan; err != nil {
+		t.Fatalf("RunSet() error = %v", err)
+	}
+
+	causes := testUPF.ReportResponseCauses()
+	if len(causes) != 1 || causes[0] != ie.CauseRequestAccepted {
+		t.Errorf("expect one accepted session report response, got %v", causes)
+	}
+}
+
+func TestRunSet_URRVerification(t *testing.T) {
+	testCases, err := LoadTestCases("./testdata/urr/urr.yaml", testTester.Config())
+	if err != nil {
+		t.Fatalf("LoadTestCases() error = %v", err)
+	}
+
+	set := report.New().NewSet(0, "urr")
+	if err = testTester.RunSet(context.Background(), testCases, set); err != nil {
+		t.Fatalf("RunSet() error = %v", err)
+	}
+
+	checks := set.Steps[3].UsageChecks
+	if len(checks) != 6 {
+		t.Fatalf("expect 6 usage checks, got %+v", checks)
+	}
+	for _, check := range checks {
+		if !check.Passed || check.Expected == 0 {
+			t.Errorf("unexpected usage check %+v", check)
+		}
+	}
+}
+
+func TestRunSet_UDP(t *testing.T) {
+	testCases, err := LoadTestCases("./testdata/udp/udp.yaml", testTester.Config())
+	if err != nil {
+		t.Fatalf("LoadTestCases() error = %v", err)
+	}
+
+	set := report.New().NewSet(0, "udp")
+	if err = testTester.RunSet(context.Background(), testCases, set); err != nil {
+		t.Fatalf("RunSet() error = %v", err)
+	}
+
+	dp := set.Steps[2].DataPlane
+	if dp == nil || dp.TestType != "UDP" || !dp.RoundTrip {
+		t.Fatalf("expect UDP data plane result with round trip latency, got %+v", dp)
+	}
+	if dp.PacketsSent != 20 || dp.PacketsReceived != 20 || dp.PacketsLost != 0 {
+		t.Errorf("expect 20 packets sent and reflected, got %+v", dp)
+	}
+	if dp.Duplicates != 0 || dp.Reordered != 0 {
+		t.Errorf("expect no duplicates or reordering on loopback, got %+v", dp)
+	}
+}
+
+func TestRunSet_ThroughputMBR(t *testing.T) {
+	testCases, err := LoadTestCases("./testdata/throughput/throughput.yaml", testTester.Config())
+	if err != nil {
+		t.Fatalf("LoadTestCases() error = %v", err)
+	}
+
+	// 上行经 N6 隧道到达 DN 仿真器，在 DN 侧统计上行实际速率
+	peer := net.JoinHostPort(testDnIp, strconv.Itoa(testUPF.N6Addr().Port))
+	if err := testUPF.SetN6Peer(peer); err != nil {
+		t.Fatalf("SetN6Peer() error = %v", err)
+	}
+	defer testUPF.SetN6Peer("")
+
+	set := report.New().NewSet(0, "throughput")
+	if err = testTester.RunSet(context.Background(), testCases, set); err != nil {
+		t.Fatalf("RunSet() error = %v", err)
+	}
+
+	dp := set.Steps[2].DataPlane
+	if dp == nil || dp.MbrUlMbps != 6 || dp.MbrDlMbps != 3 || len(dp.Windows) == 0 {
+		t.Fatalf("expect throughput windows verified against 6Mbps MBR-UL and 3Mbps MBR-DL, got %+v", dp)
+	}
+	if d := dp.Directions; d == nil || d.UplinkReceived >= d.UplinkSent || d.DownlinkReceived >= d.DownlinkSent {
+		t.Errorf("expect mock UPF to police each direction at its MBR, got %+v", d)
+	}
+}
+
+func TestRunSet_GateVerification(t *testing.T) {
+	testCases, err := LoadTestCases("./testdata/gate/gate.yaml", testTester.Config())
+	if err != nil {
+		t.Fatalf("LoadTestCases() error = %v", err)
+	}
+
+	// 上行经 N6 隧道到达 DN 仿真器，两个方向分别观测
+	peer := net.JoinHostPort(testDnIp, strconv.Itoa(testUPF.N6Addr().Port))
+	if err := testUPF.SetN6Peer(peer); err != nil {
+		t.Fatalf("SetN6Peer() error = %v", err)
+	}
+	defer testUPF.SetN6Peer("")
+
+	set := report.New().NewSet(0, "gate")
+	if err = testTester.RunSet(context.Background(), testCases, set); err != nil {
+		t.Fatalf("RunSet() error = %v", err)
+	}
+
+	observed := func(step int) [2]string {
+		checks := set.Steps[step].GateChecks
+		if len(checks) != 2 {
+			t.Fatalf("expect 2 gate checks for step %d, got %+v", step+1, checks)
+		}
+		return [2]string{checks[0].Observed, checks[1].Observed}
+	}
+	if got := observed(2); got != [2]string{gateForwarded, gateForwarded} {
+		t.Errorf("gates open: expect both directions forwarded, got %v", got)
+	}
+	if got := observed(5); got != [2]string{gateForwarded, gateBlocked} {
+		t.Errorf("downlink closed: expect uplink forwarded and downlink blocked, got %v", got)
+	}
+	if got := observed(8); got != [2]string{gateBlocked, gateForwarded} {
+		t.Errorf("uplink closed: expect uplink blocked and downlink forwarded to the DN probe, got %v", got)
+	}
+}
+
+func TestRunSet_DataPlaneLoss(t *testing.T) {
+	testCases, err := LoadTestCases("./testdata/loss/loss.yaml", testTester.Config())
+	if err != nil {
+		t.Fatalf("LoadTestCases() error = %v", err)
+	}
+
+	set := report.New().NewSet(0, "loss")
+	err = testTester.RunSet(context.Background(), testCases, set)
+	var failure *StepFailure
+	if !errors.As(err, &failure) || failure.Step != 5 {
+		t.Fatalf("expect step 5 to fail on 100%% loss, got %v", err)
+	}
+
+	step := set.Steps[4]
+	if step.Verdict != report.VerdictFailed || len(step.Mismatches) != 1 {
+		t.Errorf("expect failed step with mismatch in report, got %+v", step)
+	}
+	if dp := step.DataPlane; dp == nil || dp.PacketsSent != 3 || dp.PacketsReceived != 0 {
+		t.Errorf("expect 3 packets sent and none received, got %+v", dp)
+	}
+}
+
+func TestRunSet_Handover(t *testing.T) {
+	testCases, err := LoadTestCases("./testdata/handover/handover.yaml", testTester.Config())
+	if err != nil {
+		t.Fatalf("LoadTestCases() error = %v", err)
+	}
+
+	set := report.New().NewSet(0, "handover")
+	if err = testTester.RunSet(context.Background(), testCases, set); err != nil {
+		t.Fatalf("RunSet() error = %v", err)
+	}
+
+	h := set.Steps[3].Handover
+	if h == nil {
+		t.Fatalf("expect handover result, got %+v", set.Steps[3])
+	}
+	if h.SourceGnbIp != testGnbIp || h.TargetGnbIp != testTargetGnbIp || h.SourceTeid == h.TargetTeid {
+		t.Errorf("expect downlink switched from %s to %s with a new TEID, got %+v", testGnbIp, testTargetGnbIp, h)
+	}
+	if h.EndMarkers != 1 || h.EndMarkerLatencyMs == nil || h.SourcePathPackets != 0 {
+		t.Errorf("expect one end marker and no traffic on source path, got %+v", h)
+	}
+	if dp := set.Steps[3].DataPlane; dp == nil || dp.PacketsReceived != 10 {
+		t.Errorf("expect 10 packets delivered to target gNB, got %+v", dp)
+	}
+	if dp := set.Steps[4].DataPlane; dp == nil || dp.PacketsReceived != 3 {
+		t.Errorf("expect later data plane test to use target gNB, got %+v", dp)
+	}
+}
+
+func TestRunSet_Buffering(t *testing.T) {
+	testCases, err := LoadTestCases("./testdata/buffering/buffering.yaml", testTester.Config())
+	if err != nil {
+		t.Fatalf("LoadTestCases() error = %v", err)
+	}
+
+	set := report.New().NewSet(0, "buffering")
+	if err = testTester.RunSet(context.Background(), testCases, set); err != nil {
+		t.Fatalf("RunSet() error = %v", err)
+	}
+
+	if dp := set.Steps[2].DataPlane; dp == nil || dp.PacketsSent != 5 || dp.PacketsReceived != 0 {
+		t.Errorf("expect 5 downlink packets buffered while idle, got %+v", dp)
+	}
+	dp := set.Steps[6].DataPlane
+	if dp == nil || dp.TestType != "BUFFERING" {
+		t.Fatalf("expect buffering result after re-activation, got %+v", dp)
+	}
+	if dp.PacketsReceived != 5 || dp.Reordered != 0 || dp.Duplicates != 0 {
+		t.Errorf("expect 5 buffered packets flushed in order, got %+v", dp)
+	}
+	if dp := set.Steps[7].DataPlane; dp == nil || dp.PacketsReceived != 3 {
+		t.Errorf("expect forwarding to resume after re-activation, got %+v", dp)
+	}
+}
+
+func TestRunSet_DNEmulator(t *testing.T) {
+	testCases, err := LoadTestCases("./testdata/dn/dn.yaml", testTester.Config())
+	if err != nil {
+		t.Fatalf("LoadTestCases() error = %v", err)
+	}
+
+	// 上行经 N6 隧道转发给 DN 仿真器，而不在模拟 UPF 内回环
+	peer := net.JoinHostPort(testDnIp, strconv.Itoa(testUPF.N6Addr().Port))
+	if err := testUPF.SetN6Peer(peer); err != nil {
+		t.Fatalf("SetN6Peer() error = %v", err)
+	}
+	defer testUPF.SetN6Peer("")
+
+	set := report.New().NewSet(0, "dn")
+	if err = testTester.RunSet(context.Background(), testCases, set); err != nil {
+		t.Fatalf("RunSet() error = %v", err)
+	}
+
+	for _, i := range []int{2, 3} {
+		dp := set.Steps[i].DataPlane
+		if dp == nil || dp.Directions == nil {
+			t.Fatalf("step %d: expect per-direction result, got %+v", i+1, dp)
+		}
+		d := dp.Directions
+		if d.UplinkSent == 0 || d.UplinkReceived != d.UplinkSent || d.DownlinkSent != d.UplinkReceived ||
+			d.DownlinkReceived != d.DownlinkSent {
+			t.Errorf("step %d: expect every packet answered by the DN emulator, got %+v", i+1, d)
+		}
+	}
+
+	dp := set.Steps[4].DataPlane
+	if dp == nil || dp.TestType != "DOWNLINK" || dp.Directions == nil || dp.RoundTrip {
+		t.Fatalf("expect downlink result, got %+v", dp)
+	}
+	if d := dp.Directions; d.UplinkSent != 0 || d.DownlinkSent != 20 || d.DownlinkReceived != 20 {
+		t.Errorf("expect 20 downlink packets originated at DN and received, got %+v", d)
+	}
+	if dp.Reordered != 0 || dp.Duplicates != 0 {
+		t.Errorf("expect downlink packets in order, got %+v", dp)
+	}
+}
+
+func TestRunSet_RuleChurn(t *testing.T) {
+	testCases, err := LoadTestCases("./testdata/modification/modification.yaml", testTester.Config())
+	if err != nil {
+		t.Fatalf("LoadTestCases() error = %v", err)
+	}
+
+	set := report.New().NewSet(0, "modification")
+	if err = testTester.RunSet(context.Background(), testCases, set); err != nil {
+		t.Fatalf("RunSet() error = %v", err)
+	}
+
+	if dp := set.Steps[4].DataPlane; dp == nil || dp.PacketsReceived != dp.PacketsSent || dp.PacketsSent == 0 {
+		t.Errorf("expect echo replies via the new downlink FAR, got %+v", dp)
+	}
+}
+
+func TestRunSet_ExpectRejection(t *testing.T) {
+	tests := []struct {
+		name          string
+		path          string
+		expectFailure bool
+	}{
+		{
+			name:          "rejection matches expect block",
+			path:          "./testdata/reject/reject.yaml",
+			expectFailure: false,
+		},
+		{
+			name:          "rejection without expect block",
+			path:          "./testdata/reject/mismatch.yaml",
+			expectFailure: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testCases, err := LoadTestCases(tt.path, testTester.Config())
+			if err != nil {
+				t.Fatalf("LoadTestCases() error = %v", err)
+			}
+
+			set := report.New().NewSet(0, tt.name)
+			err = testTester.RunSet(context.Background(), testCases, set)
+			if !tt.expectFailure {
+				if err != nil {
+					t.Fatalf("RunSet() error = %v", err)
+				}
+				return
+			}
+
+			var failure *StepFailure
+			if !errors.As(err, &failure) {
+				t.Fatalf("expect StepFailure, got %v", err)
+			}
+			if failure.Step != 2 || len(failure.Mismatches) != 1 {
+				t.Errorf("unexpected failure %v", failure)
+			}
+			if step := set.Steps[1]; step.Verdict != report.VerdictFailed || len(step.Mismatches) != 1 {
+				t.Errorf("expect failed step with mismatches in report, got %+v", step)
+			}
+		})
+	}
+}
+
+func TestRunEstablishmentResponse_MissingUPFSEID(t *testing.T) {
+	resp := message.NewSessionEstablishmentResponse(0, 0, 0, 1, 0,
+		ie.NewNodeID(testN3Ip, "", ""),
+		ie.NewCause(ie.CauseRequestAccepted),
+	)
+	payload := make([]byte, resp.MarshalLen())
+	if err := resp.MarshalTo(payload); err != nil {
+		t.Fatal(err)
+	}
+
+	txn := &Transaction{respChan: make(chan *PFCPMessage, 1), doneChan: make(chan struct{}), expired: make(chan struct{})}
+	txn.respChan <- &PFCPMessage{MessageType: message.MsgTypeSessionEstablishmentResponse, Sequence: 1, Payload: payload}
+	r := &testRunner{t: testTester, ctx: context.Background(), txn: txn}
+
+	step := report.New().NewSet(0, "missing fseid").BeginStep(2, "session_establishment_response", "recv")
+	err := r.runEstablishmentResponse(TestCase{Step: 2, Type: "session_establishment_response"}, step)
+	var failure *StepFailure
+	if !errors.As(err, &failure) || len(failure.Mismatches) != 1 || failure.Mismatches[0] != "missing UP F-SEID" {
+		t.Errorf("expect missing UP F-SEID failure, got %v", err)
+	}
+}
+
+func TestRunModificationResponse_ApplyOnAccepted(t *testing.T) {
+	cfg := new(pfcp.ModificationRequestConfig)
+	err := cfg.Parse([]byte(`
+updateFars:
+  - farId: 2
+    forwardingParameters:
+      destinationInterface: 0
+      outerHeaderCreation:
+        outerHeaderCreationDescription: 0x0100
+        ipv4Address: "127.0.1.3"
+        teid: 200
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name     string
+		cause    uint8
+		wantTEID uint32
+	}{
+		{name: "rejected", cause: ie.CauseRequestRejected, wantTEID: 100},
+		{name: "accepted", cause: ie.CauseRequestAccepted, wantTEID: 200},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := message.NewSessionModificationResponse(0, 0, 0, 1, 0, ie.NewCause(tt.cause))
+			payload := make([]byte, resp.MarshalLen())
+			if err := resp.MarshalTo(payload); err != nil {
+				t.Fatal(err)
+			}
+
+			txn := &Transaction{respChan: make(chan *PFCPMessage, 1), doneChan: make(chan struct{}), expired: make(chan struct{})}
+			txn.respChan <- &PFCPMessage{MessageType: message.MsgTypeSessionModificationResponse, Sequence: 1, Payload: payload}
+			sessionCtx := &SessionContext{DownlinkTEID: 100, State: SessionStateModifying}
+			r := &testRunner{
+				t:            &Tester{sessions: NewSessionManager()},
+				ctx:          context.Background(),
+				txn:          txn,
+				sessionCtx:   sessionCtx,
+				modification: cfg,
+			}
+
+			step := report.New().NewSet(0, tt.name).BeginStep(2, "session_modification_response", "recv")
+			r.runModificationResponse(TestCase{Step: 2, Type: "session_modification_response"}, step)
+			if sessionCtx.DownlinkTEID != tt.wantTEID {
+				t.Errorf("DownlinkTEID = %d, want %d", sessionCtx.DownlinkTEID, tt.wantTEID)
+			}
+		})
+	}
+}
+
+func TestWaitReport_DropStale(t *testing.T) {
+	since := time.Now()
+	r := &testRunner{
+		t:            testTester,
+		ctx:          context.Background(),
+		ch:           make(chan *PFCPMessage, 5),
+		smfSeid:      1,
+		reportsSince: since,
+	}
+	report := func(seid uint64, receivedAt time.Time) *PFCPMessage {
+		return &PFCPMessage{MessageType: message.MsgTypeSessionReportRequest, SEID: seid, ReceivedAt: receivedAt}
+	}
+	fresh := report(1, since.Add(time.Millisecond))
+	r.ch <- report(1, since.Add(-time.Millisecond))
+	r.ch <- report(2, since.Add(time.Millisecond))
+	r.ch <- fresh
+
+	if msg, err := r.waitReport(); err != nil || msg != fresh {
+		t.Errorf("waitReport() = %+v, %v, want the report received after reportsSince", msg, err)
+	}
+
+	r.ch <- report(1, since.Add(time.Millisecond))
+	r.drainReports()
+	if len(r.ch) != 0 {
+		t.Errorf("expect queue drained when the session ends, %d messages left", len(r.ch))
+	}
+}
+
+func TestRunSet_SessionBulk(t *testing.T) {
+	testCases, err := LoadTestCases("./testdata/bulk/bulk.yaml", testTester.Config())
+	if err != nil {
+		t.Fatalf("LoadTestCases() error = %v", err)
+	}
+
+	set := report.New().NewSet(0, "bulk")
+	err = testTester.RunSet(context.Background(), testCases, set)
+	var failure *StepFailure
+	if !errors.As(err, &failure) || failure.Step != 3 {
+		t.Fatalf("expect step 3 to fail on rejected sessions, got %v", err)
+	}
+
+	b := set.Steps[0].Bulk
+	if b == nil || b.Requested != 20 || b.Succeeded != 20 || b.Failed != 0 || b.Deleted != 0 {
+		t.Fatalf("expect 20 sessions established and kept, got %+v", b)
+	}
+	if b.LatencyP50Ms <= 0 || b.LatencyP50Ms > b.LatencyP99Ms || b.LatencyP99Ms > b.LatencyMaxMs {
+		t.Errorf("expect ordered latency percentiles, got %+v", b)
+	}
+	// 20 个会话按 200/s 建立至少需要 19 个间隔
+	if b.DurationMs < 90 {
+		t.Errorf("expect setup paced by rate, took %.1fms", b.DurationMs)
+	}
+
+	if b := set.Steps[1].Bulk; b == nil || b.Succeeded != 20 {
+		t.Errorf("expect 20 sessions deleted, got %+v", b)
+	}
+	if b := set.Steps[2].Bulk; b == nil || b.Failed != 3 || b.FirstFailure == "" {
+		t.Errorf("expect first failure cause recorded, got %+v", b)
+	}
+	if testUPF.SessionCount() != 0 {
+		t.Errorf("expect no session left on mock UPF, got %d", testUPF.SessionCount())
+	}
+	if _, _, ueIPs := testTester.resources.InUse(); ueIPs != 0 {
+		t.Errorf("expect all UE addresses released, %d in use", ueIPs)
+	}
+}
+
+func TestRunSet_LoadCPS(t *testing.T) {
+	testCases, err := LoadTestCases("./testdata/load/load.yaml", testTester.Config())
+	if err != nil {
+		t.Fatalf("LoadTestCases() error = %v", err)
+	}
+
+	set := report.New().NewSet(0, "load")
+	if err = testTester.RunSet(context.Background(), testCases, set); err != nil {
+		t.Fatalf("RunSet() error = %v", err)
+	}
+
+	l := set.Steps[0].Load
+	if l == nil {
+		t.Fatalf("expect load result, got %+v", set.Steps[0])
+	}
+	// 1 秒内按 50 cps 发起，允许定时器误差
+	if l.Attempted < 40 || l.Established != l.Attempted || l.Deleted != l.Established || l.Timeouts != 0 {
+		t.Errorf("expect every session established and deleted, got %+v", l)
+	}
+	// 每个会话保持 200ms，同时存在约 10 个会话
+	if l.PeakOutstanding < 5 || l.PeakOutstanding > 20 {
+		t.Errorf("expect about 10 outstanding sessions, got %d", l.PeakOutstanding)
+	}
+	if l.EstablishLatency.Count != l.Established || l.DeleteLatency.Count != l.Deleted {
+		t.Errorf("expect every response in the latency histograms, got %+v %+v", l.EstablishLatency, l.DeleteLatency)
+	}
+	if len(l.Intervals) < 4 || l.Intervals[len(l.Intervals)-1].Outstanding != 0 {
+		t.Errorf("expect per-interval samples ending with no outstanding session, got %+v", l.Intervals)
+	}
+	if testUPF.SessionCount() != 0 {
+		t.Errorf("expect no session left on mock UPF, got %d", testUPF.SessionCount())
+	}
+}
+
+func TestRunSet_Template(t *testing.T) {
+	testCases, err := LoadTestCases("./testdata/template/template.yaml", testTester.Config())
+	if err != nil {
+		t.Fatalf("LoadTestCases() error = %v", err)
+	}
+
+	cfg := testCases[0].Config.(*pfcp.EstablishmentRequestConfig)
+	if pdrs := *cfg.CreatePDRs; pdrs[0].PDI.UEAddress.Ipv4Address != "10.250.7.1" || cfg.ApnDnn != "internet" ||
+		cfg.NodeId.Ipv4 != testGnbIp {
+		t.Errorf("expect vars and config rendered into the establishment, got %+v", cfg)
+	}
+
+	set := report.New().NewSet(0, "template")
+	if err = testTester.RunSet(context.Background(), testCases, set); err != nil {
+		t.Fatalf("RunSet() error = %v", err)
+	}
+
+	for _, i := range []int{2, 5} {
+		if dp := set.Steps[i].DataPlane; dp == nil || dp.PacketsSent != 3 || dp.PacketsReceived != 3 {
+			t.Errorf("step %d: expect echo replies to the configured DN, got %+v", i+1, dp)
+		}
+	}
+	if testUPF.SessionCount() != 0 {
+		t.Errorf("expect session deleted on mock UPF, got %d", testUPF.SessionCount())
+	}
+}
+
+func TestRunSet_Capture(t *testing.T) {
+	testCases, err := LoadTestCases("./testdata/capture/capture.yaml", testTester.Config())
+	if err != nil {
+		t.Fatalf("LoadTestCases() error = %v", err)
+	}
+
+	set := report.New().NewSet(0, "capture")
+	if err = testTester.RunSet(context.Background(), testCases, set); err != nil {
+		t.Fatalf("RunSet() error = %v", err)
+	}
+
+	captures := set.Steps[1].Captures
+	if captures["n3Ip"] != testN3Ip || captures["n3Teid"] == nil || captures["upfSeid"] == nil {
+		t.Errorf("expect F-TEID and UP SEID captured, got %+v", captures)
+	}
+	// 模拟 UPF 从 10.251.0.1 开始分配 UE 地址
+	if captures["ueIp"] != "10.251.0.1" {
+		t.Errorf("expect UE IP allocated by the UPF captured, got %+v", captures)
+	}
+	// 数据平面测试使用 UPF 分配的 UE 地址
+	if dp := set.Steps[2].DataPlane; dp == nil || dp.PacketsSent != 3 || dp.PacketsReceived != 3 {
+		t.Errorf("expect echo replies to the UPF allocated UE IP, got %+v", dp)
+	}
+	if teid := set.Steps[4].Captures["secondTeid"]; teid == nil || teid == captures["n3Teid"] {
+		t.Errorf("expect a new F-TEID captured from the modification response, got %v", teid)
+	}
+}
@@ -0,0 +1,209 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+	"upftester/internal/config"
+	"upftester/internal/network"
+	"upftester/internal/report"
+	"upftester/internal/resource"
+	"upftester/internal/util"
+
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+// DefaultN4Port PFCP 默认端口
+const DefaultN4Port = 8805
+
+// Tester 按一份配置运行测试用例集的测试器，持有 N4 传输、PFCP 分发器、会话管理器、资源池和序列号。
+// 各 Tester 之间不共享状态，同一进程内可以同时运行多个使用不同本端地址或端口的 Tester
+type Tester struct {
+	cfg        *config.Config
+	remoteAddr *net.UDPAddr
+	transport  *network.UDPTransport
+	dispatcher *PFCPDispatcher
+	sessions   *SessionManager
+	resources  *resource.Allocator
+
+	seq          util.Uint32
+	recoveryTime time.Time // 本端启动时间，用于 Recovery Time Stamp
+
+	closeOnce sync.Once
+}
+
+// NewTester 按配置创建测试器，绑定本端 N4 地址并启动 PFCP 分发器，使用完毕后需调用 Close
+func NewTester(cfg *config.Config) (*Tester, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("config is required")
+	}
+
+	remoteAddr, err := net.ResolveUDPAddr("udp", net.JoinHostPort(cfg.Basic.UpfN4Ip, n4Port(cfg.Basic.UpfN4Port)))
+	if err != nil {
+		return nil, fmt.Errorf("resolve UPF N4 address failed: %w", err)
+	}
+	resources, err := resource.New(cfg.Resource)
+	if err != nil {
+		return nil, err
+	}
+	transport, err := network.NewUDPTransport(cfg.Basic.LocalN4Ip, n4Port(cfg.Basic.LocalN4Port), cfg.Resource.QueueSize)
+	if err != nil {
+		return nil, fmt.Errorf("listen on local N4 address failed: %w", err)
+	}
+	transport.Start()
+
+	t := &Tester{
+		cfg:          cfg,
+		remoteAddr:   remoteAddr,
+		transport:    transport,
+		sessions:     NewSessionManager(),
+		resources:    resources,
+		recoveryTime: time.Now(),
+	}
+	t.dispatcher = NewPFCPDispatcher(transport, t.sessions)
+	t.dispatcher.recoveryTime = t.recoveryTime
+	t.dispatcher.reportCause = cfg.Pfcp.ReportResponseCause
+	t.dispatcher.Start()
+	return t, nil
+}
+
+// n4Port 返回 N4 端口，0 表示 PFCP 默认端口
+func n4Port(port int) string {
+	if port == 0 {
+		port = DefaultN4Port
+	}
+	return strconv.Itoa(port)
+}
+
+// Close 停止 PFCP 分发器并关闭 N4 传输
+func (t *Tester) Close() {
+	t.closeOnce.Do(func() {
+		t.dispatcher.Stop()
+		t.transport.Stop()
+	})
+}
+
+// Config 返回测试器的配置
+func (t *Tester) Config() *config.Config {
+	return t.cfg
+}
+
+// Sessions 返回测试器的会话管理器
+func (t *Tester) Sessions() *SessionManager {
+	return t.sessions
+}
+
+// RemoteAddr 返回 UPF 的 N4 地址
+func (t *Tester) RemoteAddr() *net.UDPAddr {
+	return t.remoteAddr
+}
+
+// request 分配序列号后发送 PFCP 请求，按配置的 T1/N1 重传
+func (t *Tester) request(msg message.Message) (*Transaction, error) {
+	msg.SetSequenceNumber(t.seq.Inc())
+	data := make([]byte, msg.MarshalLen())
+	if err := msg.MarshalTo(data); err != nil {
+		return nil, fmt.Errorf("marshal %s failed: %w", msg.MessageTypeName(), err)
+	}
+	return t.dispatcher.SendRequest(data, msg.Sequence(), t.remoteAddr, t.cfg.Pfcp.T1Duration(), t.cfg.Pfcp.N1Count()), nil
+}
+
+// Run 并发执行所有测试用例集，返回测试报告。ctx 取消或到达截止时间时正在执行的步骤立即返回，
+// 其余步骤记为跳过，并返回 ctx.Err()。所有测试用例集结束后调用 Teardown 删除遗留的会话，结果记录到报告
+func (t *Tester) Run(ctx context.Context, sets [][]TestCase) (*report.Report, error) {
+	rep := report.New()
+
+	var wg sync.WaitGroup
+	for i, testCases := range sets {
+		name := fmt.Sprintf("test case set %d", i)
+		if len(testCases) > 0 && testCases[0].Source != "" {
+			name = testCases[0].Source
+		}
+		set := rep.NewSet(i, name)
+
+		wg.Add(1)
+		go func(tc []TestCase, index int) {
+			defer wg.Done()
+			log.Printf("Starting test case set %d", index)
+			err := t.RunSet(ctx, tc, set)
+			set.Finish(err)
+			if err != nil {
+				log.Printf("Test case set %d failed: %v", index, err)
+			} else {
+				log.Printf("Test case set %d completed successfully", index)
+			}
+		}(testCases, i)
+	}
+	wg.Wait()
+
+	rep.Teardown = t.Teardown()
+
+	rep.Finish()
+	return rep, ctx.Err()
+}
+
+// RunSet 顺序执行一个测试用例集的步骤，步骤结果记录到 result，返回第一个失败步骤的错误。
+// 结束时删除本测试用例集遗留在 UPF 上的会话，清理结果记录到 result.Teardown，未能全部删除时返回错误
+func (t *Tester) RunSet(ctx context.Context, testCases []TestCase, result *report.SetResult) error {
+	if result == nil {
+		result = report.New().NewSet(0, "")
+	}
+
+	r := &testRunner{
+		t:   t,
+		ctx: ctx,
+		ch:  make(chan *PFCPMessage, 5),
+	}
+	err := r.runSteps(ctx, testCases, result)
+	r.stopBackgroundTest()
+
+	result.Teardown = r.teardown()
+	r.recordDuplicates()
+	if td := result.Teardown; td != nil && !td.Clean() && err == nil {
+		err = fmt.Errorf("teardown: %d of %d leftover sessions not deleted", td.Failed+td.Unresolved, td.Requested+td.Unresolved)
+	}
+	return err
+}
+
+// runSteps 顺序执行步骤，步骤失败或 ctx 取消后其余步骤记为跳过
+func (r *testRunner) runSteps(ctx context.Context, testCases []TestCase, result *report.SetResult) error {
+	var previousStart time.Time
+	for i, testcase := range testCases {
+		err := ctx.Err()
+		if err != nil {
+			for _, skipped := range testCases[i:] {
+				result.SkipStep(skipped.Step, skipped.Type, skipped.Action)
+			}
+			return err
+		}
+
+		step := result.BeginStep(testcase.Step, testcase.Type, testcase.Action)
+		// 等待报告的步骤接受上一个步骤开始后收到的报告 (如其触发的下行数据或用量门限报告)，更早的视为过期
+		r.reportsSince = previousStart
+		if i == 0 {
+			r.reportsSince = step.StartTime
+		}
+		previousStart = step.StartTime
+		err = r.runStep(ctx, testcase, step)
+		step.Finish(err)
+
+		var failure *StepFailure
+		if errors.As(err, &failure) {
+			step.Mismatches = failure.Mismatches
+		}
+
+		if err != nil {
+			for _, skipped := range testCases[i+1:] {
+				result.SkipStep(skipped.Step, skipped.Type, skipped.Action)
+			}
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,221 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+	"upftester/internal/config"
+	"upftester/internal/mockupf"
+	"upftester/internal/report"
+
+	"github.com/wmnsk/go-pfcp/ie"
+)
+
+func TestTester_Independent(t *testing.T) {
+	// 独立的 mock UPF，N3 地址与 TestMain 中的 mock UPF 不同
+	upf, err := mockupf.New(mockupf.Config{N4Addr: "127.0.1.5:0", N3Ip: "127.0.1.5", N6Addr: "127.0.1.5:0", RequireAssociation: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	upf.Start()
+	defer upf.Stop()
+
+	// 两个测试器各自持有传输、分发器、会话和资源池，在同一进程内并发运行
+	testers := make([]*Tester, 2)
+	for i := range testers {
+		cfg := newTestConfig()
+		cfg.Basic.UpfN4Ip = upf.N4Addr().IP.String()
+		cfg.Basic.UpfN4Port = upf.N4Addr().Port
+		tester, err := NewTester(cfg)
+		if err != nil {
+			t.Fatalf("NewTester() error = %v", err)
+		}
+		defer tester.Close()
+		if err := tester.Associate(context.Background()); err != nil {
+			t.Fatalf("Associate() error = %v", err)
+		}
+		testers[i] = tester
+	}
+
+	testCases, err := LoadTestCases("./testdata/tester/tester.yaml", testers[0].Config())
+	if err != nil {
+		t.Fatalf("LoadTestCases() error = %v", err)
+	}
+
+	reports := make([]*report.Report, len(testers))
+	errs := make([]error, len(testers))
+	var wg sync.WaitGroup
+	for i, tester := range testers {
+		wg.Add(1)
+		go func(i int, tester *Tester) {
+			defer wg.Done()
+			reports[i], errs[i] = tester.Run(context.Background(), [][]TestCase{testCases, testCases})
+		}(i, tester)
+	}
+	wg.Wait()
+
+	for i, rep := range reports {
+		if errs[i] != nil {
+			t.Errorf("tester %d: Run() error = %v", i, errs[i])
+		}
+		if failed := rep.Failed(); failed != 0 {
+			t.Errorf("tester %d: expect all sets passed, %d failed: %+v", i, failed, rep.Sets)
+		}
+		if n := testers[i].Sessions().Count(); n != 0 {
+			t.Errorf("tester %d: expect no session left, got %d", i, n)
+		}
+	}
+	if upf.SessionCount() != 0 {
+		t.Errorf("expect no session left on mock UPF, got %d", upf.SessionCount())
+	}
+}
+
+func TestTester_RunSetCanceled(t *testing.T) {
+	testCases, err := LoadTestCases("./testdata/tester/tester.yaml", testTester.Config())
+	if err != nil {
+		t.Fatalf("LoadTestCases() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	set := report.New().NewSet(0, "canceled")
+	if err := testTester.RunSet(ctx, testCases, set); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expect context.Canceled, got %v", err)
+	}
+	for _, step := range set.Steps {
+		if step.Verdict != report.VerdictSkipped {
+			t.Errorf("expect step %d skipped, got %s", step.Step, step.Verdict)
+		}
+	}
+}
+
+func TestRunSet_StepTimeout(t *testing.T) {
+	testCases, err := LoadTestCases("./testdata/cancel/timeout.yaml", testTester.Config())
+	if err != nil {
+		t.Fatalf("LoadTestCases() error = %v", err)
+	}
+
+	set := report.New().NewSet(0, "timeout")
+	start := time.Now()
+	err = testTester.RunSet(context.Background(), testCases, set)
+	if !errors.Is(err, ErrStepTimeout) {
+		t.Fatalf("expect ErrStepTimeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("expect step to give up after its timeout, took %s", elapsed)
+	}
+	if step := set.Steps[2]; step.Verdict != report.VerdictFailed {
+		t.Errorf("expect report step failed, got %+v", step)
+	}
+	if step := set.Steps[3]; step.Verdict != report.VerdictSkipped {
+		t.Errorf("expect deletion skipped, got %+v", step)
+	}
+
+	// 步骤失败遗留的会话在测试用例集结束时删除
+	if td := set.Teardown; td == nil || td.Requested != 1 || td.Deleted != 1 || !td.Clean() {
+		t.Errorf("expect 1 leftover session deleted by teardown, got %+v", td)
+	}
+	if n := testTester.Sessions().Count(); n != 0 || testUPF.SessionCount() != 0 {
+		t.Errorf("expect no session left after teardown, tester %d, mock UPF %d", n, testUPF.SessionCount())
+	}
+}
+
+func TestTester_RunCanceled(t *testing.T) {
+	testCases, err := LoadTestCases("./testdata/cancel/sleep.yaml", testTester.Config())
+	if err != nil {
+		t.Fatalf("LoadTestCases() error = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	rep, err := testTester.Run(ctx, [][]TestCase{testCases})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expect context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expect sleep step canceled, run took %s", elapsed)
+	}
+
+	steps := rep.Sets[0].Steps
+	if steps[1].Verdict != report.VerdictPassed || steps[2].Verdict != report.VerdictFailed || steps[3].Verdict != report.VerdictSkipped {
+		t.Errorf("expect session established, sleep canceled and deletion skipped, got %+v", steps)
+	}
+	if td := rep.Sets[0].Teardown; td == nil || td.Deleted != 1 {
+		t.Errorf("expect session deleted by set teardown, got %+v", td)
+	}
+	if rep.Teardown != nil {
+		t.Errorf("expect nothing left for run teardown, got %+v", rep.Teardown)
+	}
+	if n := testTester.Sessions().Count(); n != 0 || testUPF.SessionCount() != 0 {
+		t.Errorf("expect session deleted by teardown, tester %d, mock UPF %d", n, testUPF.SessionCount())
+	}
+}
+
+func TestTester_TeardownLastResort(t *testing.T) {
+	tests := []struct {
+		name     string
+		teardown config.TeardownConfig
+		outcome  func(*report.TeardownResult) string
+	}{
+		{
+			name:     "session set deletion",
+			teardown: config.TeardownConfig{SessionSetDeletion: true},
+			outcome:  func(td *report.TeardownResult) string { return td.SessionSetDeletion },
+		},
+		{
+			name:     "association release",
+			teardown: config.TeardownConfig{AssociationRelease: true},
+			outcome:  func(td *report.TeardownResult) string { return td.AssociationRelease },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upf, err := mockupf.New(mockupf.Config{N4Addr: "127.0.1.6:0", N3Ip: "127.0.1.6", N6Addr: "127.0.1.6:0", RequireAssociation: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			upf.Start()
+			defer upf.Stop()
+
+			cfg := newTestConfig()
+			cfg.Basic.UpfN4Ip = upf.N4Addr().IP.String()
+			cfg.Basic.UpfN4Port = upf.N4Addr().Port
+			cfg.Teardown = tt.teardown
+			tester, err := NewTester(cfg)
+			if err != nil {
+				t.Fatalf("NewTester() error = %v", err)
+			}
+			defer tester.Close()
+			if err := tester.Associate(context.Background()); err != nil {
+				t.Fatalf("Associate() error = %v", err)
+			}
+
+			testCases, err := LoadTestCases("./testdata/cancel/timeout.yaml", cfg)
+			if err != nil {
+				t.Fatalf("LoadTestCases() error = %v", err)
+			}
+
+			// UPF 拒绝逐个删除，遗留的会话只能由最后手段清理
+			upf.SetCauses(mockupf.Causes{Deletion: ie.CauseRequestRejected})
+			rep, err := tester.Run(context.Background(), [][]TestCase{testCases})
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+
+			if td := rep.Sets[0].Teardown; td == nil || td.Failed != 1 || td.Clean() || len(td.Failures) != 1 {
+				t.Errorf("expect set teardown failed to delete 1 session, got %+v", td)
+			}
+			td := rep.Teardown
+			if td == nil || td.Failed != 1 || tt.outcome(td) != report.TeardownAccepted || !td.Clean() {
+				t.Fatalf("expect run teardown cleaned up by %s, got %+v", tt.name, td)
+			}
+			if n := tester.Sessions().Count(); n != 0 || upf.SessionCount() != 0 {
+				t.Errorf("expect no session left, tester %d, mock UPF %d", n, upf.SessionCount())
+			}
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+	"upftester/internal/config"
+
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+// ErrNoResponse 请求经 N1 次重传仍未收到响应
+var ErrNoResponse = errors.New("no response to PFCP request")
+
+// Transaction PFCP 请求事务，按 T1 定时重传最多 N1 次，直到收到相同序列号的响应
+type Transaction struct {
+	seq     uint32
+	msgType uint8 // 请求的消息类型
+	data    []byte
+	addr    *net.UDPAddr
+	t1      time.Duration
+	n1      int
+
+	respChan chan *PFCPMessage
+	doneChan chan struct{}
+	expired  chan struct{}
+
+	mu         sync.Mutex
+	done       bool
+	retries    int
+	duplicates int
+}
+
+// SendRequest 发送 PFCP 请求并启动重传定时器，响应按序列号匹配
+func (d *PFCPDispatcher) SendRequest(data []byte, seq uint32, addr *net.UDPAddr, t1 time.Duration, n1 int) *Transaction {
+	if t1 <= 0 {
+		t1 = config.DefaultT1
+	}
+	if n1 < 0 {
+		n1 = 0
+	}
+
+	txn := &Transaction{
+		seq:      seq & 0xffffff, // PFCP 序列号只有 24 位
+		msgType:  data[1],
+		data:     data,
+		addr:     addr,
+		t1:       t1,
+		n1:       n1,
+		respChan: make(chan *PFCPMessage, 1),
+		doneChan: make(chan struct{}),
+		expired:  make(chan struct{}),
+	}
+	d.transactions.Store(txn.seq, txn)
+
+	d.transport.Send(data, addr)
+	go txn.retransmit(d)
+
+	return txn
+}
+
+// retransmit 每个 T1 未收到响应时重传，N1 次后放弃
+func (t *Transaction) retransmit(d *PFCPDispatcher) {
+	timer := time.NewTimer(t.t1)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-t.doneChan:
+			return
+		case <-d.stopChan:
+			// 分发器已停止，不会再收到响应
+			t.mu.Lock()
+			if !t.done {
+				t.done = true
+				close(t.expired)
+			}
+			t.mu.Unlock()
+			return
+		case <-timer.C:
+			t.mu.Lock()
+			if t.done {
+				t.mu.Unlock()
+				return
+			}
+			if t.retries >= t.n1 {
+				t.done = true
+				t.mu.Unlock()
+				close(t.expired)
+				// 保留一段时间，丢弃放弃后迟到的响应，避免被当作 UPF 发起的消息分发
+				time.AfterFunc(t.linger(), func() {
+					d.transactions.Delete(t.seq)
+				})
+				return
+			}
+			t.retries++
+			retries := t.retries
+			t.mu.Unlock()
+
+			log.Printf("PFCP request seq=%d not answered in %s, retransmission %d/%d", t.seq, t.t1, retries, t.n1)
+			d.transport.Send(t.data, t.addr)
+			timer.Reset(t.t1)
+		}
+	}
+}
+
+// linger 事务结束后保留序列号的时间，用于识别重复或迟到的响应
+func (t *Transaction) linger() time.Duration {
+	return t.t1 * time.Duration(t.n1+1)
+}
+
+// answers 响应的消息类型是否与请求对应，Version Not Supported Response 可以应答任意请求
+func (t *Transaction) answers(msgType uint8) bool {
+	return msgType == t.msgType+1 || msgType == message.MsgTypeVersionNotSupportedResponse
+}
+
+// complete 投递响应，重复或迟到的响应返回 false
+func (t *Transaction) complete(msg *PFCPMessage) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.done {
+		t.duplicates++
+		return false
+	}
+	t.done = true
+	close(t.doneChan)
+	t.respChan <- msg
+	return true
+}
+
+// Wait 等待响应，所有重传均未得到响应时返回错误
+func (t *Transaction) Wait() (*PFCPMessage, error) {
+	return t.WaitContext(context.Background())
+}
+
+// WaitContext 等待响应，ctx 取消时返回 ctx.Err()，事务继续按 T1/N1 重传直到收到响应或放弃
+func (t *Transaction) WaitContext(ctx context.Context) (*PFCPMessage, error) {
+	select {
+	case msg := <-t.respChan:
+		return msg, nil
+	case <-t.expired:
+		return nil, fmt.Errorf("%w seq=%d after %d retransmissions", ErrNoResponse, t.seq, t.n1)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Retries 返回重传次数
+func (t *Transaction) Retries() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.retries
+}
+
+// Duplicates 返回丢弃的重复响应数
+func (t *Transaction) Duplicates() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.duplicates
+}
+
+// matchTransaction 将响应按序列号和消息类型匹配到事务，已匹配 (包括丢弃重复、迟到或类型不符的响应) 时返回 true
+func (d *PFCPDispatcher) matchTransaction(msg *PFCPMessage) bool {
+	if !isResponse(msg.MessageType) {
+		return false
+	}
+
+	value, ok := d.transactions.Load(msg.Sequence)
+	if !ok {
+		return false
+	}
+	txn := value.(*Transaction)
+
+	if !txn.answers(msg.MessageType) {
+		log.Printf("Discard PFCP response with unexpected type=%d for request type=%d, seq=%d", msg.MessageType, txn.msgType, msg.Sequence)
+		return true
+	}
+
+	if !txn.complete(msg) {
+		select {
+		case <-txn.expired:
+			log.Printf("Discard late PFCP response, type=%d, seq=%d", msg.MessageType, msg.Sequence)
+		default:
+			log.Printf("Discard duplicate PFCP response, type=%d, seq=%d", msg.MessageType, msg.Sequence)
+		}
+		return true
+	}
+
+	// 保留一段时间用于识别重传请求引起的重复响应
+	time.AfterFunc(txn.linger(), func() {
+		d.transactions.Delete(txn.seq)
+	})
+	return true
+}
+
+func isResponse(msgType uint8) bool {
+	switch msgType {
+	case message.MsgTypeHeartbeatResponse,
+		message.MsgTypePFDManagementResponse,
+		message.MsgTypeAssociationSetupResponse,
+		message.MsgTypeAssociationUpdateResponse,
+		message.MsgTypeAssociationReleaseResponse,
+		message.MsgTypeVersionNotSupportedResponse,
+		message.MsgTypeNodeReportResponse,
+		message.MsgTypeSessionSetDeletionResponse,
+		message.MsgTypeSessionEstablishmentResponse,
+		message.MsgTypeSessionModificationResponse,
+		message.MsgTypeSessionDeletionResponse,
+		message.MsgTypeSessionReportResponse:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+	"upftester/internal/network"
+
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+func newTestDispatcher(t *testing.T) *PFCPDispatcher {
+	transport, err := network.NewUDPTransport("127.0.0.1", "0", 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(transport.Stop)
+	return NewPFCPDispatcher(transport, nil)
+}
+
+func sendTestRequest(d *PFCPDispatcher, seq uint32, t1 time.Duration, n1 int) *Transaction {
+	req := message.NewSessionEstablishmentRequest(0, 0, 0, seq, 0)
+	data := make([]byte, req.MarshalLen())
+	req.MarshalTo(data)
+	return d.SendRequest(data, seq, &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 8805}, t1, n1)
+}
+
+func TestMatchTransaction_MessageType(t *testing.T) {
+	d := newTestDispatcher(t)
+	defer close(d.stopChan)
+	txn := sendTestRequest(d, 1, time.Hour, 0)
+
+	wrong := &PFCPMessage{MessageType: message.MsgTypeSessionDeletionResponse, Sequence: 1}
+	if !d.matchTransaction(wrong) {
+		t.Fatal("expect response of another type consumed")
+	}
+	select {
+	case <-txn.doneChan:
+		t.Fatal("expect transaction still pending after response of another type")
+	default:
+	}
+
+	resp := &PFCPMessage{MessageType: message.MsgTypeSessionEstablishmentResponse, Sequence: 1}
+	if !d.matchTransaction(resp) {
+		t.Fatal("expect matching response consumed")
+	}
+	if msg, err := txn.Wait(); err != nil || msg != resp {
+		t.Errorf("Wait() = %v, %v, want the matching response", msg, err)
+	}
+}
+
+func TestMatchTransaction_LateResponse(t *testing.T) {
+	d := newTestDispatcher(t)
+	defer close(d.stopChan)
+	txn := sendTestRequest(d, 2, 20*time.Millisecond, 1)
+
+	if _, err := txn.Wait(); !errors.Is(err, ErrNoResponse) {
+		t.Fatalf("Wait() error = %v, want ErrNoResponse", err)
+	}
+
+	late := &PFCPMessage{MessageType: message.MsgTypeSessionEstablishmentResponse, Sequence: 2}
+	if !d.matchTransaction(late) {
+		t.Error("expect late response dropped by the expired transaction")
+	}
+
+	time.Sleep(txn.linger() + 20*time.Millisecond)
+	if d.matchTransaction(late) {
+		t.Error("expect sequence number forgotten after the grace period")
+	}
+}
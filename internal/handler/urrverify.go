@@ -0,0 +1,241 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"upftester/internal/report"
+
+	"github.com/wmnsk/go-pfcp/ie"
+	"gopkg.in/yaml.v3"
+)
+
+// URRVerifyConfig URR 流量核对配置，由 urr_verification 步骤的 path 指定
+type URRVerifyConfig struct {
+	UrrIds           []uint32 `yaml:"urrIds"`           // 需要核对的 URR ID
+	Metrics          []string `yaml:"metrics"`          // 核对项，默认核对 UPF 上报的所有项
+	Tolerance        float64  `yaml:"tolerance"`        // 允许的相对偏差 (百分比)
+	ToleranceBytes   uint64   `yaml:"toleranceBytes"`   // 允许的流量绝对偏差 (字节)
+	TolerancePackets uint64   `yaml:"tolerancePackets"` // 允许的包数绝对偏差
+}
+
+// usageMetrics 可核对的流量项
+var usageMetrics = []string{
+	"uplinkVolume",
+	"downlinkVolume",
+	"totalVolume",
+	"uplinkPackets",
+	"downlinkPackets",
+	"totalPackets",
+}
+
+// LoadURRVerifyConfig 从文件加载 URR 流量核对配置
+func LoadURRVerifyConfig(path string, td *TemplateData) (*URRVerifyConfig, error) {
+	data, err := renderFile(path, td)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg URRVerifyConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("unmarshal urr verification config failed: %w", err)
+	}
+
+	if len(cfg.UrrIds) == 0 {
+		return nil, fmt.Errorf("urrIds is required")
+	}
+	if cfg.Tolerance < 0 {
+		return nil, fmt.Errorf("tolerance must not be negative")
+	}
+	for _, metric := range cfg.Metrics {
+		if !isUsageMetric(metric) {
+			return nil, fmt.Errorf("unknown metric %q", metric)
+		}
+	}
+
+	return &cfg, nil
+}
+
+// loadURRVerificationStep 校验 URR 核对动作并加载核对配置
+func loadURRVerificationStep(step TestStep, td *TemplateData) (interface{}, error) {
+	if step.Action != "query" && step.Action != "report" {
+		return nil, fmt.Errorf("unsupported urr verification action: %s", step.Action)
+	}
+	return LoadURRVerifyConfig(step.Path, td)
+}
+
+func isUsageMetric(name string) bool {
+	for _, metric := range usageMetrics {
+		if metric == name {
+			return true
+		}
+	}
+	return false
+}
+
+// usageMetricValues 返回各核对项的 UPF 上报值和测试端统计值，未上报的项为 nil
+func usageMetricValues(usage UsageReport, traffic TrafficCounters) map[string][2]*uint64 {
+	total := func(a, b uint64) *uint64 {
+		sum := a + b
+		return &sum
+	}
+
+	return map[string][2]*uint64{
+		"uplinkVolume":    {usage.UplinkVolume, &traffic.UplinkBytes},
+		"downlinkVolume":  {usage.DownlinkVolume, &traffic.DownlinkBytes},
+		"totalVolume":     {usage.TotalVolume, total(traffic.UplinkBytes, traffic.DownlinkBytes)},
+		"uplinkPackets":   {usage.UplinkPackets, &traffic.UplinkPackets},
+		"downlinkPackets": {usage.DownlinkPackets, &traffic.DownlinkPackets},
+		"totalPackets":    {usage.TotalPackets, total(traffic.UplinkPackets, traffic.DownlinkPackets)},
+	}
+}
+
+// mergeUsageReports 按 URR ID 累加多个用量上报
+func mergeUsageReports(reports []UsageReport, urrId uint32) (UsageReport, bool) {
+	merged := UsageReport{URRID: urrId}
+	found := false
+
+	add := func(dst **uint64, src *uint64) {
+		if src == nil {
+			return
+		}
+		if *dst == nil {
+			*dst = new(uint64)
+		}
+		**dst += *src
+	}
+
+	for _, r := range reports {
+		if r.URRID != urrId {
+			continue
+		}
+		found = true
+		add(&merged.UplinkVolume, r.UplinkVolume)
+		add(&merged.DownlinkVolume, r.DownlinkVolume)
+		add(&merged.TotalVolume, r.TotalVolume)
+		add(&merged.UplinkPackets, r.UplinkPackets)
+		add(&merged.DownlinkPackets, r.DownlinkPackets)
+		add(&merged.TotalPackets, r.TotalPackets)
+	}
+
+	return merged, found
+}
+
+// Verify 将 UPF 上报的用量与测试端实际收发的流量比较，返回核对结果和不匹配项
+func (cfg *URRVerifyConfig) Verify(reports []UsageReport, traffic TrafficCounters) ([]report.UsageCheck, []string) {
+	metrics := cfg.Metrics
+	explicit := len(metrics) > 0
+	if !explicit {
+		metrics = usageMetrics
+	}
+
+	var checks []report.UsageCheck
+	var mismatches []string
+
+	for _, urrId := range cfg.UrrIds {
+		usage, ok := mergeUsageReports(reports, urrId)
+		if !ok {
+			mismatches = append(mismatches, fmt.Sprintf("usage report %d: expect present, got absent", urrId))
+			continue
+		}
+
+		values := usageMetricValues(usage, traffic)
+		for _, metric := range metrics {
+			reported, expected := values[metric][0], values[metric][1]
+			if reported == nil {
+				if explicit {
+					mismatches = append(mismatches, fmt.Sprintf("usage report %d: %s not reported", urrId, metric))
+				}
+				continue
+			}
+
+			check := report.UsageCheck{
+				URRID:            urrId,
+				Metric:           metric,
+				Expected:         *expected,
+				Reported:         *reported,
+				DeviationPercent: deviationPercent(*expected, *reported),
+			}
+			check.Passed = cfg.withinTolerance(metric, check)
+			checks = append(checks, check)
+
+			if !check.Passed {
+				mismatches = append(mismatches, fmt.Sprintf("usage report %d: %s expect %d, reported %d (%.2f%%)",
+					urrId, metric, check.Expected, check.Reported, check.DeviationPercent))
+			}
+		}
+	}
+
+	return checks, mismatches
+}
+
+// withinTolerance 偏差在相对或绝对容差内即视为一致
+func (cfg *URRVerifyConfig) withinTolerance(metric string, check report.UsageCheck) bool {
+	diff := check.Reported - check.Expected
+	if check.Expected > check.Reported {
+		diff = check.Expected - check.Reported
+	}
+
+	absolute := cfg.ToleranceBytes
+	if metric == "uplinkPackets" || metric == "downlinkPackets" || metric == "totalPackets" {
+		absolute = cfg.TolerancePackets
+	}
+
+	return diff <= absolute || check.DeviationPercent <= cfg.Tolerance
+}
+
+func deviationPercent(expected, reported uint64) float64 {
+	if expected == 0 {
+		if reported == 0 {
+			return 0
+		}
+		return 100
+	}
+	return math.Abs(float64(reported)-float64(expected)) / float64(expected) * 100
+}
+
+// runURRVerification 通过 Query URR 或 Session Report 获取用量，与测试端收发的流量核对
+func (r *testRunner) runURRVerification(testcase TestCase, step *report.StepResult) error {
+	if r.sessionCtx == nil {
+		return fmt.Errorf("no active session for urr verification")
+	}
+
+	cfg, err := LoadURRVerifyConfig(testcase.Path, r.templateData(testcase))
+	if err != nil {
+		return err
+	}
+
+	var usageReports []*ie.IE
+	switch testcase.Action {
+	case "query":
+		usageReports, err = r.queryURR(cfg.UrrIds, step)
+	case "report":
+		usageReports, err = r.reportedUsage()
+	default:
+		err = fmt.Errorf("unsupported urr verification action: %s", testcase.Action)
+	}
+	if err != nil {
+		return err
+	}
+
+	var reports []UsageReport
+	for _, item := range usageReports {
+		usage, err := parseUsageReport(item)
+		if err != nil {
+			return fmt.Errorf("parse usage report failed: %w", err)
+		}
+		reports = append(reports, usage)
+	}
+
+	checks, mismatches := cfg.Verify(reports, r.sessionCtx.Traffic)
+	step.UsageChecks = checks
+	// UPF 每次上报后重新计量，测试端计数同步清零
+	r.sessionCtx.Traffic = TrafficCounters{}
+
+	if len(mismatches) > 0 {
+		log.Printf("urr verification failed: %v", mismatches)
+		return &StepFailure{Step: testcase.Step, Type: testcase.Type, Mismatches: mismatches}
+	}
+	log.Printf("URR verification passed, %d checks", len(checks))
+	return nil
+}
@@ -0,0 +1,53 @@
+package handler
+
+import "testing"
+
+func TestURRVerifyConfig_Verify(t *testing.T) {
+	u64 := func(v uint64) *uint64 { return &v }
+	traffic := TrafficCounters{UplinkPackets: 10, UplinkBytes: 1000}
+	reports := []UsageReport{
+		{URRID: 1, UplinkVolume: u64(600), UplinkPackets: u64(6)},
+		{URRID: 1, UplinkVolume: u64(420), UplinkPackets: u64(4)},
+	}
+
+	tests := []struct {
+		name       string
+		cfg        URRVerifyConfig
+		mismatches int
+	}{
+		{
+			name:       "exact match fails without tolerance",
+			cfg:        URRVerifyConfig{UrrIds: []uint32{1}},
+			mismatches: 1,
+		},
+		{
+			name:       "relative tolerance",
+			cfg:        URRVerifyConfig{UrrIds: []uint32{1}, Tolerance: 5},
+			mismatches: 0,
+		},
+		{
+			name:       "absolute tolerance",
+			cfg:        URRVerifyConfig{UrrIds: []uint32{1}, ToleranceBytes: 20},
+			mismatches: 0,
+		},
+		{
+			name:       "explicit metric not reported",
+			cfg:        URRVerifyConfig{UrrIds: []uint32{1}, Metrics: []string{"downlinkVolume"}},
+			mismatches: 1,
+		},
+		{
+			name:       "missing usage report",
+			cfg:        URRVerifyConfig{UrrIds: []uint32{2}},
+			mismatches: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, mismatches := tt.cfg.Verify(reports, traffic)
+			if len(mismatches) != tt.mismatches {
+				t.Errorf("expect %d mismatches, got %v", tt.mismatches, mismatches)
+			}
+		})
+	}
+}
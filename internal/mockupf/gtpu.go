@@ -0,0 +1,234 @@
+package mockupf
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+	"net"
+
+	"github.com/wmnsk/go-pfcp/ie"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+const (
+	gtpuMsgTypeEndMarker = 0xFE
+	gtpuMsgTypeGPDU      = 0xFF
+)
+
+// serveGTPU 接收上行 GTP-U，将 ICMP Echo Request 和 UDP 报文回环为下行，或经 N6 隧道转发给 DN
+func (m *MockUPF) serveGTPU() {
+	defer m.wg.Done()
+
+	buffer := make([]byte, 65535)
+	for {
+		n, _, err := m.n3Conn.ReadFromUDP(buffer)
+		if err != nil {
+			if m.stopped() {
+				return
+			}
+			log.Printf("mock upf read N3 failed: %v", err)
+			continue
+		}
+
+		teid, inner, err := parseGPDU(buffer[:n])
+		if err != nil {
+			continue
+		}
+
+		m.reflect(teid, inner, n)
+	}
+}
+
+// reflect 根据上行 TEID 查找会话，配置了 DN 侧 N6 隧道地址时转发解封装后的报文，
+// 否则构造应答并按下行 FAR 回送
+func (m *MockUPF) reflect(teid uint32, inner []byte, size int) {
+	m.mu.Lock()
+	sess, ok := m.teids[teid]
+	if !ok {
+		m.mu.Unlock()
+		return
+	}
+	m.stats.UplinkPackets++
+	m.stats.UplinkBytes += int64(size)
+
+	// 上行 PDR 关联的 QER 门控关闭或超过上行 MBR 时丢弃
+	if p := sess.uplinkPDR(teid); p != nil && !sess.allow(p.qerIDs, true, len(inner)) {
+		m.mu.Unlock()
+		return
+	}
+	sess.usage.UplinkPackets++
+	sess.usage.UplinkBytes += int64(len(inner))
+
+	if peer := m.n6Peer; peer != nil {
+		m.mu.Unlock()
+		if _, err := m.n6Conn.WriteToUDP(inner, peer); err != nil {
+			log.Printf("mock upf write N6 failed: %v", err)
+		}
+		return
+	}
+
+	reply, srcIP, err := buildReply(inner)
+	if err != nil {
+		m.mu.Unlock()
+		return
+	}
+
+	p, f := sess.downlinkFAR(srcIP)
+	if f == nil {
+		m.mu.Unlock()
+		log.Printf("mock upf no downlink FAR for UE %s, drop", srcIP)
+		return
+	}
+	notify := m.downlink(sess, p, f, reply)
+	cpSEID := sess.cpSEID
+	m.mu.Unlock()
+
+	if notify {
+		m.reportDownlinkData(cpSEID, p.id)
+	}
+}
+
+// parseGPDU 解析 GTP-U G-PDU，返回 TEID 和内层 IP 包
+func parseGPDU(b []byte) (uint32, []byte, error) {
+	if len(b) < 8 {
+		return 0, nil, fmt.Errorf("gtp-u packet too short: %d", len(b))
+	}
+	flags := b[0]
+	if b[1] != gtpuMsgTypeGPDU {
+		return 0, nil, fmt.Errorf("not a g-pdu: %d", b[1])
+	}
+	teid := binary.BigEndian.Uint32(b[4:8])
+
+	offset := 8
+	if flags&0x07 != 0 {
+		if len(b) < 12 {
+			return 0, nil, fmt.Errorf("gtp-u optional header truncated")
+		}
+		offset = 12
+		next := b[11]
+		for flags&0x04 != 0 && next != 0 {
+			if len(b) < offset+1 {
+				return 0, nil, fmt.Errorf("gtp-u extension header truncated")
+			}
+			extLen := int(b[offset]) * 4
+			if extLen == 0 || len(b) < offset+extLen {
+				return 0, nil, fmt.Errorf("gtp-u extension header truncated")
+			}
+			next = b[offset+extLen-1]
+			offset += extLen
+		}
+	}
+
+	return teid, b[offset:], nil
+}
+
+// buildGTPUHeader 构造不带可选字段的 G-PDU 头部
+func buildGTPUHeader(teid uint32, payloadLen int) []byte {
+	header := make([]byte, 8)
+	header[0] = 0x30
+	header[1] = gtpuMsgTypeGPDU
+	binary.BigEndian.PutUint16(header[2:4], uint16(payloadLen))
+	binary.BigEndian.PutUint32(header[4:8], teid)
+	return header
+}
+
+// sendEndMarker 向 Outer Header Creation 指向的 gNB 发送 End Marker
+func (m *MockUPF) sendEndMarker(ohc *ie.OuterHeaderCreationFields) {
+	header := buildGTPUHeader(ohc.TEID, 0)
+	header[1] = gtpuMsgTypeEndMarker
+
+	dst := &net.UDPAddr{IP: ohc.IPv4Address, Port: m.cfg.GTPUPort}
+	if _, err := m.n3Conn.WriteToUDP(header, dst); err != nil {
+		log.Printf("mock upf write end marker failed: %v", err)
+		return
+	}
+	log.Printf("Mock UPF sent end marker to %s, TEID=%d", dst, ohc.TEID)
+}
+
+// buildReply 为上行 ICMP Echo Request 或 UDP 报文构造下行应答，返回应答和 UE 地址
+func buildReply(packet []byte) ([]byte, net.IP, error) {
+	header, err := ipv4.ParseHeader(packet)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(packet) < header.Len {
+		return nil, nil, fmt.Errorf("ip packet truncated")
+	}
+
+	switch header.Protocol {
+	case 1:
+		return buildEchoReply(header, packet)
+	case 17:
+		return buildUDPReply(header, packet)
+	default:
+		return nil, nil, fmt.Errorf("unsupported protocol %d", header.Protocol)
+	}
+}
+
+// buildUDPReply 交换地址和端口回送 UDP 报文，负载保持不变
+func buildUDPReply(header *ipv4.Header, packet []byte) ([]byte, net.IP, error) {
+	datagram := packet[header.Len:]
+	if len(datagram) < 8 {
+		return nil, nil, fmt.Errorf("udp datagram truncated")
+	}
+
+	reply := make([]byte, 20+len(datagram))
+	copy(reply[20:], datagram)
+	udp := reply[20:]
+	copy(udp[0:2], datagram[2:4])
+	copy(udp[2:4], datagram[0:2])
+	udp[6], udp[7] = 0, 0 // IPv4 下 UDP 校验和可选
+
+	writeIPv4Header(reply[:20], header, 17, len(datagram))
+	return reply, header.Src, nil
+}
+
+// buildEchoReply 将 IPv4 ICMP Echo Request 转换为 Echo Reply，返回应答包和原始源地址
+func buildEchoReply(header *ipv4.Header, packet []byte) ([]byte, net.IP, error) {
+	msg, err := icmp.ParseMessage(1, packet[header.Len:])
+	if err != nil {
+		return nil, nil, err
+	}
+	echo, ok := msg.Body.(*icmp.Echo)
+	if !ok || msg.Type != ipv4.ICMPTypeEcho {
+		return nil, nil, fmt.Errorf("not an icmp echo request")
+	}
+
+	reply := icmp.Message{Type: ipv4.ICMPTypeEchoReply, Body: echo}
+	body, err := reply.Marshal(nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ipHeader := make([]byte, 20)
+	writeIPv4Header(ipHeader, header, 1, len(body))
+
+	return append(ipHeader, body...), header.Src, nil
+}
+
+// writeIPv4Header 写入交换了源/目的地址的应答 IPv4 头部
+func writeIPv4Header(b []byte, req *ipv4.Header, protocol uint8, payloadLen int) {
+	b[0] = 0x45
+	binary.BigEndian.PutUint16(b[2:4], uint16(20+payloadLen))
+	binary.BigEndian.PutUint16(b[4:6], uint16(req.ID))
+	b[6] = 0x40
+	b[8] = 64
+	b[9] = protocol
+	copy(b[12:16], req.Dst.To4())
+	copy(b[16:20], req.Src.To4())
+	binary.BigEndian.PutUint16(b[10:12], checksum(b))
+}
+
+func checksum(data []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(data); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(data[i:]))
+	}
+	if len(data)%2 != 0 {
+		sum += uint32(data[len(data)-1]) << 8
+	}
+	sum = (sum >> 16) + (sum & 0xffff)
+	sum += sum >> 16
+	return ^uint16(sum)
+}
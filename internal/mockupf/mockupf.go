@@ -0,0 +1,282 @@
+package mockupf
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// Causes 各类请求的应答 Cause，零值表示 Request Accepted
+type Causes struct {
+	AssociationSetup uint8 `yaml:"associationSetup"`
+	Establishment    uint8 `yaml:"establishment"`
+	Modification     uint8 `yaml:"modification"`
+	Deletion         uint8 `yaml:"deletion"`
+}
+
+// Config 模拟 UPF 配置
+type Config struct {
+	N4Addr             string // N4 监听地址 (IP:Port)，端口为空时使用 8805
+	N3Ip               string // N3 接口 IP，用于分配 F-TEID 和接收 GTP-U
+	GTPUPort           int    // GTP-U 端口，默认 2152
+	N6Addr             string // N6 隧道监听地址 (IP:Port)，DN 侧报文以 IP-in-UDP 形式收发，为空时不启用
+	N6Peer             string // DN 侧 N6 隧道地址 (IP:Port)，配置后上行报文经 N6 隧道发往 DN，而不在 UPF 内回环
+	NodeID             string // UPF Node ID，默认使用 N4 IP
+	StartSEID          uint64 // UPF SEID 起始值
+	StartTEID          uint32 // 本端 TEID 起始值
+	StartUEIP          string // PDR 请求 UPF 分配 UE 地址 (CHV4) 时的起始地址，默认 10.251.0.1
+	Causes             Causes // 应答 Cause
+	RequireAssociation bool   // 是否要求先建立 Association 才接受会话请求
+}
+
+// Stats 模拟 UPF 数据面统计
+type Stats struct {
+	UplinkPackets   int
+	UplinkBytes     int64
+	DownlinkPackets int
+	DownlinkBytes   int64
+}
+
+// MockUPF 模拟 UPF，应答 PFCP 请求并将上行 ICMP/UDP 回环为下行，或经 N6 隧道转发给 DN
+type MockUPF struct {
+	cfg          Config
+	recoveryTime time.Time
+
+	n4Conn *net.UDPConn
+	n3Conn *net.UDPConn
+	n6Conn *net.UDPConn // 未启用 N6 隧道时为 nil
+
+	mu           sync.Mutex
+	causes       Causes
+	n6Peer       *net.UDPAddr // 上行报文的 N6 隧道目的地址，nil 表示回环
+	associations map[string]bool
+	sessions     map[uint64]*session // UPF SEID -> session
+	teids        map[uint32]*session // 本端 TEID -> session
+	nextSEID     uint64
+	nextTEID     uint32
+	nextUEIP     uint32
+	stats        Stats
+
+	dropRequests       int               // 待丢弃的请求数
+	duplicateResponses bool              // 每个应答发送两次
+	responses          map[string][]byte // 对端地址/序列号 -> 应答，用于应答重传请求
+
+	nextSeq      uint32  // UPF 发起请求的序列号
+	reportCauses []uint8 // 收到的 Session Report Response Cause
+
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+// New 创建模拟 UPF，并绑定 N4 与 N3 端口
+func New(cfg Config) (*MockUPF, error) {
+	host, port, err := net.SplitHostPort(cfg.N4Addr)
+	if err != nil {
+		host, port = cfg.N4Addr, "8805"
+	}
+	if port == "" {
+		port = "8805"
+	}
+	if cfg.N3Ip == "" {
+		cfg.N3Ip = host
+	}
+	if cfg.GTPUPort == 0 {
+		cfg.GTPUPort = 2152
+	}
+	if cfg.NodeID == "" {
+		cfg.NodeID = host
+	}
+	if cfg.StartSEID == 0 {
+		cfg.StartSEID = 1
+	}
+	if cfg.StartTEID == 0 {
+		cfg.StartTEID = 1
+	}
+	if cfg.StartUEIP == "" {
+		cfg.StartUEIP = "10.251.0.1"
+	}
+	startUEIP := net.ParseIP(cfg.StartUEIP).To4()
+	if startUEIP == nil {
+		return nil, fmt.Errorf("invalid start UE IP %q", cfg.StartUEIP)
+	}
+
+	n4Addr, err := net.ResolveUDPAddr("udp", net.JoinHostPort(host, port))
+	if err != nil {
+		return nil, fmt.Errorf("resolve N4 address failed: %w", err)
+	}
+	n4Conn, err := net.ListenUDP("udp", n4Addr)
+	if err != nil {
+		return nil, fmt.Errorf("listen N4 failed: %w", err)
+	}
+
+	n3Addr, err := net.ResolveUDPAddr("udp", net.JoinHostPort(cfg.N3Ip, fmt.Sprint(cfg.GTPUPort)))
+	if err != nil {
+		n4Conn.Close()
+		return nil, fmt.Errorf("resolve N3 address failed: %w", err)
+	}
+	n3Conn, err := net.ListenUDP("udp", n3Addr)
+	if err != nil {
+		n4Conn.Close()
+		return nil, fmt.Errorf("listen N3 failed: %w", err)
+	}
+
+	var n6Conn *net.UDPConn
+	var n6Peer *net.UDPAddr
+	if cfg.N6Addr != "" {
+		n6Addr, err := net.ResolveUDPAddr("udp", cfg.N6Addr)
+		if err == nil {
+			n6Conn, err = net.ListenUDP("udp", n6Addr)
+		}
+		if err != nil {
+			n4Conn.Close()
+			n3Conn.Close()
+			return nil, fmt.Errorf("listen N6 failed: %w", err)
+		}
+		if cfg.N6Peer != "" {
+			if n6Peer, err = net.ResolveUDPAddr("udp", cfg.N6Peer); err != nil {
+				n4Conn.Close()
+				n3Conn.Close()
+				n6Conn.Close()
+				return nil, fmt.Errorf("resolve N6 peer address failed: %w", err)
+			}
+		}
+	}
+
+	return &MockUPF{
+		cfg:          cfg,
+		recoveryTime: time.Now(),
+		n4Conn:       n4Conn,
+		n3Conn:       n3Conn,
+		n6Conn:       n6Conn,
+		causes:       cfg.Causes,
+		n6Peer:       n6Peer,
+		responses:    make(map[string][]byte),
+		associations: make(map[string]bool),
+		sessions:     make(map[uint64]*session),
+		teids:        make(map[uint32]*session),
+		nextSEID:     cfg.StartSEID,
+		nextTEID:     cfg.StartTEID,
+		nextUEIP:     binary.BigEndian.Uint32(startUEIP),
+		stopChan:     make(chan struct{}),
+	}, nil
+}
+
+// Start 启动 N4 与 N3 处理协程
+func (m *MockUPF) Start() {
+	m.wg.Add(2)
+	go m.servePFCP()
+	go m.serveGTPU()
+	log.Printf("Mock UPF started, N4=%s, N3=%s", m.n4Conn.LocalAddr(), m.n3Conn.LocalAddr())
+
+	if m.n6Conn != nil {
+		m.wg.Add(1)
+		go m.serveN6()
+		log.Printf("Mock UPF N6 tunnel listening on %s", m.n6Conn.LocalAddr())
+	}
+}
+
+// Stop 停止模拟 UPF 并释放端口
+func (m *MockUPF) Stop() {
+	close(m.stopChan)
+	m.n4Conn.Close()
+	m.n3Conn.Close()
+	if m.n6Conn != nil {
+		m.n6Conn.Close()
+	}
+	m.wg.Wait()
+}
+
+// N4Addr 返回实际监听的 N4 地址
+func (m *MockUPF) N4Addr() *net.UDPAddr {
+	return m.n4Conn.LocalAddr().(*net.UDPAddr)
+}
+
+// N3Addr 返回实际监听的 N3 地址
+func (m *MockUPF) N3Addr() *net.UDPAddr {
+	return m.n3Conn.LocalAddr().(*net.UDPAddr)
+}
+
+// N6Addr 返回实际监听的 N6 隧道地址，未启用时为 nil
+func (m *MockUPF) N6Addr() *net.UDPAddr {
+	if m.n6Conn == nil {
+		return nil
+	}
+	return m.n6Conn.LocalAddr().(*net.UDPAddr)
+}
+
+// SetCauses 运行时修改应答 Cause
+func (m *MockUPF) SetCauses(causes Causes) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.causes = causes
+}
+
+// SetN6Peer 运行时设置 DN 侧 N6 隧道地址，为空时恢复在 UPF 内回环上行报文
+func (m *MockUPF) SetN6Peer(addr string) error {
+	var peer *net.UDPAddr
+	if addr != "" {
+		if m.n6Conn == nil {
+			return fmt.Errorf("N6 tunnel is not enabled")
+		}
+		var err error
+		if peer, err = net.ResolveUDPAddr("udp", addr); err != nil {
+			return fmt.Errorf("resolve N6 peer address failed: %w", err)
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.n6Peer = peer
+	return nil
+}
+
+// DropRequests 丢弃接下来收到的 n 个 PFCP 请求，用于测试重传
+func (m *MockUPF) DropRequests(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.dropRequests = n
+}
+
+// SetDuplicateResponses 设置是否将每个 PFCP 应答发送两次
+func (m *MockUPF) SetDuplicateResponses(enable bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.duplicateResponses = enable
+}
+
+// SessionCount 返回当前会话数量
+func (m *MockUPF) SessionCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.sessions)
+}
+
+// CPSEIDs 返回当前会话的 CP SEID
+func (m *MockUPF) CPSEIDs() []uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	seids := make([]uint64, 0, len(m.sessions))
+	for _, sess := range m.sessions {
+		seids = append(seids, sess.cpSEID)
+	}
+	return seids
+}
+
+// GetStats 返回数据面统计
+func (m *MockUPF) GetStats() Stats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.stats
+}
+
+func (m *MockUPF) stopped() bool {
+	select {
+	case <-m.stopChan:
+		return true
+	default:
+		return false
+	}
+}
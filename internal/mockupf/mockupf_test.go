@@ -0,0 +1,229 @@
+package mockupf
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+const (
+	testGnbIp = "127.0.2.1"
+	testN3Ip  = "127.0.2.2"
+)
+
+func newTestUPF(t *testing.T, causes Causes) *MockUPF {
+	t.Helper()
+	upf, err := New(Config{N4Addr: testN3Ip + ":0", N3Ip: testN3Ip, Causes: causes})
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	upf.Start()
+	t.Cleanup(upf.Stop)
+	return upf
+}
+
+func exchange(t *testing.T, conn *net.UDPConn, upf *MockUPF, req message.Message) message.Message {
+	t.Helper()
+	data := make([]byte, req.MarshalLen())
+	if err := req.MarshalTo(data); err != nil {
+		t.Fatalf("marshal request failed: %v", err)
+	}
+	if _, err := conn.WriteToUDP(data, upf.N4Addr()); err != nil {
+		t.Fatalf("write request failed: %v", err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	buffer := make([]byte, 65535)
+	n, _, err := conn.ReadFromUDP(buffer)
+	if err != nil {
+		t.Fatalf("read response failed: %v", err)
+	}
+	resp, err := message.Parse(buffer[:n])
+	if err != nil {
+		t.Fatalf("parse response failed: %v", err)
+	}
+	return resp
+}
+
+func establishmentRequest(cpSEID uint64, downlinkFAR uint32) *message.SessionEstablishmentRequest {
+	return message.NewSessionEstablishmentRequest(0, 0, 0, 1, 0,
+		ie.NewNodeID(testGnbIp, "", ""),
+		ie.NewFSEID(cpSEID, net.ParseIP(testGnbIp), nil),
+		ie.NewCreatePDR(
+			ie.NewPDRID(1),
+			ie.NewPrecedence(10),
+			ie.NewPDI(
+				ie.NewSourceInterface(ie.SrcInterfaceAccess),
+				ie.NewFTEID(0x0d, 0, nil, nil, 1),
+				ie.NewUEIPAddress(0x02, "10.250.0.1", "", 0, 0),
+			),
+			ie.NewFARID(1),
+		),
+		ie.NewCreatePDR(
+			ie.NewPDRID(2),
+			ie.NewPrecedence(10),
+			ie.NewPDI(
+				ie.NewSourceInterface(ie.SrcInterfaceCore),
+				ie.NewUEIPAddress(0x06, "10.250.0.1", "", 0, 0),
+			),
+			ie.NewFARID(downlinkFAR),
+		),
+		ie.NewCreateFAR(ie.NewFARID(1), ie.NewApplyAction(0x02),
+			ie.NewForwardingParameters(ie.NewDestinationInterface(ie.DstInterfaceCore))),
+		ie.NewCreateFAR(ie.NewFARID(2), ie.NewApplyAction(0x02),
+			ie.NewForwardingParameters(
+				ie.NewDestinationInterface(ie.DstInterfaceAccess),
+				ie.NewOuterHeaderCreation(0x0100, 100, testGnbIp, "", 0, 0, 0),
+			)),
+	)
+}
+
+func TestMockUPF_SessionLifecycleAndReflect(t *testing.T) {
+	upf := newTestUPF(t, Causes{})
+
+	n4, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP(testGnbIp)})
+	if err != nil {
+		t.Fatalf("listen N4 failed: %v", err)
+	}
+	defer n4.Close()
+
+	resp, ok := exchange(t, n4, upf, establishmentRequest(1, 2)).(*message.SessionEstablishmentResponse)
+	if !ok {
+		t.Fatal("expect session establishment response")
+	}
+	if cause, _ := resp.Cause.Cause(); cause != ie.CauseRequestAccepted {
+		t.Fatalf("expect cause accepted, got %d", cause)
+	}
+	if resp.SEID() != 1 {
+		t.Errorf("expect response SEID 1, got %d", resp.SEID())
+	}
+	if len(resp.CreatedPDR) != 1 {
+		t.Fatalf("expect 1 created PDR, got %d", len(resp.CreatedPDR))
+	}
+	fteid, err := resp.CreatedPDR[0].FTEID()
+	if err != nil {
+		t.Fatalf("created PDR F-TEID parse failed: %v", err)
+	}
+	if !fteid.IPv4Address.Equal(net.ParseIP(testN3Ip)) {
+		t.Errorf("expect F-TEID IPv4 %s, got %s", testN3Ip, fteid.IPv4Address)
+	}
+	upSEID, _ := resp.UPFSEID.FSEID()
+
+	gnb, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP(testGnbIp), Port: 2152})
+	if err != nil {
+		t.Fatalf("listen gNB failed: %v", err)
+	}
+	defer gnb.Close()
+
+	echo, _ := (&icmp.Message{Type: ipv4.ICMPTypeEcho, Body: &icmp.Echo{ID: 7, Seq: 3, Data: []byte("ping")}}).Marshal(nil)
+	ipHeader := make([]byte, 20)
+	ipHeader[0], ipHeader[8], ipHeader[9] = 0x45, 64, 1
+	ipHeader[3] = byte(20 + len(echo))
+	copy(ipHeader[12:16], net.ParseIP("10.250.0.1").To4())
+	copy(ipHeader[16:20], net.ParseIP("8.8.8.8").To4())
+	uplink := append(buildGTPUHeader(fteid.TEID, 20+len(echo)), append(ipHeader, echo...)...)
+	if _, err := gnb.WriteToUDP(uplink, upf.N3Addr()); err != nil {
+		t.Fatalf("send uplink failed: %v", err)
+	}
+
+	gnb.SetReadDeadline(time.Now().Add(time.Second))
+	buffer := make([]byte, 2048)
+	n, _, err := gnb.ReadFromUDP(buffer)
+	if err != nil {
+		t.Fatalf("read downlink failed: %v", err)
+	}
+	teid, inner, err := parseGPDU(buffer[:n])
+	if err != nil {
+		t.Fatalf("parse downlink failed: %v", err)
+	}
+	if teid != 100 {
+		t.Errorf("expect downlink TEID 100, got %d", teid)
+	}
+	reply, err := icmp.ParseMessage(1, inner[20:])
+	if err != nil || reply.Type != ipv4.ICMPTypeEchoReply {
+		t.Fatalf("expect echo reply, got %v (%v)", reply, err)
+	}
+	if body := reply.Body.(*icmp.Echo); body.ID != 7 || body.Seq != 3 {
+		t.Errorf("expect echo id 7 seq 3, got %d %d", body.ID, body.Seq)
+	}
+	if stats := upf.GetStats(); stats.UplinkPackets != 1 || stats.DownlinkPackets != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	del := message.NewSessionDeletionRequest(0, 0, upSEID.SEID, 2, 0)
+	delResp, ok := exchange(t, n4, upf, del).(*message.SessionDeletionResponse)
+	if !ok {
+		t.Fatal("expect session deletion response")
+	}
+	if cause, _ := delResp.Cause.Cause(); cause != ie.CauseRequestAccepted {
+		t.Errorf("expect deletion accepted, got %d", cause)
+	}
+	if upf.SessionCount() != 0 {
+		t.Errorf("expect no sessions left, got %d", upf.SessionCount())
+	}
+}
+
+func TestMockUPF_Rejections(t *testing.T) {
+	tests := []struct {
+		name          string
+		causes        Causes
+		downlinkFAR   uint32
+		expectCause   uint8
+		expectOffense uint16
+	}{
+		{
+			name:        "configured establishment cause",
+			causes:      Causes{Establishment: ie.CauseNoResourcesAvailable},
+			downlinkFAR: 2,
+			expectCause: ie.CauseNoResourcesAvailable,
+		},
+		{
+			name:          "unknown FAR ID",
+			downlinkFAR:   9,
+			expectCause:   ie.CauseMandatoryIEMissing,
+			expectOffense: ie.FARID,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upf := newTestUPF(t, tt.causes)
+
+			n4, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP(testGnbIp)})
+			if err != nil {
+				t.Fatalf("listen N4 failed: %v", err)
+			}
+			defer n4.Close()
+
+			upf.mu.Lock()
+			startTEID := upf.nextTEID
+			upf.mu.Unlock()
+
+			resp := exchange(t, n4, upf, establishmentRequest(1, tt.downlinkFAR)).(*message.SessionEstablishmentResponse)
+			if cause, _ := resp.Cause.Cause(); cause != tt.expectCause {
+				t.Errorf("expect cause %d, got %d", tt.expectCause, cause)
+			}
+			if tt.expectOffense != 0 {
+				if resp.OffendingIE == nil {
+					t.Fatal("expect offending IE")
+				}
+				if offense, _ := resp.OffendingIE.OffendingIE(); offense != tt.expectOffense {
+					t.Errorf("expect offending IE %d, got %d", tt.expectOffense, offense)
+				}
+			}
+			if upf.SessionCount() != 0 {
+				t.Errorf("rejected session must not be stored")
+			}
+			upf.mu.Lock()
+			defer upf.mu.Unlock()
+			if upf.nextTEID != startTEID || len(upf.teids) != 0 {
+				t.Errorf("rejected session must not consume TEIDs")
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package mockupf
+
+import (
+	"log"
+	"net"
+
+	"github.com/wmnsk/go-pfcp/ie"
+	"golang.org/x/net/ipv4"
+)
+
+// serveN6 接收 N6 隧道中 DN 侧发往 UE 的 IPv4 报文 (每个 UDP 负载为一个完整的 IP 包)，按下行 FAR 处理
+func (m *MockUPF) serveN6() {
+	defer m.wg.Done()
+
+	buffer := make([]byte, 65535)
+	for {
+		n, _, err := m.n6Conn.ReadFromUDP(buffer)
+		if err != nil {
+			if m.stopped() {
+				return
+			}
+			log.Printf("mock upf read N6 failed: %v", err)
+			continue
+		}
+
+		header, err := ipv4.ParseHeader(buffer[:n])
+		if err != nil {
+			continue
+		}
+		packet := append([]byte(nil), buffer[:n]...)
+
+		m.mu.Lock()
+		sess, p, f := m.findDownlink(header.Dst)
+		if f == nil {
+			m.mu.Unlock()
+			log.Printf("mock upf no downlink FAR for UE %s, drop", header.Dst)
+			continue
+		}
+		notify := m.downlink(sess, p, f, packet)
+		cpSEID := sess.cpSEID
+		m.mu.Unlock()
+
+		if notify {
+			m.reportDownlinkData(cpSEID, p.id)
+		}
+	}
+}
+
+// findDownlink 按 UE 地址查找会话及其下行 PDR 和 FAR，调用方持有 m.mu
+func (m *MockUPF) findDownlink(ueIP net.IP) (*session, *pdr, *far) {
+	for _, sess := range m.sessions {
+		p, f := sess.downlinkFAR(ueIP)
+		if f != nil && p.ueIP != nil && p.ueIP.Equal(ueIP) {
+			return sess, p, f
+		}
+	}
+	return nil, nil, nil
+}
+
+// downlink 按下行 FAR 的 Apply Action 转发、缓存或丢弃发往 UE 的报文，调用方持有 m.mu。
+// 返回 true 表示 FAR 设置了 NOCP 且本轮缓存了首个报文，需要上报 Downlink Data Report
+func (m *MockUPF) downlink(sess *session, p *pdr, f *far, packet []byte) bool {
+	action := f.action()
+	switch {
+	case action&applyActionBUFF != 0:
+		if len(f.buffered) < sess.bufferLimit {
+			f.buffered = append(f.buffered, packet)
+		}
+		if action&applyActionNOCP != 0 && !f.notified {
+			f.notified = true
+			return true
+		}
+
+	case action&applyActionFORW != 0:
+		if f.ohc == nil || f.ohc.IPv4Address == nil {
+			log.Printf("mock upf downlink FAR %d without outer header creation, drop", f.id)
+			return false
+		}
+		if p != nil && !sess.allow(p.qerIDs, false, len(packet)) {
+			return false
+		}
+		m.sendDownlink(sess, f.ohc, packet)
+	}
+	return false
+}
+
+// sendDownlink 按 Outer Header Creation 封装并发送下行报文，调用方持有 m.mu
+func (m *MockUPF) sendDownlink(sess *session, ohc *ie.OuterHeaderCreationFields, packet []byte) {
+	gpdu := append(buildGTPUHeader(ohc.TEID, len(packet)), packet...)
+	dst := &net.UDPAddr{IP: ohc.IPv4Address, Port: m.cfg.GTPUPort}
+	if _, err := m.n3Conn.WriteToUDP(gpdu, dst); err != nil {
+		log.Printf("mock upf write N3 failed: %v", err)
+		return
+	}
+
+	m.stats.DownlinkPackets++
+	m.stats.DownlinkBytes += int64(len(gpdu))
+	sess.usage.DownlinkPackets++
+	sess.usage.DownlinkBytes += int64(len(packet))
+}
+
+// flush FAR 恢复转发后按到达顺序发送缓存的报文，调用方持有 m.mu
+func (m *MockUPF) flush(sess *session, f *far) {
+	if f.action()&applyActionBUFF == 0 {
+		f.notified = false
+	}
+	if f.action()&applyActionFORW == 0 || len(f.buffered) == 0 {
+		return
+	}
+
+	buffered := f.buffered
+	f.buffered = nil
+	p := sess.farPDR(f.id)
+	for _, packet := range buffered {
+		m.downlink(sess, p, f, packet)
+	}
+	log.Printf("Mock UPF flushed %d buffered packets, FAR ID: %d", len(buffered), f.id)
+}
+
+// reportDownlinkData 发送带 Downlink Data Report 的 Session Report Request
+func (m *MockUPF) reportDownlinkData(cpSEID uint64, pdrID uint16) {
+	err := m.SendSessionReport(cpSEID,
+		ie.NewReportType(0, 0, 0, 1),
+		ie.NewDownlinkDataReport(ie.NewPDRID(pdrID)),
+	)
+	if err != nil {
+		log.Printf("mock upf send downlink data report failed: %v", err)
+		return
+	}
+	log.Printf("Mock UPF reported downlink data, CP SEID: 0x%016x, PDR ID: %d", cpSEID, pdrID)
+}
@@ -0,0 +1,545 @@
+package mockupf
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+	"net"
+
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+// maxCachedResponses 应答缓存上限，超过后清空
+const maxCachedResponses = 4096
+
+// upFunctionFeatures 模拟 UPF 在 Association 响应中声明的 UP Function Features (BUCP)
+var upFunctionFeatures = []uint8{0x01, 0x00}
+
+// servePFCP 接收并应答 PFCP 请求
+func (m *MockUPF) servePFCP() {
+	defer m.wg.Done()
+
+	buffer := make([]byte, 65535)
+	for {
+		n, addr, err := m.n4Conn.ReadFromUDP(buffer)
+		if err != nil {
+			if m.stopped() {
+				return
+			}
+			log.Printf("mock upf read N4 failed: %v", err)
+			continue
+		}
+
+		msg, err := message.Parse(buffer[:n])
+		if err != nil {
+			log.Printf("mock upf parse PFCP failed: %v", err)
+			continue
+		}
+
+		if resp, ok := msg.(*message.SessionReportResponse); ok {
+			m.handleReportResponse(resp)
+			continue
+		}
+
+		if m.dropRequest() {
+			log.Printf("mock upf drop PFCP message type %d, seq=%d", msg.MessageType(), msg.Sequence())
+			continue
+		}
+
+		// 重传的请求直接返回缓存的应答，不再重复处理
+		key := fmt.Sprintf("%s/%d", addr, msg.Sequence())
+		data, cached := m.cachedResponse(key)
+		if !cached {
+			resp := m.handlePFCP(msg, addr)
+			if resp == nil {
+				continue
+			}
+
+			data = make([]byte, resp.MarshalLen())
+			if err := resp.MarshalTo(data); err != nil {
+				log.Printf("mock upf marshal PFCP failed: %v", err)
+				continue
+			}
+			m.cacheResponse(key, data)
+		}
+
+		m.writeN4(data, addr)
+		if m.duplicating() {
+			m.writeN4(data, addr)
+		}
+	}
+}
+
+func (m *MockUPF) writeN4(data []byte, addr *net.UDPAddr) {
+	if _, err := m.n4Conn.WriteToUDP(data, addr); err != nil {
+		log.Printf("mock upf write N4 failed: %v", err)
+	}
+}
+
+// dropRequest 判断是否丢弃当前收到的请求
+func (m *MockUPF) dropRequest() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.dropRequests > 0 {
+		m.dropRequests--
+		return true
+	}
+	return false
+}
+
+func (m *MockUPF) duplicating() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.duplicateResponses
+}
+
+func (m *MockUPF) cachedResponse(key string) ([]byte, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	data, ok := m.responses[key]
+	return data, ok
+}
+
+func (m *MockUPF) cacheResponse(key string, data []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.responses) >= maxCachedResponses {
+		m.responses = make(map[string][]byte)
+	}
+	m.responses[key] = data
+}
+
+// handlePFCP 根据消息类型生成应答，返回 nil 表示不应答
+func (m *MockUPF) handlePFCP(msg message.Message, addr *net.UDPAddr) message.Message {
+	switch req := msg.(type) {
+	case *message.HeartbeatRequest:
+		return message.NewHeartbeatResponse(req.Sequence(), ie.NewRecoveryTimeStamp(m.recoveryTime))
+	case *message.AssociationSetupRequest:
+		return m.handleAssociationSetup(req)
+	case *message.SessionEstablishmentRequest:
+		return m.handleEstablishment(req, addr)
+	case *message.SessionModificationRequest:
+		return m.handleModification(req)
+	case *message.SessionDeletionRequest:
+		return m.handleDeletion(req)
+	case *message.SessionSetDeletionRequest:
+		return m.handleSessionSetDeletion(req, addr)
+	case *message.AssociationUpdateRequest:
+		return m.handleAssociationUpdate(req)
+	case *message.AssociationReleaseRequest:
+		return m.handleAssociationRelease(req, addr)
+	default:
+		log.Printf("mock upf ignore PFCP message type %d", msg.MessageType())
+		return nil
+	}
+}
+
+func (m *MockUPF) handleAssociationSetup(req *message.AssociationSetupRequest) message.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cause := acceptedOr(m.causes.AssociationSetup)
+	if cause == ie.CauseRequestAccepted && req.NodeID != nil {
+		if nodeID, err := req.NodeID.NodeID(); err == nil {
+			m.associations[nodeID] = true
+		}
+	}
+
+	return message.NewAssociationSetupResponse(req.Sequence(),
+		ie.NewNodeIDHeuristic(m.cfg.NodeID),
+		ie.NewCause(cause),
+		ie.NewRecoveryTimeStamp(m.recoveryTime),
+		ie.NewUPFunctionFeatures(upFunctionFeatures...),
+	)
+}
+
+// handleAssociationUpdate 应答已建立 Association 的 CP 发起的 Association Update Request
+func (m *MockUPF) handleAssociationUpdate(req *message.AssociationUpdateRequest) message.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	nodeID := ie.NewNodeIDHeuristic(m.cfg.NodeID)
+	if req.NodeID == nil {
+		return message.NewAssociationUpdateResponse(req.Sequence(), nodeID, ie.NewCause(ie.CauseMandatoryIEMissing))
+	}
+	id, err := req.NodeID.NodeID()
+	if err != nil {
+		return message.NewAssociationUpdateResponse(req.Sequence(), nodeID, ie.NewCause(ie.CauseMandatoryIEIncorrect))
+	}
+	if !m.associations[id] {
+		return message.NewAssociationUpdateResponse(req.Sequence(), nodeID, ie.NewCause(ie.CauseNoEstablishedPFCPAssociation))
+	}
+
+	log.Printf("Mock UPF association with %s updated", id)
+	return message.NewAssociationUpdateResponse(req.Sequence(), nodeID,
+		ie.NewCause(ie.CauseRequestAccepted),
+		ie.NewUPFunctionFeatures(upFunctionFeatures...),
+	)
+}
+
+func (m *MockUPF) handleEstablishment(req *message.SessionEstablishmentRequest, addr *net.UDPAddr) message.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var cpSEID uint64
+	if req.CPFSEID != nil {
+		if fseid, err := req.CPFSEID.FSEID(); err == nil {
+			cpSEID = fseid.SEID
+		}
+	}
+
+	reject := func(cause uint8, offending ...*ie.IE) message.Message {
+		return message.NewSessionEstablishmentResponse(0, 0, cpSEID, req.Sequence(), 0,
+			append([]*ie.IE{ie.NewNodeIDHeuristic(m.cfg.NodeID), ie.NewCause(cause)}, offending...)...)
+	}
+
+	if cause := acceptedOr(m.causes.Establishment); cause != ie.CauseRequestAccepted {
+		return reject(cause)
+	}
+	if req.CPFSEID == nil {
+		return reject(ie.CauseMandatoryIEMissing, ie.NewOffendingIE(ie.FSEID))
+	}
+	if m.cfg.RequireAssociation && !m.associated(req.NodeID) {
+		return reject(ie.CauseNoEstablishedPFCPAssociation)
+	}
+
+	sess := newSession(cpSEID, m.nextSEID, addr)
+	if req.CreateBAR != nil {
+		sess.applyBAR(req.CreateBAR)
+	}
+
+	for _, i := range req.CreateFAR {
+		f, err := parseFAR(i)
+		if err != nil {
+			return reject(ie.CauseMandatoryIEIncorrect, ie.NewOffendingIE(ie.CreateFAR))
+		}
+		sess.fars[f.id] = f
+	}
+
+	for _, i := range req.CreateQER {
+		q, err := parseQER(i)
+		if err != nil {
+			return reject(ie.CauseMandatoryIEIncorrect, ie.NewOffendingIE(ie.CreateQER))
+		}
+		sess.qers[q.id] = q
+	}
+
+	// 先校验全部 PDR，再分配 TEID 和 UE 地址，避免拒绝时泄漏已分配的资源
+	pdrs := make([]*pdr, 0, len(req.CreatePDR))
+	chooseTEID := make([]bool, 0, len(req.CreatePDR))
+	for _, i := range req.CreatePDR {
+		p, fteid, err := parsePDR(i)
+		if err != nil {
+			return reject(ie.CauseMandatoryIEIncorrect, ie.NewOffendingIE(ie.CreatePDR))
+		}
+		if _, ok := sess.fars[p.farID]; p.hasFAR && !ok {
+			return reject(ie.CauseMandatoryIEMissing, ie.NewOffendingIE(ie.FARID))
+		}
+		pdrs = append(pdrs, p)
+		chooseTEID = append(chooseTEID, fteid != nil && fteid.HasCh())
+	}
+
+	var created []*ie.IE
+	var ueIP net.IP
+	for idx, p := range pdrs {
+		sess.pdrs[p.id] = p
+
+		createdIEs := []*ie.IE{ie.NewPDRID(p.id)}
+		if chooseTEID[idx] {
+			p.teid = m.allocateTEID()
+			createdIEs = append(createdIEs, ie.NewFTEID(0x01, p.teid, net.ParseIP(m.cfg.N3Ip), nil, 0))
+		}
+		// 会话内请求分配 UE 地址的 PDR 共用同一个地址
+		if p.chooseUEIP {
+			if ueIP == nil {
+				ueIP = m.allocateUEIP()
+			}
+			p.ueIP = ueIP
+			createdIEs = append(createdIEs, ie.NewUEIPAddress(0x02, ueIP.String(), "", 0, 0))
+		}
+		if len(createdIEs) > 1 {
+			created = append(created, ie.NewCreatedPDR(createdIEs...))
+		}
+	}
+
+	m.nextSEID++
+	m.sessions[sess.upSEID] = sess
+	for _, p := range sess.pdrs {
+		if p.teid != 0 {
+			m.teids[p.teid] = sess
+		}
+	}
+
+	log.Printf("Mock UPF session established, CP SEID: 0x%016x, UP SEID: 0x%016x", sess.cpSEID, sess.upSEID)
+
+	ies := []*ie.IE{
+		ie.NewNodeIDHeuristic(m.cfg.NodeID),
+		ie.NewCause(ie.CauseRequestAccepted),
+		ie.NewFSEID(sess.upSEID, m.N4Addr().IP.To4(), nil),
+	}
+	ies = append(ies, created...)
+	return message.NewSessionEstablishmentResponse(0, 0, cpSEID, req.Sequence(), 0, ies...)
+}
+
+func (m *MockUPF) handleModification(req *message.SessionModificationRequest) message.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sess, ok := m.sessions[req.SEID()]
+	if !ok {
+		return message.NewSessionModificationResponse(0, 0, 0, req.Sequence(), 0,
+			ie.NewCause(ie.CauseSessionContextNotFound))
+	}
+
+	reply := func(cause uint8, ies ...*ie.IE) message.Message {
+		return message.NewSessionModificationResponse(0, 0, sess.cpSEID, req.Sequence(), 0,
+			append([]*ie.IE{ie.NewCause(cause)}, ies...)...)
+	}
+
+	if cause := acceptedOr(m.causes.Modification); cause != ie.CauseRequestAccepted {
+		return reply(cause)
+	}
+
+	for _, i := range req.CreateFAR {
+		f, err := parseFAR(i)
+		if err != nil {
+			return reply(ie.CauseMandatoryIEIncorrect, ie.NewOffendingIE(ie.CreateFAR))
+		}
+		sess.fars[f.id] = f
+	}
+
+	for _, i := range req.CreateQER {
+		q, err := parseQER(i)
+		if err != nil {
+			return reply(ie.CauseMandatoryIEIncorrect, ie.NewOffendingIE(ie.CreateQER))
+		}
+		sess.qers[q.id] = q
+	}
+
+	for _, i := range []*ie.IE{req.CreateBAR, req.UpdateBAR} {
+		if i != nil {
+			sess.applyBAR(i)
+		}
+	}
+	if req.RemoveBAR != nil {
+		sess.bufferLimit = defaultBufferLimit
+	}
+
+	for _, i := range req.UpdateQER {
+		id, err := i.QERID()
+		if err != nil {
+			return reply(ie.CauseMandatoryIEIncorrect, ie.NewOffendingIE(ie.UpdateQER))
+		}
+		q, ok := sess.qers[id]
+		if !ok {
+			return reply(ie.CauseRuleCreationModificationFailure, ie.NewOffendingIE(ie.QERID))
+		}
+		q.update(i)
+	}
+
+	for _, i := range req.RemoveQER {
+		if id, err := i.QERID(); err == nil {
+			delete(sess.qers, id)
+		}
+	}
+
+	for _, i := range req.UpdateFAR {
+		update, err := parseFAR(i)
+		if err != nil {
+			return reply(ie.CauseMandatoryIEIncorrect, ie.NewOffendingIE(ie.UpdateFAR))
+		}
+		f, ok := sess.fars[update.id]
+		if !ok {
+			return reply(ie.CauseRuleCreationModificationFailure, ie.NewOffendingIE(ie.FARID))
+		}
+		if update.applyAction != nil {
+			f.applyAction = update.applyAction
+		}
+		if update.ohc != nil {
+			// 设置 SNDEM 时在切换前的下行路径上发送 End Marker
+			if update.sndem && f.ohc != nil && f.ohc.IPv4Address != nil &&
+				(!f.ohc.IPv4Address.Equal(update.ohc.IPv4Address) || f.ohc.TEID != update.ohc.TEID) {
+				m.sendEndMarker(f.ohc)
+			}
+			f.ohc = update.ohc
+		}
+		m.flush(sess, f)
+	}
+
+	for _, i := range req.RemoveFAR {
+		if id, err := i.FARID(); err == nil {
+			delete(sess.fars, id)
+		}
+	}
+
+	var created []*ie.IE
+	for _, i := range req.CreatePDR {
+		p, fteid, err := parsePDR(i)
+		if err != nil {
+			return reply(ie.CauseMandatoryIEIncorrect, ie.NewOffendingIE(ie.CreatePDR))
+		}
+		sess.pdrs[p.id] = p
+		if fteid != nil && fteid.HasCh() {
+			p.teid = m.allocateTEID()
+			m.teids[p.teid] = sess
+			created = append(created, ie.NewCreatedPDR(
+				ie.NewPDRID(p.id),
+				ie.NewFTEID(0x01, p.teid, net.ParseIP(m.cfg.N3Ip), nil, 0),
+			))
+		}
+	}
+
+	for _, i := range req.UpdatePDR {
+		update, _, err := parsePDR(i)
+		if err != nil {
+			return reply(ie.CauseMandatoryIEIncorrect, ie.NewOffendingIE(ie.UpdatePDR))
+		}
+		p, ok := sess.pdrs[update.id]
+		if !ok {
+			return reply(ie.CauseRuleCreationModificationFailure, ie.NewOffendingIE(ie.PDRID))
+		}
+		p.merge(update, i)
+	}
+
+	for _, i := range req.RemovePDR {
+		if id, err := i.PDRID(); err == nil {
+			if p, ok := sess.pdrs[id]; ok && p.teid != 0 {
+				delete(m.teids, p.teid)
+			}
+			delete(sess.pdrs, id)
+		}
+	}
+
+	var queried []uint32
+	for _, i := range req.QueryURR {
+		if id, err := i.URRID(); err == nil {
+			queried = append(queried, id)
+		}
+	}
+
+	log.Printf("Mock UPF session modified, UP SEID: 0x%016x", sess.upSEID)
+	if len(queried) > 0 {
+		return reply(ie.CauseRequestAccepted, append(created, sess.usageReport(queried)...)...)
+	}
+	return reply(ie.CauseRequestAccepted, created...)
+}
+
+func (m *MockUPF) handleDeletion(req *message.SessionDeletionRequest) message.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sess, ok := m.sessions[req.SEID()]
+	if !ok {
+		return message.NewSessionDeletionResponse(0, 0, 0, req.Sequence(), 0,
+			ie.NewCause(ie.CauseSessionContextNotFound))
+	}
+
+	if cause := acceptedOr(m.causes.Deletion); cause != ie.CauseRequestAccepted {
+		return message.NewSessionDeletionResponse(0, 0, sess.cpSEID, req.Sequence(), 0, ie.NewCause(cause))
+	}
+
+	m.removeSession(sess)
+
+	log.Printf("Mock UPF session deleted, UP SEID: 0x%016x", sess.upSEID)
+	return message.NewSessionDeletionResponse(0, 0, sess.cpSEID, req.Sequence(), 0,
+		ie.NewCause(ie.CauseRequestAccepted))
+}
+
+// handleSessionSetDeletion 删除请求方建立的全部会话。模拟 UPF 不维护 FQ-CSID，按请求的源地址匹配会话
+func (m *MockUPF) handleSessionSetDeletion(req *message.SessionSetDeletionRequest, addr *net.UDPAddr) message.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	nodeID := ie.NewNodeIDHeuristic(m.cfg.NodeID)
+	if req.NodeID == nil {
+		return message.NewSessionSetDeletionResponse(req.Sequence(), nodeID,
+			ie.NewCause(ie.CauseMandatoryIEMissing), ie.NewOffendingIE(ie.NodeID))
+	}
+
+	deleted := m.removePeerSessions(addr)
+	log.Printf("Mock UPF session set deleted, %d sessions from %s", deleted, addr)
+	return message.NewSessionSetDeletionResponse(req.Sequence(), nodeID, ie.NewCause(ie.CauseRequestAccepted), nil)
+}
+
+// handleAssociationRelease 释放 Association 并删除请求方建立的全部会话
+func (m *MockUPF) handleAssociationRelease(req *message.AssociationReleaseRequest, addr *net.UDPAddr) message.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	nodeID := ie.NewNodeIDHeuristic(m.cfg.NodeID)
+	if req.NodeID == nil {
+		return message.NewAssociationReleaseResponse(req.Sequence(), nodeID, ie.NewCause(ie.CauseMandatoryIEMissing))
+	}
+	id, err := req.NodeID.NodeID()
+	if err != nil {
+		return message.NewAssociationReleaseResponse(req.Sequence(), nodeID, ie.NewCause(ie.CauseMandatoryIEIncorrect))
+	}
+	if !m.associations[id] {
+		return message.NewAssociationReleaseResponse(req.Sequence(), nodeID, ie.NewCause(ie.CauseNoEstablishedPFCPAssociation))
+	}
+
+	delete(m.associations, id)
+	deleted := m.removePeerSessions(addr)
+	log.Printf("Mock UPF association with %s released, %d sessions deleted", id, deleted)
+	return message.NewAssociationReleaseResponse(req.Sequence(), nodeID, ie.NewCause(ie.CauseRequestAccepted))
+}
+
+// removePeerSessions 删除 addr 建立的全部会话，返回删除的会话数，调用方需持有锁
+func (m *MockUPF) removePeerSessions(addr *net.UDPAddr) int {
+	deleted := 0
+	for _, sess := range m.sessions {
+		if sess.cpAddr != nil && sess.cpAddr.String() == addr.String() {
+			m.removeSession(sess)
+			deleted++
+		}
+	}
+	return deleted
+}
+
+// removeSession 删除会话及其 TEID，调用方需持有锁
+func (m *MockUPF) removeSession(sess *session) {
+	for _, p := range sess.pdrs {
+		if p.teid != 0 {
+			delete(m.teids, p.teid)
+		}
+	}
+	delete(m.sessions, sess.upSEID)
+}
+
+// associated 检查 Node ID 是否已建立 Association，调用方需持有锁
+func (m *MockUPF) associated(nodeID *ie.IE) bool {
+	if nodeID == nil {
+		return len(m.associations) > 0
+	}
+	id, err := nodeID.NodeID()
+	if err != nil {
+		return false
+	}
+	return m.associations[id]
+}
+
+// allocateTEID 分配本端 TEID，调用方需持有锁
+func (m *MockUPF) allocateTEID() uint32 {
+	teid := m.nextTEID
+	m.nextTEID++
+	return teid
+}
+
+// allocateUEIP 分配 UE 地址，调用方需持有锁
+func (m *MockUPF) allocateUEIP() net.IP {
+	ip := make(net.IP, net.IPv4len)
+	binary.BigEndian.PutUint32(ip, m.nextUEIP)
+	m.nextUEIP++
+	return ip
+}
+
+func acceptedOr(cause uint8) uint8 {
+	if cause == 0 {
+		return ie.CauseRequestAccepted
+	}
+	return cause
+}
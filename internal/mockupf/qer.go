@@ -0,0 +1,100 @@
+package mockupf
+
+import (
+	"time"
+
+	"github.com/wmnsk/go-pfcp/ie"
+)
+
+// qer 模拟 UPF 保存的 QER
+type qer struct {
+	id     uint32
+	gateUL uint8
+	gateDL uint8
+	ul     *tokenBucket // 上行 MBR 限速，nil 表示不限速
+	dl     *tokenBucket // 下行 MBR 限速，nil 表示不限速
+}
+
+// parseQER 从 Create QER 中解析 QER
+func parseQER(i *ie.IE) (*qer, error) {
+	id, err := i.QERID()
+	if err != nil {
+		return nil, err
+	}
+
+	q := &qer{id: id}
+	q.update(i)
+	return q, nil
+}
+
+// update 按 Update QER 更新门控状态和 MBR
+func (q *qer) update(i *ie.IE) {
+	if ul, dl, err := i.GateStatusULDL(); err == nil {
+		q.gateUL, q.gateDL = ul, dl
+	}
+	if ul, err := i.MBRUL(); err == nil {
+		q.ul = nil
+		if ul > 0 {
+			q.ul = newTokenBucket(ul)
+		}
+	}
+	if dl, err := i.MBRDL(); err == nil {
+		q.dl = nil
+		if dl > 0 {
+			q.dl = newTokenBucket(dl)
+		}
+	}
+}
+
+// allow 判断 size 字节的报文能否通过 PDR 关联的 QER：门控关闭或超过 MBR 时丢弃
+func (s *session) allow(qerIDs []uint32, uplink bool, size int) bool {
+	now := time.Now()
+	for _, id := range qerIDs {
+		q, ok := s.qers[id]
+		if !ok {
+			continue
+		}
+		gate, bucket := q.gateDL, q.dl
+		if uplink {
+			gate, bucket = q.gateUL, q.ul
+		}
+		if gate == ie.GateStatusClosed {
+			return false
+		}
+		if bucket != nil && !bucket.take(now, size) {
+			return false
+		}
+	}
+	return true
+}
+
+// tokenBucket 按 MBR 限速的令牌桶，令牌单位为字节
+type tokenBucket struct {
+	rate   float64 // 字节/秒
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newTokenBucket 创建速率为 kbps 的令牌桶，桶深为 20ms 的流量且不小于两个最大报文
+func newTokenBucket(kbps uint64) *tokenBucket {
+	rate := float64(kbps) * 1000 / 8
+	burst := rate * 0.02
+	if burst < 3000 {
+		burst = 3000
+	}
+	return &tokenBucket{rate: rate, burst: burst, tokens: burst, last: time.Now()}
+}
+
+func (b *tokenBucket) take(now time.Time, size int) bool {
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	b.last = now
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	if b.tokens < float64(size) {
+		return false
+	}
+	b.tokens -= float64(size)
+	return true
+}
@@ -0,0 +1,61 @@
+package mockupf
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+// SendSessionReport 向指定 CP SEID 的会话发送 Session Report Request，ies 为上报内容
+func (m *MockUPF) SendSessionReport(cpSEID uint64, ies ...*ie.IE) error {
+	m.mu.Lock()
+	var sess *session
+	for _, s := range m.sessions {
+		if s.cpSEID == cpSEID {
+			sess = s
+			break
+		}
+	}
+	m.nextSeq = (m.nextSeq + 1) & 0xffffff
+	seq := m.nextSeq
+	m.mu.Unlock()
+
+	if sess == nil {
+		return fmt.Errorf("session with CP SEID 0x%016x not found", cpSEID)
+	}
+
+	req := message.NewSessionReportRequest(0, 0, cpSEID, seq, 0, ies...)
+	data := make([]byte, req.MarshalLen())
+	if err := req.MarshalTo(data); err != nil {
+		return fmt.Errorf("marshal session report request failed: %w", err)
+	}
+
+	m.writeN4(data, sess.cpAddr)
+	return nil
+}
+
+// ReportResponseCauses 返回收到的 Session Report Response 的 Cause
+func (m *MockUPF) ReportResponseCauses() []uint8 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]uint8(nil), m.reportCauses...)
+}
+
+func (m *MockUPF) handleReportResponse(resp *message.SessionReportResponse) {
+	if resp.Cause == nil {
+		log.Printf("mock upf session report response without cause, seq=%d", resp.Sequence())
+		return
+	}
+
+	cause, err := resp.Cause.Cause()
+	if err != nil {
+		log.Printf("mock upf parse session report response cause failed: %v", err)
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reportCauses = append(m.reportCauses, cause)
+}
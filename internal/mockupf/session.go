@@ -0,0 +1,256 @@
+package mockupf
+
+import (
+	"net"
+
+	"github.com/wmnsk/go-pfcp/ie"
+)
+
+// pdr 模拟 UPF 保存的 PDR
+type pdr struct {
+	id              uint16
+	sourceInterface uint8
+	teid            uint32 // 本端分配的 F-TEID，0 表示无
+	ueIP            net.IP
+	chooseUEIP      bool // UE 地址由 UPF 分配 (CHV4)
+	farID           uint32
+	hasFAR          bool
+	qerIDs          []uint32
+}
+
+// Apply Action 标志位
+const (
+	applyActionDROP = 0x01
+	applyActionFORW = 0x02
+	applyActionBUFF = 0x04
+	applyActionNOCP = 0x08
+)
+
+// far 模拟 UPF 保存的 FAR
+type far struct {
+	id          uint32
+	applyAction []byte
+	ohc         *ie.OuterHeaderCreationFields
+	sndem       bool // Update Forwarding Parameters 中设置了 PFCPSMReq-Flags 的 SNDEM
+
+	buffered [][]byte // BUFF 时缓存的下行报文
+	notified bool     // 本轮缓存是否已上报 Downlink Data Report
+}
+
+// action 返回 Apply Action 的第一个字节
+func (f *far) action() uint8 {
+	if len(f.applyAction) == 0 {
+		return 0
+	}
+	return f.applyAction[0]
+}
+
+// session 模拟 UPF 保存的会话
+type session struct {
+	cpSEID uint64
+	upSEID uint64
+	cpAddr *net.UDPAddr
+	pdrs   map[uint16]*pdr
+	fars   map[uint32]*far
+	qers   map[uint32]*qer
+
+	bufferLimit int // 每个 FAR 最多缓存的下行报文数，来自 BAR 的 Suggested Buffering Packets Count
+
+	usage  Stats  // 自上次用量上报以来的流量 (内层 IP 包)，所有 URR 共用
+	urSeqN uint32 // 下一个 UR-SEQN
+}
+
+// usageReport 生成 Query URR 的用量上报并清零计数
+func (s *session) usageReport(urrIDs []uint32) []*ie.IE {
+	reports := make([]*ie.IE, 0, len(urrIDs))
+	for _, id := range urrIDs {
+		reports = append(reports, ie.NewUsageReportWithinSessionModificationResponse(
+			ie.NewURRID(id),
+			ie.NewURSEQN(s.urSeqN),
+			ie.NewUsageReportTrigger(0x80, 0x00, 0x00), // IMMER
+			ie.NewVolumeMeasurement(0x3f,
+				uint64(s.usage.UplinkBytes+s.usage.DownlinkBytes),
+				uint64(s.usage.UplinkBytes),
+				uint64(s.usage.DownlinkBytes),
+				uint64(s.usage.UplinkPackets+s.usage.DownlinkPackets),
+				uint64(s.usage.UplinkPackets),
+				uint64(s.usage.DownlinkPackets),
+			),
+		))
+		s.urSeqN++
+	}
+	s.usage = Stats{}
+	return reports
+}
+
+func newSession(cpSEID, upSEID uint64, cpAddr *net.UDPAddr) *session {
+	return &session{
+		cpSEID: cpSEID,
+		upSEID: upSEID,
+		cpAddr: cpAddr,
+		pdrs:   make(map[uint16]*pdr),
+		fars:   make(map[uint32]*far),
+		qers:   make(map[uint32]*qer),
+
+		bufferLimit: defaultBufferLimit,
+	}
+}
+
+// defaultBufferLimit 未配置 BAR 时每个 FAR 最多缓存的下行报文数
+const defaultBufferLimit = 64
+
+// applyBAR 按 Create BAR / Update BAR 设置缓存上限
+func (s *session) applyBAR(i *ie.IE) {
+	if count, err := i.SuggestedBufferingPacketsCount(); err == nil && count > 0 {
+		s.bufferLimit = int(count)
+	}
+}
+
+// parsePDR 从 Create PDR / Update PDR 中解析 PDR
+func parsePDR(i *ie.IE) (*pdr, *ie.FTEIDFields, error) {
+	id, err := i.PDRID()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	p := &pdr{id: id}
+
+	if src, err := i.SourceInterface(); err == nil {
+		p.sourceInterface = src
+	}
+
+	if farID, err := i.FARID(); err == nil {
+		p.farID = farID
+		p.hasFAR = true
+	}
+
+	if children, err := i.ValueAsGrouped(); err == nil {
+		for _, x := range children {
+			if x.Type == ie.QERID {
+				if id, err := x.QERID(); err == nil {
+					p.qerIDs = append(p.qerIDs, id)
+				}
+			}
+		}
+	}
+
+	var fteid *ie.FTEIDFields
+	pdi, err := i.FindByType(ie.PDI)
+	if err == nil {
+		if f, err := pdi.FTEID(); err == nil {
+			fteid = f
+		}
+		if ue, err := pdi.UEIPAddress(); err == nil {
+			p.ueIP = ue.IPv4Address
+			p.chooseUEIP = ue.Flags&0x10 != 0
+		}
+	}
+
+	return p, fteid, nil
+}
+
+// merge 按 Update PDR 更新其中携带的字段
+func (p *pdr) merge(update *pdr, i *ie.IE) {
+	if _, err := i.SourceInterface(); err == nil {
+		p.sourceInterface = update.sourceInterface
+	}
+	if update.hasFAR {
+		p.farID, p.hasFAR = update.farID, true
+	}
+	if update.ueIP != nil {
+		p.ueIP = update.ueIP
+	}
+	if len(update.qerIDs) > 0 {
+		p.qerIDs = update.qerIDs
+	}
+}
+
+// parseFAR 从 Create FAR / Update FAR 中解析 FAR
+func parseFAR(i *ie.IE) (*far, error) {
+	id, err := i.FARID()
+	if err != nil {
+		return nil, err
+	}
+
+	f := &far{id: id}
+
+	if action, err := i.ApplyAction(); err == nil {
+		f.applyAction = action
+	}
+
+	children, err := i.ValueAsGrouped()
+	if err != nil {
+		return nil, err
+	}
+	for _, x := range children {
+		switch x.Type {
+		case ie.ForwardingParameters, ie.UpdateForwardingParameters:
+			if ohc, err := x.OuterHeaderCreation(); err == nil {
+				f.ohc = ohc
+			}
+			if x.Type == ie.UpdateForwardingParameters {
+				f.sndem = hasSNDEM(x)
+			}
+		}
+	}
+
+	return f, nil
+}
+
+// hasSNDEM 判断 Update Forwarding Parameters 中的 PFCPSMReq-Flags 是否设置了 SNDEM
+func hasSNDEM(params *ie.IE) bool {
+	children, err := params.ValueAsGrouped()
+	if err != nil {
+		return false
+	}
+	for _, x := range children {
+		if x.Type == ie.PFCPSMReqFlags && x.HasSNDEM() {
+			return true
+		}
+	}
+	return false
+}
+
+// uplinkPDR 查找本端 F-TEID 为 teid 的上行 PDR
+func (s *session) uplinkPDR(teid uint32) *pdr {
+	for _, p := range s.pdrs {
+		if p.teid == teid {
+			return p
+		}
+	}
+	return nil
+}
+
+// downlinkFAR 查找 UE IP 对应的下行 PDR 及其关联的 FAR
+func (s *session) downlinkFAR(ueIP net.IP) (*pdr, *far) {
+	var fallback *pdr
+	for _, p := range s.pdrs {
+		if p.sourceInterface != ie.SrcInterfaceCore || !p.hasFAR {
+			continue
+		}
+		f, ok := s.fars[p.farID]
+		if !ok {
+			continue
+		}
+		if p.ueIP != nil && p.ueIP.Equal(ueIP) {
+			return p, f
+		}
+		if fallback == nil {
+			fallback = p
+		}
+	}
+	if fallback == nil {
+		return nil, nil
+	}
+	return fallback, s.fars[fallback.farID]
+}
+
+// farPDR 查找关联 FAR 的下行 PDR
+func (s *session) farPDR(farID uint32) *pdr {
+	for _, p := range s.pdrs {
+		if p.sourceInterface == ie.SrcInterfaceCore && p.hasFAR && p.farID == farID {
+			return p
+		}
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// WriteJSON 以 JSON 格式输出报告
+func WriteJSON(w io.Writer, r *Report) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(r); err != nil {
+		return fmt.Errorf("encode json report failed: %w", err)
+	}
+	return nil
+}
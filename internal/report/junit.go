@@ -0,0 +1,237 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+	SystemErr string          `xml:"system-err,omitempty"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *struct{}     `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Body    string `xml:",chardata"`
+}
+
+// WriteJUnit 以 JUnit XML 格式输出报告，每个测试用例集对应一个 testsuite，每个步骤对应一个 testcase
+func WriteJUnit(w io.Writer, r *Report) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	suites := junitTestSuites{
+		Time: seconds(r.EndTime.Sub(r.StartTime).Seconds()),
+	}
+
+	for _, set := range r.Sets {
+		suite := junitTestSuite{
+			Name:      set.Name,
+			Time:      seconds(set.EndTime.Sub(set.StartTime).Seconds()),
+			Timestamp: set.StartTime.Format("2006-01-02T15:04:05"),
+		}
+
+		for _, step := range set.Steps {
+			tc := junitTestCase{
+				Name:      fmt.Sprintf("step %d %s", step.Step, step.Type),
+				ClassName: set.Name,
+				Time:      seconds(step.Duration().Seconds()),
+				SystemOut: stepDetail(step),
+			}
+
+			switch step.Verdict {
+			case VerdictFailed:
+				body := step.Error
+				if len(step.Mismatches) > 0 {
+					body = strings.Join(step.Mismatches, "\n")
+				}
+				tc.Failure = &junitFailure{Message: step.Error, Body: body}
+				suite.Failures++
+			case VerdictSkipped:
+				tc.Skipped = &struct{}{}
+				suite.Skipped++
+			}
+
+			suite.Cases = append(suite.Cases, tc)
+			suite.Tests++
+		}
+
+		if set.Teardown != nil {
+			suite.addTeardown(set.Name, set.Teardown)
+		}
+
+		if set.Verdict == VerdictFailed && suite.Failures == 0 {
+			suite.SystemErr = set.Error
+		}
+
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Skipped += suite.Skipped
+		suites.Suites = append(suites.Suites, suite)
+	}
+
+	// 所有测试用例集结束后的清理单独作为一个 testsuite
+	if r.Teardown != nil {
+		suite := junitTestSuite{
+			Name:      "teardown",
+			Timestamp: r.EndTime.Format("2006-01-02T15:04:05"),
+			Time:      seconds(0),
+		}
+		suite.addTeardown("teardown", r.Teardown)
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Suites = append(suites.Suites, suite)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return fmt.Errorf("write junit report failed: %w", err)
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(suites); err != nil {
+		return fmt.Errorf("encode junit report failed: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// addTeardown 将清理结果作为名为 teardown 的 testcase 加入 testsuite，未能删除全部会话时记为失败
+func (s *junitTestSuite) addTeardown(className string, t *TeardownResult) {
+	details := []string{fmt.Sprintf("deleted=%d/%d failed=%d", t.Deleted, t.Requested, t.Failed)}
+	if t.Unresolved > 0 {
+		details = append(details, fmt.Sprintf("unresolved=%d", t.Unresolved))
+	}
+	if t.SessionSetDeletion != "" {
+		details = append(details, "session-set-deletion="+t.SessionSetDeletion)
+	}
+	if t.AssociationRelease != "" {
+		details = append(details, "association-release="+t.AssociationRelease)
+	}
+
+	tc := junitTestCase{
+		Name:      "teardown",
+		ClassName: className,
+		Time:      seconds(0),
+		SystemOut: strings.Join(details, " "),
+	}
+	if !t.Clean() {
+		tc.Failure = &junitFailure{
+			Message: fmt.Sprintf("%d sessions left on UPF", t.Failed+t.Unresolved),
+			Body:    strings.Join(t.Failures, "\n"),
+		}
+		s.Failures++
+	}
+	s.Cases = append(s.Cases, tc)
+	s.Tests++
+}
+
+// stepDetail 输出步骤的 Cause、时延和数据面统计
+func stepDetail(step *StepResult) string {
+	var details []string
+	if step.Cause != nil {
+		details = append(details, fmt.Sprintf("cause=%d", *step.Cause))
+	}
+	if step.LatencyMs != nil {
+		details = append(details, fmt.Sprintf("latency=%.3fms", *step.LatencyMs))
+	}
+	if step.Retries > 0 {
+		details = append(details, fmt.Sprintf("retries=%d", step.Retries))
+	}
+	if dp := step.DataPlane; dp != nil {
+		latencyLabel := "latency"
+		if dp.RoundTrip {
+			latencyLabel = "rtt"
+		}
+		details = append(details, fmt.Sprintf("%s sent=%d received=%d lost=%d loss=%.2f%% %s(min/avg/max)=%.3f/%.3f/%.3fms",
+			dp.TestType, dp.PacketsSent, dp.PacketsReceived, dp.PacketsLost, dp.PacketLossRate, latencyLabel,
+			dp.MinLatencyMs, dp.AvgLatencyMs, dp.MaxLatencyMs))
+		if dp.Reordered > 0 || dp.Duplicates > 0 || dp.JitterMs > 0 {
+			details = append(details, fmt.Sprintf("reordered=%d duplicates=%d jitter=%.3fms",
+				dp.Reordered, dp.Duplicates, dp.JitterMs))
+		}
+		if len(dp.Windows) > 0 {
+			details = append(details, fmt.Sprintf("throughput=%.3fMbps windows=%d", dp.ThroughputMbps, len(dp.Windows)))
+		}
+		if dp.MbrUlMbps > 0 || dp.MbrDlMbps > 0 {
+			details = append(details, fmt.Sprintf("mbr(ul/dl)=%.3f/%.3fMbps", dp.MbrUlMbps, dp.MbrDlMbps))
+		}
+		if d := dp.Directions; d != nil {
+			details = append(details, fmt.Sprintf("uplink sent=%d received=%d lost=%d downlink sent=%d received=%d lost=%d",
+				d.UplinkSent, d.UplinkReceived, d.UplinkLost, d.DownlinkSent, d.DownlinkReceived, d.DownlinkLost))
+		}
+	}
+	if h := step.Handover; h != nil {
+		details = append(details, fmt.Sprintf("handover %s/%d -> %s/%d end-markers=%d source-path-packets=%d",
+			h.SourceGnbIp, h.SourceTeid, h.TargetGnbIp, h.TargetTeid, h.EndMarkers, h.SourcePathPackets))
+	}
+	if b := step.Bulk; b != nil {
+		details = append(details, fmt.Sprintf("bulk succeeded=%d/%d rate=%.1f/s latency p50/p90/p99=%.2f/%.2f/%.2fms",
+			b.Succeeded, b.Requested, b.SetupRate, b.LatencyP50Ms, b.LatencyP90Ms, b.LatencyP99Ms))
+		if b.FirstFailure != "" {
+			details = append(details, "first-failure="+b.FirstFailure)
+		}
+	}
+	if l := step.Load; l != nil {
+		details = append(details, fmt.Sprintf("load cps=%.1f/%.1f established=%d/%d deleted=%d timeouts=%d peak-outstanding=%d",
+			l.AchievedCps, l.TargetCps, l.Established, l.Attempted, l.Deleted, l.Timeouts, l.PeakOutstanding))
+		if l.FirstFailure != "" {
+			details = append(details, "first-failure="+l.FirstFailure)
+		}
+	}
+	if len(step.Captures) > 0 {
+		names := make([]string, 0, len(step.Captures))
+		for name := range step.Captures {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			details = append(details, fmt.Sprintf("%s=%v", name, step.Captures[name]))
+		}
+	}
+	for _, check := range step.GateChecks {
+		detail := fmt.Sprintf("gate %s expected=%s observed=%s", check.Direction, check.Expected, check.Observed)
+		if check.Skipped {
+			detail += " skipped"
+		}
+		details = append(details, detail)
+	}
+	for _, check := range step.UsageChecks {
+		details = append(details, fmt.Sprintf("urr%d %s expected=%d reported=%d",
+			check.URRID, check.Metric, check.Expected, check.Reported))
+	}
+	return strings.Join(details, " ")
+}
+
+func seconds(s float64) string {
+	if s < 0 {
+		s = 0
+	}
+	return fmt.Sprintf("%.3f", s)
+}
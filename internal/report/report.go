@@ -0,0 +1,376 @@
+package report
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Verdict 测试结论
+type Verdict string
+
+const (
+	VerdictPassed  Verdict = "passed"
+	VerdictFailed  Verdict = "failed"
+	VerdictSkipped Verdict = "skipped"
+)
+
+// Report 一次运行的测试报告
+type Report struct {
+	StartTime time.Time       `json:"startTime"`
+	EndTime   time.Time       `json:"endTime"`
+	Verdict   Verdict         `json:"verdict"`
+	Sets      []*SetResult    `json:"sets"`
+	Teardown  *TeardownResult `json:"teardown,omitempty"` // 所有测试用例集结束后清理遗留会话的结果
+
+	mu sync.Mutex
+}
+
+// SetResult 测试用例集结果
+type SetResult struct {
+	Index     int             `json:"index"`
+	Name      string          `json:"name"`
+	StartTime time.Time       `json:"startTime"`
+	EndTime   time.Time       `json:"endTime"`
+	Verdict   Verdict         `json:"verdict"`
+	Error     string          `json:"error,omitempty"`
+	Steps     []*StepResult   `json:"steps"`
+	Teardown  *TeardownResult `json:"teardown,omitempty"` // 测试用例集结束时清理本集遗留会话的结果
+}
+
+// TeardownResult 清理遗留会话的结果，逐个发送 Session Deletion Request 仍未能删除时，
+// 可按配置发送 Session Set Deletion Request 或 Association Release Request
+type TeardownResult struct {
+	Requested          int      `json:"requested"`                    // 发送删除请求的会话数
+	Deleted            int      `json:"deleted"`                      // 删除成功的会话数
+	Failed             int      `json:"failed"`                       // 删除失败的会话数
+	Unresolved         int      `json:"unresolved,omitempty"`         // 未收到建立响应、没有 UP F-SEID 而无法删除的会话数
+	Failures           []string `json:"failures,omitempty"`           // 删除失败的原因
+	SessionSetDeletion string   `json:"sessionSetDeletion,omitempty"` // Session Set Deletion 的结果: accepted 或失败原因，未发送时为空
+	AssociationRelease string   `json:"associationRelease,omitempty"` // Association Release 的结果: accepted 或失败原因，未发送时为空
+}
+
+// TeardownAccepted Session Set Deletion 或 Association Release 被 UPF 接受
+const TeardownAccepted = "accepted"
+
+// Clean 判断遗留会话是否已全部删除：逐个删除全部成功，或 UPF 接受了 Session Set Deletion 或 Association Release
+func (t *TeardownResult) Clean() bool {
+	if t.Failed == 0 && t.Unresolved == 0 {
+		return true
+	}
+	return t.SessionSetDeletion == TeardownAccepted || t.AssociationRelease == TeardownAccepted
+}
+
+// StepResult 测试步骤结果
+type StepResult struct {
+	Step               int                    `json:"step"`
+	Type               string                 `json:"type"`
+	Action             string                 `json:"action,omitempty"`
+	StartTime          time.Time              `json:"startTime"`
+	EndTime            time.Time              `json:"endTime"`
+	LatencyMs          *float64               `json:"latencyMs,omitempty"` // 请求到响应的时延
+	Cause              *uint8                 `json:"cause,omitempty"`     // 收到的 Cause
+	Retries            int                    `json:"retries"`             // 请求重传次数
+	DuplicateResponses int                    `json:"duplicateResponses"`  // 丢弃的重复响应数
+	Captures           map[string]interface{} `json:"captures,omitempty"`  // 从响应中捕获的变量
+	DataPlane          *DataPlaneResult       `json:"dataPlane,omitempty"`
+	UsageChecks        []UsageCheck           `json:"usageChecks,omitempty"` // URR 流量核对结果
+	GateChecks         []GateCheck            `json:"gateChecks,omitempty"`  // QER 门控核对结果
+	Handover           *HandoverResult        `json:"handover,omitempty"`    // 路径切换结果
+	Bulk               *BulkResult            `json:"bulk,omitempty"`        // 批量会话结果
+	Load               *LoadResult            `json:"load,omitempty"`        // CPS 负载结果
+	Verdict            Verdict                `json:"verdict"`
+	Error              string                 `json:"error,omitempty"`
+	Mismatches         []string               `json:"mismatches,omitempty"`
+}
+
+// DataPlaneResult 数据平面测试结果
+type DataPlaneResult struct {
+	TestType        string             `json:"testType"`
+	PacketsSent     int                `json:"packetsSent"`
+	PacketsReceived int                `json:"packetsReceived"`
+	BytesSent       int                `json:"bytesSent"`
+	BytesReceived   int                `json:"bytesReceived"`
+	PacketsLost     int                `json:"packetsLost"`
+	PacketLossRate  float64            `json:"packetLossRate"`
+	AvgLatencyMs    float64            `json:"avgLatencyMs"`
+	MinLatencyMs    float64            `json:"minLatencyMs"`
+	MaxLatencyMs    float64            `json:"maxLatencyMs"`
+	RoundTrip       bool               `json:"roundTrip"` // 时延为往返时延 (RTT)，否则为单向时延
+	ThroughputMbps  float64            `json:"throughputMbps"`
+	Reordered       int                `json:"reordered"`
+	Duplicates      int                `json:"duplicates"`
+	JitterMs        float64            `json:"jitterMs"`
+	Windows         []ThroughputWindow `json:"windows,omitempty"`    // 吞吐量测试的滑动窗口速率
+	MbrUlMbps       float64            `json:"mbrUlMbps,omitempty"`  // 核对上行时使用的 MBR
+	MbrDlMbps       float64            `json:"mbrDlMbps,omitempty"`  // 核对下行时使用的 MBR
+	Directions      *DirectionResult   `json:"directions,omitempty"` // 启用 DN 仿真器时分别统计的上下行
+	Success         bool               `json:"success"`
+}
+
+// DirectionResult 按方向统计的收发结果，上行在 DN 仿真器处接收，下行在 gNB 处接收
+type DirectionResult struct {
+	UplinkSent       int `json:"uplinkSent"`
+	UplinkReceived   int `json:"uplinkReceived"`
+	UplinkLost       int `json:"uplinkLost"`
+	DownlinkSent     int `json:"downlinkSent"`
+	DownlinkReceived int `json:"downlinkReceived"`
+	DownlinkLost     int `json:"downlinkLost"`
+}
+
+// HandoverResult 路径切换结果，下行从源 gNB 切换到目标 gNB
+type HandoverResult struct {
+	SourceGnbIp        string   `json:"sourceGnbIp"`
+	SourceTeid         uint32   `json:"sourceTeid"`
+	TargetGnbIp        string   `json:"targetGnbIp"`
+	TargetTeid         uint32   `json:"targetTeid"`
+	EndMarkerRequested bool     `json:"endMarkerRequested"`           // 是否设置了 SNDEM
+	EndMarkers         int      `json:"endMarkers"`                   // 源路径上收到的 End Marker 数
+	EndMarkerLatencyMs *float64 `json:"endMarkerLatencyMs,omitempty"` // 从发送修改请求到收到首个 End Marker 的时延
+	SourcePathPackets  int      `json:"sourcePathPackets"`            // 切换后仍到达源路径的下行 G-PDU 数
+}
+
+// BulkResult 批量建立或删除会话的结果，时延分位数只统计成功的请求
+type BulkResult struct {
+	Requested    int     `json:"requested"`
+	Succeeded    int     `json:"succeeded"`
+	Failed       int     `json:"failed"`
+	Deleted      int     `json:"deleted,omitempty"` // 建立后在同一步骤中删除的会话数
+	DurationMs   float64 `json:"durationMs"`
+	SetupRate    float64 `json:"setupRate"` // 实际完成速率 (会话/秒)
+	LatencyP50Ms float64 `json:"latencyP50Ms"`
+	LatencyP90Ms float64 `json:"latencyP90Ms"`
+	LatencyP99Ms float64 `json:"latencyP99Ms"`
+	LatencyMaxMs float64 `json:"latencyMaxMs"`
+	FirstFailure string  `json:"firstFailure,omitempty"` // 首个失败的原因，如拒绝的 Cause
+}
+
+// LoadResult CPS 负载结果，会话按目标速率建立，保持一段时间后删除
+type LoadResult struct {
+	TargetCps        float64           `json:"targetCps"`
+	AchievedCps      float64           `json:"achievedCps"` // 发起期间实际建立成功的速率
+	DurationMs       float64           `json:"durationMs"`
+	Attempted        int               `json:"attempted"`
+	Established      int               `json:"established"`
+	EstablishFailed  int               `json:"establishFailed"`
+	Deleted          int               `json:"deleted"`
+	DeleteFailed     int               `json:"deleteFailed"`
+	Timeouts         int               `json:"timeouts"`  // 重传后仍未收到响应的请求
+	Throttled        int               `json:"throttled"` // 达到会话数上限而未发起的建立
+	PeakOutstanding  int               `json:"peakOutstanding"`
+	SessionReports   int               `json:"sessionReports"` // 保持期间收到的 Session Report Request
+	EstablishLatency *LatencyHistogram `json:"establishLatency"`
+	DeleteLatency    *LatencyHistogram `json:"deleteLatency"`
+	Intervals        []LoadInterval    `json:"intervals"`
+	FirstFailure     string            `json:"firstFailure,omitempty"`
+}
+
+// LatencyHistogram 响应时延直方图，Buckets 按上限升序，超过最大上限的计入 Overflow
+type LatencyHistogram struct {
+	Count    int             `json:"count"`
+	Buckets  []LatencyBucket `json:"buckets"`
+	Overflow int             `json:"overflow"`
+	MaxMs    float64         `json:"maxMs"`
+}
+
+// LatencyBucket 时延不超过 UpperMs 且大于前一个桶上限的响应数
+type LatencyBucket struct {
+	UpperMs float64 `json:"upperMs"`
+	Count   int     `json:"count"`
+}
+
+// LoadInterval CPS 负载一个统计间隔内的计数，Outstanding 为间隔结束时存在的会话数
+type LoadInterval struct {
+	OffsetMs    float64 `json:"offsetMs"`
+	Cps         float64 `json:"cps"`
+	Established int     `json:"established"`
+	Deleted     int     `json:"deleted"`
+	Failed      int     `json:"failed"`
+	Timeouts    int     `json:"timeouts"`
+	TimeoutRate float64 `json:"timeoutRate"` // 每秒超时数
+	Outstanding int     `json:"outstanding"`
+}
+
+// ThroughputWindow 吞吐量测试一个滑动窗口内的发送速率和两个方向的实际速率
+type ThroughputWindow struct {
+	OffsetMs     float64 `json:"offsetMs"`
+	OfferedMbps  float64 `json:"offeredMbps"`  // 测试端发出的上行速率
+	UplinkMbps   float64 `json:"uplinkMbps"`   // DN 仿真器收到的上行速率
+	DownlinkMbps float64 `json:"downlinkMbps"` // 测试端收到的下行速率
+}
+
+// GateCheck 一个方向的 QER 门控核对结果，Observed 为 forwarded、blocked 或 untested，
+// 期望关闭而没有流量经过的方向记为跳过
+type GateCheck struct {
+	Direction string `json:"direction"`
+	Expected  string `json:"expected"`
+	Observed  string `json:"observed"`
+	Passed    bool   `json:"passed"`
+	Skipped   bool   `json:"skipped,omitempty"`
+}
+
+// UsageCheck URR 上报值与测试端实际收发流量的核对结果
+type UsageCheck struct {
+	URRID            uint32  `json:"urrId"`
+	Metric           string  `json:"metric"`
+	Expected         uint64  `json:"expected"` // 测试端统计值
+	Reported         uint64  `json:"reported"` // UPF 上报值
+	DeviationPercent float64 `json:"deviationPercent"`
+	Passed           bool    `json:"passed"`
+}
+
+// New 创建测试报告
+func New() *Report {
+	return &Report{
+		StartTime: time.Now(),
+		Sets:      make([]*SetResult, 0),
+	}
+}
+
+// NewSet 添加测试用例集结果，可并发调用
+func (r *Report) NewSet(index int, name string) *SetResult {
+	set := &SetResult{
+		Index:     index,
+		Name:      name,
+		StartTime: time.Now(),
+		Steps:     make([]*StepResult, 0),
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Sets = append(r.Sets, set)
+	return set
+}
+
+// Finish 结束测试报告并计算总体结论
+func (r *Report) Finish() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.EndTime = time.Now()
+	r.Verdict = VerdictPassed
+	for _, set := range r.Sets {
+		if set.Verdict != VerdictPassed {
+			r.Verdict = VerdictFailed
+		}
+	}
+	if r.Teardown != nil && !r.Teardown.Clean() {
+		r.Verdict = VerdictFailed
+	}
+}
+
+// Failed 返回失败的测试用例集数量
+func (r *Report) Failed() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	failed := 0
+	for _, set := range r.Sets {
+		if set.Verdict != VerdictPassed {
+			failed++
+		}
+	}
+	return failed
+}
+
+// BeginStep 开始一个测试步骤
+func (s *SetResult) BeginStep(step int, typ, action string) *StepResult {
+	result := &StepResult{
+		Step:      step,
+		Type:      typ,
+		Action:    action,
+		StartTime: time.Now(),
+	}
+	s.Steps = append(s.Steps, result)
+	return result
+}
+
+// SkipStep 记录未执行的测试步骤
+func (s *SetResult) SkipStep(step int, typ, action string) {
+	s.Steps = append(s.Steps, &StepResult{
+		Step:    step,
+		Type:    typ,
+		Action:  action,
+		Verdict: VerdictSkipped,
+	})
+}
+
+// Finish 结束测试用例集
+func (s *SetResult) Finish(err error) {
+	s.EndTime = time.Now()
+	if err != nil {
+		s.Verdict = VerdictFailed
+		s.Error = err.Error()
+		return
+	}
+	s.Verdict = VerdictPassed
+}
+
+// Finish 结束测试步骤
+func (s *StepResult) Finish(err error) {
+	s.EndTime = time.Now()
+	if err != nil {
+		s.Verdict = VerdictFailed
+		s.Error = err.Error()
+		return
+	}
+	s.Verdict = VerdictPassed
+}
+
+// SetLatency 记录请求到响应的时延
+func (s *StepResult) SetLatency(d time.Duration) {
+	ms := durationMs(d)
+	s.LatencyMs = &ms
+}
+
+// SetCause 记录收到的 Cause
+func (s *StepResult) SetCause(cause uint8) {
+	s.Cause = &cause
+}
+
+// Duration 返回步骤耗时
+func (s *StepResult) Duration() time.Duration {
+	if s.EndTime.IsZero() {
+		return 0
+	}
+	return s.EndTime.Sub(s.StartTime)
+}
+
+// CheckFormat 检查报告格式是否受支持，空串视为 json
+func CheckFormat(format string) error {
+	switch format {
+	case "json", "", "junit":
+		return nil
+	}
+	return fmt.Errorf("unsupported report format: %s", format)
+}
+
+// WriteFile 按格式将报告写入文件，format 为 json 或 junit。
+// 格式不受支持时不创建文件
+func WriteFile(path, format string, r *Report) error {
+	if err := CheckFormat(format); err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create report file failed: %w", err)
+	}
+	defer f.Close()
+
+	if format == "junit" {
+		err = WriteJUnit(f, r)
+	} else {
+		err = WriteJSON(f, r)
+	}
+	if err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
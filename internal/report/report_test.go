@@ -0,0 +1,159 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestReport() *Report {
+	r := New()
+
+	passed := r.NewSet(0, "passed.yaml")
+	step := passed.BeginStep(1, "session_establishment_response", "recv")
+	step.SetCause(1)
+	step.SetLatency(1500 * time.Microsecond)
+	step.Finish(nil)
+	step = passed.BeginStep(2, "data_plane_test", "icmp")
+	step.DataPlane = &DataPlaneResult{TestType: "ICMP", PacketsSent: 3, PacketsReceived: 3, Success: true}
+	step.Finish(nil)
+	passed.Finish(nil)
+
+	failed := r.NewSet(1, "failed.yaml")
+	step = failed.BeginStep(1, "session_establishment_response", "recv")
+	step.SetCause(66)
+	step.Mismatches = []string{"cause: expect 1, got 66"}
+	step.Finish(errors.New("step 1 failed"))
+	failed.SkipStep(2, "session_deletion_request", "send")
+	failed.Finish(errors.New("step 1 failed"))
+
+	r.Finish()
+	return r
+}
+
+func TestWriteJSON(t *testing.T) {
+	r := newTestReport()
+
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, r); err != nil {
+		t.Fatalf("WriteJSON() error = %v", err)
+	}
+
+	var decoded Report
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("decode json report failed: %v", err)
+	}
+
+	if decoded.Verdict != VerdictFailed || len(decoded.Sets) != 2 {
+		t.Fatalf("unexpected report verdict %s with %d sets", decoded.Verdict, len(decoded.Sets))
+	}
+	if step := decoded.Sets[0].Steps[0]; step.Cause == nil || *step.Cause != 1 || step.LatencyMs == nil || *step.LatencyMs != 1.5 {
+		t.Errorf("unexpected step %+v", step)
+	}
+	if dp := decoded.Sets[0].Steps[1].DataPlane; dp == nil || dp.PacketsReceived != 3 {
+		t.Errorf("unexpected data plane result %+v", dp)
+	}
+	if r.Failed() != 1 {
+		t.Errorf("expect 1 failed set, got %d", r.Failed())
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	r := newTestReport()
+
+	var buf bytes.Buffer
+	if err := WriteJUnit(&buf, r); err != nil {
+		t.Fatalf("WriteJUnit() error = %v", err)
+	}
+
+	var decoded junitTestSuites
+	if err := xml.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("decode junit report failed: %v", err)
+	}
+
+	if decoded.Tests != 4 || decoded.Failures != 1 || decoded.Skipped != 1 {
+		t.Errorf("expect 4 tests, 1 failure, 1 skipped, got %d %d %d", decoded.Tests, decoded.Failures, decoded.Skipped)
+	}
+	if len(decoded.Suites) != 2 || decoded.Suites[1].Name != "failed.yaml" {
+		t.Fatalf("unexpected suites %+v", decoded.Suites)
+	}
+	failure := decoded.Suites[1].Cases[0].Failure
+	if failure == nil || !strings.Contains(failure.Body, "expect 1, got 66") {
+		t.Errorf("expect failure body with mismatches, got %+v", failure)
+	}
+}
+
+func TestWriteJUnit_Teardown(t *testing.T) {
+	r := New()
+	set := r.NewSet(0, "aborted.yaml")
+	step := set.BeginStep(1, "data_plane_test", "icmp")
+	step.Finish(errors.New("icmp timeout"))
+	set.Teardown = &TeardownResult{Requested: 1, Deleted: 1}
+	set.Finish(errors.New("step 1 failed"))
+	r.Teardown = &TeardownResult{
+		Requested:          2,
+		Deleted:            1,
+		Failed:             1,
+		Failures:           []string{"session 0x2: wait session deletion response failed"},
+		SessionSetDeletion: "rejected, cause 64",
+	}
+	r.Finish()
+
+	if r.Verdict != VerdictFailed || r.Teardown.Clean() {
+		t.Fatalf("expect failed verdict with unclean teardown, got %s", r.Verdict)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteJUnit(&buf, r); err != nil {
+		t.Fatalf("WriteJUnit() error = %v", err)
+	}
+	var decoded junitTestSuites
+	if err := xml.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("decode junit report failed: %v", err)
+	}
+
+	if decoded.Tests != 3 || decoded.Failures != 2 {
+		t.Errorf("expect 3 tests, 2 failures, got %d %d", decoded.Tests, decoded.Failures)
+	}
+	if len(decoded.Suites) != 2 || decoded.Suites[1].Name != "teardown" {
+		t.Fatalf("unexpected suites %+v", decoded.Suites)
+	}
+	if tc := decoded.Suites[0].Cases[1]; tc.Name != "teardown" || tc.Failure != nil {
+		t.Errorf("expect passed set teardown, got %+v", tc)
+	}
+	tc := decoded.Suites[1].Cases[0]
+	if tc.Failure == nil || !strings.Contains(tc.SystemOut, "session-set-deletion=rejected") {
+		t.Errorf("expect failed run teardown, got %+v", tc)
+	}
+
+	r.Teardown.SessionSetDeletion = TeardownAccepted
+	if !r.Teardown.Clean() {
+		t.Errorf("expect clean teardown after accepted session set deletion")
+	}
+}
+
+func TestWriteFile_UnsupportedFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.html")
+	if err := os.WriteFile(path, []byte("previous report"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteFile(path, "html", newTestReport()); err == nil {
+		t.Fatal("WriteFile() error = nil, want unsupported format")
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != "previous report" {
+		t.Errorf("expect existing file untouched, got %q, %v", data, err)
+	}
+
+	missing := filepath.Join(t.TempDir(), "report.xml")
+	WriteFile(missing, "xml", newTestReport())
+	if _, err := os.Stat(missing); !os.IsNotExist(err) {
+		t.Errorf("expect no file created for unsupported format, stat error = %v", err)
+	}
+}
@@ -0,0 +1,195 @@
+package resource
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net"
+	"sync"
+	"upftester/internal/config"
+)
+
+// pool 数值资源池，先顺序分配从未使用过的值，用尽后按释放顺序复用
+type pool struct {
+	name string
+	next uint64 // 下一个未使用过的值
+	last uint64 // 最大可分配的值
+	done bool   // 未使用过的值已分配完
+	free []uint64
+	used map[uint64]bool
+}
+
+func newPool(name string, start, max uint64, size uint32) *pool {
+	last := max
+	if size > 0 && uint64(size)-1 < max-start {
+		last = start + uint64(size) - 1
+	}
+	return &pool{name: name, next: start, last: last, used: make(map[uint64]bool)}
+}
+
+func (p *pool) allocate() (uint64, error) {
+	var v uint64
+	switch {
+	case !p.done:
+		v = p.next
+		if p.next == p.last {
+			p.done = true
+		} else {
+			p.next++
+		}
+	case len(p.free) > 0:
+		v = p.free[0]
+		p.free = p.free[1:]
+	default:
+		return 0, fmt.Errorf("%s pool exhausted", p.name)
+	}
+	p.used[v] = true
+	return v, nil
+}
+
+// release 归还 v，未分配的值忽略
+func (p *pool) release(v uint64) {
+	if !p.used[v] {
+		return
+	}
+	delete(p.used, v)
+	p.free = append(p.free, v)
+}
+
+// Allocator 按 ResourceConfig 分配 UE IPv4/IPv6 地址、SEID 和本端 TEID，
+// 会话删除后归还的资源在池中的新值用尽后复用
+type Allocator struct {
+	mu     sync.Mutex
+	seids  *pool
+	teids  *pool
+	ueIPv4 *pool // 值为 IPv4 地址的整数形式
+	ueIPv6 *pool // 值为 IPv6 地址的低 64 位
+	v6Base net.IP
+}
+
+// New 按配置创建资源分配器，起始 SEID/TEID 未配置时从 1 开始，未配置起始 UE 地址的地址族不可分配
+func New(cfg config.ResourceConfig) (*Allocator, error) {
+	a := &Allocator{}
+
+	startSEID := cfg.StartSeId
+	if startSEID == 0 {
+		startSEID = 1
+	}
+	startTEID := cfg.StartTeId
+	if startTEID == 0 {
+		startTEID = 1
+	}
+	a.seids = newPool("SEID", startSEID, math.MaxUint64, cfg.PoolSize)
+	a.teids = newPool("TEID", uint64(startTEID), math.MaxUint32, cfg.PoolSize)
+
+	if cfg.StartUeIp != "" {
+		ip := net.ParseIP(cfg.StartUeIp).To4()
+		if ip == nil {
+			return nil, fmt.Errorf("startUeIp %q is not an IPv4 address", cfg.StartUeIp)
+		}
+		a.ueIPv4 = newPool("UE IPv4", uint64(binary.BigEndian.Uint32(ip)), math.MaxUint32, cfg.PoolSize)
+	}
+
+	if cfg.StartUeIpv6 != "" {
+		ip := net.ParseIP(cfg.StartUeIpv6)
+		if ip == nil || ip.To4() != nil {
+			return nil, fmt.Errorf("startUeIpv6 %q is not an IPv6 address", cfg.StartUeIpv6)
+		}
+		a.v6Base = ip.To16()
+		a.ueIPv6 = newPool("UE IPv6", binary.BigEndian.Uint64(a.v6Base[8:]), math.MaxUint64, cfg.PoolSize)
+	}
+
+	return a, nil
+}
+
+// AllocateSEID 分配一个 SEID
+func (a *Allocator) AllocateSEID() (uint64, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.seids.allocate()
+}
+
+// AllocateTEID 分配一个本端 TEID
+func (a *Allocator) AllocateTEID() (uint32, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	v, err := a.teids.allocate()
+	return uint32(v), err
+}
+
+// AllocateUEIPv4 分配一个 UE IPv4 地址
+func (a *Allocator) AllocateUEIPv4() (net.IP, error) {
+	if a.ueIPv4 == nil {
+		return nil, fmt.Errorf("UE IPv4 pool is not configured, set resources.startUeIp")
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	v, err := a.ueIPv4.allocate()
+	if err != nil {
+		return nil, err
+	}
+	ip := make(net.IP, net.IPv4len)
+	binary.BigEndian.PutUint32(ip, uint32(v))
+	return ip, nil
+}
+
+// AllocateUEIPv6 分配一个 UE IPv6 地址
+func (a *Allocator) AllocateUEIPv6() (net.IP, error) {
+	if a.ueIPv6 == nil {
+		return nil, fmt.Errorf("UE IPv6 pool is not configured, set resources.startUeIpv6")
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	v, err := a.ueIPv6.allocate()
+	if err != nil {
+		return nil, err
+	}
+	ip := make(net.IP, net.IPv6len)
+	copy(ip, a.v6Base[:8])
+	binary.BigEndian.PutUint64(ip[8:], v)
+	return ip, nil
+}
+
+// ReleaseSEID 归还 SEID
+func (a *Allocator) ReleaseSEID(seid uint64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.seids.release(seid)
+}
+
+// ReleaseTEID 归还 TEID
+func (a *Allocator) ReleaseTEID(teid uint32) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.teids.release(uint64(teid))
+}
+
+// ReleaseUEIP 归还 UE IPv4 或 IPv6 地址
+func (a *Allocator) ReleaseUEIP(ip net.IP) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if v4 := ip.To4(); v4 != nil {
+		if a.ueIPv4 != nil {
+			a.ueIPv4.release(uint64(binary.BigEndian.Uint32(v4)))
+		}
+		return
+	}
+	if v6 := ip.To16(); v6 != nil && a.ueIPv6 != nil && bytes.Equal(v6[:8], a.v6Base[:8]) {
+		a.ueIPv6.release(binary.BigEndian.Uint64(v6[8:]))
+	}
+}
+
+// InUse 返回已分配未归还的 SEID、TEID 和 UE 地址数量
+func (a *Allocator) InUse() (seids, teids, ueIPs int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	seids, teids = len(a.seids.used), len(a.teids.used)
+	if a.ueIPv4 != nil {
+		ueIPs += len(a.ueIPv4.used)
+	}
+	if a.ueIPv6 != nil {
+		ueIPs += len(a.ueIPv6.used)
+	}
+	return seids, teids, ueIPs
+}
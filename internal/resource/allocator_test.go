@@ -0,0 +1,91 @@
+package resource
+
+import (
+	"testing"
+	"upftester/internal/config"
+)
+
+func TestAllocator_PoolSizeAndReuse(t *testing.T) {
+	a, err := New(config.ResourceConfig{StartUeIp: "10.250.0.254", StartTeId: 5, PoolSize: 3})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	var teids []uint32
+	for i := 0; i < 3; i++ {
+		teid, err := a.AllocateTEID()
+		if err != nil {
+			t.Fatalf("AllocateTEID() error = %v", err)
+		}
+		teids = append(teids, teid)
+	}
+	if teids[0] != 5 || teids[2] != 7 {
+		t.Errorf("expect TEIDs 5..7, got %v", teids)
+	}
+	if _, err := a.AllocateTEID(); err == nil {
+		t.Error("expect TEID pool exhausted")
+	}
+
+	// 归还的值按归还顺序复用，重复归还忽略
+	a.ReleaseTEID(6)
+	a.ReleaseTEID(5)
+	a.ReleaseTEID(6)
+	for _, want := range []uint32{6, 5} {
+		if teid, err := a.AllocateTEID(); err != nil || teid != want {
+			t.Errorf("AllocateTEID() = %d, %v, want %d", teid, err, want)
+		}
+	}
+
+	// UE 地址跨越字节边界递增
+	var ips []string
+	for i := 0; i < 3; i++ {
+		ip, err := a.AllocateUEIPv4()
+		if err != nil {
+			t.Fatalf("AllocateUEIPv4() error = %v", err)
+		}
+		ips = append(ips, ip.String())
+	}
+	if ips[0] != "10.250.0.254" || ips[2] != "10.250.1.0" {
+		t.Errorf("unexpected UE IPv4 addresses %v", ips)
+	}
+
+	if _, err := a.AllocateUEIPv6(); err == nil {
+		t.Error("expect error without an IPv6 pool")
+	}
+}
+
+func TestSession_Release(t *testing.T) {
+	a, err := New(config.ResourceConfig{StartUeIp: "10.250.0.1", StartUeIpv6: "2001:db8::ffff:ffff:ffff:fffe"})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	s := a.NewSession()
+	seid, _ := s.SEID()
+	if again, _ := s.SEID(); again != seid || seid != 1 {
+		t.Errorf("expect SEID 1 allocated once, got %d and %d", seid, again)
+	}
+	v4, _ := s.UEIPv4()
+	if again, _ := s.UEIPv4(); !again.Equal(v4) {
+		t.Errorf("expect the same UE IPv4, got %s and %s", v4, again)
+	}
+	if v6, err := s.UEIPv6(); err != nil || v6.String() != "2001:db8::ffff:ffff:ffff:fffe" {
+		t.Errorf("UEIPv6() = %s, %v", v6, err)
+	}
+	s.AllocateTEID()
+	s.AllocateTEID()
+
+	if seids, teids, ueIPs := a.InUse(); seids != 1 || teids != 2 || ueIPs != 2 {
+		t.Errorf("InUse() = %d/%d/%d, want 1/2/2", seids, teids, ueIPs)
+	}
+	s.Release()
+	s.Release()
+	if seids, teids, ueIPs := a.InUse(); seids+teids+ueIPs != 0 {
+		t.Errorf("expect everything released, got %d/%d/%d", seids, teids, ueIPs)
+	}
+
+	// 新值用尽前不复用已归还的 SEID
+	if next, _ := a.NewSession().SEID(); next != 2 {
+		t.Errorf("expect fresh SEID 2, got %d", next)
+	}
+}
@@ -0,0 +1,98 @@
+package resource
+
+import (
+	"net"
+	"sync"
+)
+
+// Session 一个会话从分配器获得的资源。同一会话内多次引用 auto 的 SEID 和 UE 地址只分配一次，
+// TEID 每次引用都分配新值；会话删除时调用 Release 一并归还
+type Session struct {
+	alloc *Allocator
+
+	mu     sync.Mutex
+	seid   uint64
+	ueIPv4 net.IP
+	ueIPv6 net.IP
+	teids  []uint32
+}
+
+// NewSession 创建会话的资源集合
+func (a *Allocator) NewSession() *Session {
+	return &Session{alloc: a}
+}
+
+// SEID 返回会话的 SEID，首次调用时分配
+func (s *Session) SEID() (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.seid == 0 {
+		seid, err := s.alloc.AllocateSEID()
+		if err != nil {
+			return 0, err
+		}
+		s.seid = seid
+	}
+	return s.seid, nil
+}
+
+// UEIPv4 返回会话的 UE IPv4 地址，首次调用时分配
+func (s *Session) UEIPv4() (net.IP, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ueIPv4 == nil {
+		ip, err := s.alloc.AllocateUEIPv4()
+		if err != nil {
+			return nil, err
+		}
+		s.ueIPv4 = ip
+	}
+	return s.ueIPv4, nil
+}
+
+// UEIPv6 返回会话的 UE IPv6 地址，首次调用时分配
+func (s *Session) UEIPv6() (net.IP, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ueIPv6 == nil {
+		ip, err := s.alloc.AllocateUEIPv6()
+		if err != nil {
+			return nil, err
+		}
+		s.ueIPv6 = ip
+	}
+	return s.ueIPv6, nil
+}
+
+// AllocateTEID 为会话分配一个新的本端 TEID
+func (s *Session) AllocateTEID() (uint32, error) {
+	teid, err := s.alloc.AllocateTEID()
+	if err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.teids = append(s.teids, teid)
+	return teid, nil
+}
+
+// Release 归还会话的全部资源，可重复调用
+func (s *Session) Release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.seid != 0 {
+		s.alloc.ReleaseSEID(s.seid)
+		s.seid = 0
+	}
+	for _, ip := range []net.IP{s.ueIPv4, s.ueIPv6} {
+		if ip != nil {
+			s.alloc.ReleaseUEIP(ip)
+		}
+	}
+	s.ueIPv4, s.ueIPv6 = nil, nil
+	for _, teid := range s.teids {
+		s.alloc.ReleaseTEID(teid)
+	}
+	s.teids = nil
+}
@@ -2,8 +2,6 @@ package util
 
 import "sync/atomic"
 
-var GlobalSeid Uint64
-
 type Uint64 struct {
 	val uint64
 }
@@ -2,10 +2,6 @@ package util
 
 import "sync/atomic"
 
-var GlobalSeqNumber Uint32
-
-var GlobalTeId Uint32
-
 type Uint32 struct {
 	val uint32
 }
@@ -2,9 +2,6 @@ package util
 
 import "sync/atomic"
 
-// GlobalTeid 全局 TEID 分配器
-var GlobalTeid AtomicCounter32
-
 // TEID 管理器，用于分配和回收 TEID
 type TeidManager struct {
 	current uint32